@@ -1,6 +1,8 @@
 package acceptance_test
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +11,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"golang.org/x/sys/unix"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gexec"
@@ -66,6 +70,23 @@ func writeSkipConfig(index int, outDir string) error {
 	return ioutil.WriteFile(outpath, []byte(config), 0600)
 }
 
+// logLines parses the adapter's logs, one JSON object per line.
+func logLines(contents []byte) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		var fields map[string]interface{}
+		Expect(json.Unmarshal([]byte(line), &fields)).To(Succeed())
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+// lastLine returns the last line of output, where errors are reported as JSON.
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return lines[len(lines)-1]
+}
+
 func sameFile(path1, path2 string) bool {
 	fi1, err := os.Stat(path1)
 	Expect(err).NotTo(HaveOccurred())
@@ -88,6 +109,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 		fakeProcess        *os.Process
 		fakeConfigFilePath string
 		adapterLogFilePath string
+		stateDir           string
 	)
 
 	BeforeEach(func() {
@@ -116,6 +138,9 @@ var _ = Describe("Guardian CNI adapter", func() {
 		Expect(os.RemoveAll(adapterLogDir)).To(Succeed()) // directory need not exist
 		adapterLogFilePath = filepath.Join(adapterLogDir, "some-container-handle.log")
 
+		stateDir, err = ioutil.TempDir("", "state-dir")
+		Expect(err).NotTo(HaveOccurred())
+
 		Expect(writeConfig(0, cniConfigDir)).To(Succeed())
 		Expect(writeConfig(1, cniConfigDir)).To(Succeed())
 		Expect(writeConfig(2, cniConfigDir)).To(Succeed())
@@ -128,6 +153,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 			"cni_config_dir": cniConfigDir,
 			"bind_mount_dir": bindMountRoot,
 			"log_dir":        adapterLogDir,
+			"state_dir":      stateDir,
 		}
 		configBytes, err := json.Marshal(config)
 		Expect(err).NotTo(HaveOccurred())
@@ -140,6 +166,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 		Expect(os.Remove(fakeConfigFilePath)).To(Succeed())
 		Expect(os.RemoveAll(cniConfigDir)).To(Succeed())
 		Expect(os.RemoveAll(fakeLogDir)).To(Succeed())
+		Expect(os.RemoveAll(stateDir)).To(Succeed())
 		Expect(fakeProcess.Kill()).To(Succeed())
 	})
 
@@ -155,7 +182,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 				"--configFile", fakeConfigFilePath,
 				"--action", "up",
 				"--handle", "some-container-handle",
-				"--network", "garden-network-spec",
+				"--network", "10.255.0.0/24",
 			}
 
 			downCommand = exec.Command(pathToAdapter)
@@ -166,7 +193,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 				"--action", "down",
 				"--handle", "some-container-handle",
 				"--configFile", fakeConfigFilePath,
-				"--network", "garden-network-spec",
+				"--network", "10.255.0.0/24",
 			}
 		})
 
@@ -208,11 +235,54 @@ var _ = Describe("Guardian CNI adapter", func() {
 				By("checking that the fake process's network namespace has been bind-mounted into the filesystem")
 				Expect(sameFile(expectedNetNSPath, fmt.Sprintf("/proc/%d/ns/net", fakePid))).To(BeTrue())
 
+				By("checking that the results were written to stdout")
+				expectedResult := `{
+					"handle": "some-container-handle",
+					"networks": {
+						"some-net-0": { "interface": "eth0", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} },
+						"some-net-1": { "interface": "eth1", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} },
+						"some-net-2": { "interface": "eth2", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} }
+					}
+				}`
+				Expect(upSession.Out.Contents()).To(MatchJSON(expectedResult))
+
+				By("checking that the container's state was recorded")
+				stateFilePath := filepath.Join(stateDir, "some-container-handle.json")
+				stateBytes, err := ioutil.ReadFile(stateFilePath)
+				Expect(err).NotTo(HaveOccurred())
+				var state struct {
+					Handle      string
+					Pid         int
+					NetNSPath   string `json:"netns_path"`
+					Status      string
+					Attachments []struct {
+						Network   string
+						Interface string
+						Configs   []json.RawMessage
+						Result    json.RawMessage
+					}
+				}
+				Expect(json.Unmarshal(stateBytes, &state)).To(Succeed())
+				Expect(state.Handle).To(Equal(containerHandle))
+				Expect(state.Pid).To(Equal(fakePid))
+				Expect(state.NetNSPath).To(Equal(expectedNetNSPath))
+				Expect(state.Status).To(Equal("attached"))
+				Expect(state.Attachments).To(HaveLen(3))
+				for i, attachment := range state.Attachments {
+					Expect(attachment.Network).To(Equal(fmt.Sprintf("some-net-%d", i)))
+					Expect(attachment.Interface).To(Equal(fmt.Sprintf("eth%d", i)))
+					Expect(attachment.Configs).To(HaveLen(1))
+					Expect(attachment.Result).To(MatchJSON(fmt.Sprintf(`{ "interface": "eth%d", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} }`, i)))
+				}
+
 				By("calling down")
 				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
 
+				By("checking that the container's state was removed")
+				Expect(stateFilePath).NotTo(BeAnExistingFile())
+
 				By("checking that every CNI plugin in the plugin directory got called with DEL")
 				for i := 0; i < 3; i++ {
 					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
@@ -220,7 +290,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 					var pluginCallInfo fakePluginLogData
 					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
 
-					Expect(pluginCallInfo.Stdin).To(MatchJSON(getConfig(i)))
+					Expect(pluginCallInfo.Stdin).To(MatchJSON(expectedStdin(i)))
 					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
 					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_CONTAINERID", containerHandle))
 					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", fmt.Sprintf("eth%d", i)))
@@ -234,55 +304,1336 @@ var _ = Describe("Guardian CNI adapter", func() {
 			})
 		})
 
-		Context("when the network spec is missing", func() {
+		Context("when another up or down for the container holds its lock", func() {
+			var lockFile *os.File
+
 			BeforeEach(func() {
-				Expect(writeSkipConfig(0, cniConfigDir)).To(Succeed())
-				Expect(writeConfig(1, cniConfigDir)).To(Succeed())
-				Expect(writeConfig(2, cniConfigDir)).To(Succeed())
-				Expect(writeSkipConfig(3, cniConfigDir)).To(Succeed())
+				configBytes, err := ioutil.ReadFile(fakeConfigFilePath)
+				Expect(err).NotTo(HaveOccurred())
+				var config map[string]string
+				Expect(json.Unmarshal(configBytes, &config)).To(Succeed())
+				config["lock_timeout"] = "500ms"
+				configBytes, err = json.Marshal(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(fakeConfigFilePath, configBytes, 0600)).To(Succeed())
 
-				upCommand.Args = append(
-					upCommand.Args,
-					"--properties", `{}`,
-				)
+				Expect(os.MkdirAll(filepath.Join(bindMountRoot, ".locks"), 0700)).To(Succeed())
+				lockFile, err = os.Create(filepath.Join(bindMountRoot, ".locks", "some-container-handle"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(unix.Flock(int(lockFile.Fd()), unix.LOCK_EX)).To(Succeed())
 
-				downCommand.Args = append(
-					downCommand.Args,
-					"--properties", `{}`,
-				)
+				upCommand.Args = append(upCommand.Args, "--properties", `{}`)
 			})
 
-			It("calls CNI plugins for which skip_without_network is false", func() {
+			AfterEach(func() {
+				Expect(lockFile.Close()).To(Succeed())
+			})
+
+			It("gives up after the lock timeout without touching the container", func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(5))
+				Expect(upSession.Err.Contents()).To(ContainSubstring("timed out after 500ms waiting for another up, down or check of some-container-handle to finish"))
+
+				Expect(expectedNetNSPath).NotTo(BeAnExistingFile())
+				files, err := ioutil.ReadDir(fakeLogDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(BeEmpty())
+			})
+		})
+
+		Context("when a plugin fails to ADD", func() {
+			BeforeEach(func() {
+				upCommand.Env = append(upCommand.Env, "FAKE_ADD_FAILURE=no addresses left", "FAKE_FAILING_PLUGIN=plugin-2")
+			})
+
+			It("rolls back every network that was added and removes the mount", func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(4))
+				Expect(upSession.Out.Contents()).To(BeEmpty())
+				Expect(upSession.Err.Contents()).To(ContainSubstring("cni up failed: add network failed: no addresses left"))
+				Expect(lastLine(upSession.Err.Contents())).To(MatchJSON(`{
+					"kind": "plugin",
+					"msg": "up failed: cni up failed: add network failed: no addresses left",
+					"network": "some-net-2",
+					"plugin": "plugin-2",
+					"cni_error": { "code": 100, "msg": "no addresses left" }
+				}`))
+
+				By("checking that every attempted network got called with DEL")
+				for i := 0; i < 3; i++ {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", fmt.Sprintf("eth%d", i)))
+				}
+
+				By("checking that the bind-mounted namespace has been removed")
+				Expect(expectedNetNSPath).NotTo(BeAnExistingFile())
+
+				By("checking that no state was left behind")
+				Expect(filepath.Join(stateDir, "some-container-handle.json")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when a plugin hangs on ADD", func() {
+			var setPluginTimeout func(timeout string)
+
+			BeforeEach(func() {
+				setPluginTimeout = func(timeout string) {
+					configBytes, err := ioutil.ReadFile(fakeConfigFilePath)
+					Expect(err).NotTo(HaveOccurred())
+					var config map[string]string
+					Expect(json.Unmarshal(configBytes, &config)).To(Succeed())
+					config["plugin_timeout"] = timeout
+					configBytes, err = json.Marshal(config)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ioutil.WriteFile(fakeConfigFilePath, configBytes, 0600)).To(Succeed())
+				}
+
+				upCommand.Env = append(upCommand.Env, "FAKE_ADD_HANG=true", "FAKE_FAILING_PLUGIN=plugin-1")
+			})
+
+			childIsRunning := func() bool {
+				pidBytes, err := ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-1.child-pid"))
+				Expect(err).NotTo(HaveOccurred())
+				statBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%s/stat", string(pidBytes)))
+				return err == nil && !strings.Contains(string(statBytes), ") Z ")
+			}
+
+			It("kills the plugin and everything it started after the timeout, then rolls back", func() {
+				setPluginTimeout("2s")
+
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(5))
+				Expect(upSession.Err.Contents()).To(ContainSubstring("cni up failed: add network failed: ADD timed out after 2s for name=some-net-1, type=plugin-1"))
+				Expect(lastLine(upSession.Err.Contents())).To(MatchJSON(`{
+					"kind": "timeout",
+					"msg": "up failed: cni up failed: add network failed: ADD timed out after 2s for name=some-net-1, type=plugin-1",
+					"network": "some-net-1",
+					"plugin": "plugin-1"
+				}`))
+
+				Eventually(childIsRunning).Should(BeFalse())
+
+				By("checking that every attempted network got called with DEL")
+				for i := 0; i < 2; i++ {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+				}
+				Expect(filepath.Join(fakeLogDir, "plugin-2.log")).NotTo(BeAnExistingFile())
+
+				Expect(expectedNetNSPath).NotTo(BeAnExistingFile())
+			})
+
+			Context("when the hook is terminated", func() {
+				It("kills the plugin and everything it started", func() {
+					setPluginTimeout("1h")
+
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(filepath.Join(fakeLogDir, "plugin-1.child-pid"), DEFAULT_TIMEOUT).Should(BeAnExistingFile())
+
+					upSession.Terminate()
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(1))
+					Expect(upSession.Err.Contents()).To(ContainSubstring("ADD cancelled for name=some-net-1, type=plugin-1: context canceled"))
+					Eventually(childIsRunning).Should(BeFalse())
+				})
+			})
+
+			Context("when the network declares its own timeout", func() {
+				BeforeEach(func() {
+					config := `{ "cniVersion": "0.1.0", "name": "some-net-1", "type": "plugin-1", "timeout": "1s" }`
+					Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "10-plugin-1.conf"), []byte(config), 0600)).To(Succeed())
+				})
+
+				It("overrides the configured timeout", func() {
+					setPluginTimeout("1h")
+
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(5))
+					Expect(upSession.Err.Contents()).To(ContainSubstring("ADD timed out after 1s for name=some-net-1, type=plugin-1"))
+
+					Eventually(childIsRunning).Should(BeFalse())
+				})
+			})
+		})
+
+		Context("when a plugin with a retry policy fails transiently", func() {
+			BeforeEach(func() {
+				config := `{
+					"cniVersion": "0.1.0",
+					"name": "some-net-1",
+					"type": "plugin-1",
+					"retry": { "max_attempts": 3, "backoff": "10ms", "retryable_codes": [11] }
+				}`
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "10-plugin-1.conf"), []byte(config), 0600)).To(Succeed())
+
+				upCommand.Env = append(upCommand.Env, "FAKE_ADD_FAILURE=ipam backend busy", "FAKE_FAILING_PLUGIN=plugin-1", "FAKE_FAILURE_COUNT=2")
+			})
+
+			calls := func() []string {
+				callBytes, err := ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-1.calls"))
+				Expect(err).NotTo(HaveOccurred())
+				return strings.Fields(string(callBytes))
+			}
+
+			It("retries ADD, deleting after each failed attempt", func() {
+				upCommand.Env = append(upCommand.Env, "FAKE_FAILURE_CODE=11")
+
 				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				Expect(upSession.Err.Contents()).To(ContainSubstring("ADD for name=some-net-1 failed on attempt 1 of 3"))
+				Expect(upSession.Err.Contents()).To(ContainSubstring("ADD for name=some-net-1 failed on attempt 2 of 3"))
 
-				By("checking plugins with skip_without_network were not called")
-				Expect(filepath.Join(fakeLogDir, "plugin-0.log")).NotTo(BeAnExistingFile())
-				Expect(filepath.Join(fakeLogDir, "plugin-3.log")).NotTo(BeAnExistingFile())
+				Expect(calls()).To(Equal([]string{"ADD", "DEL", "ADD", "DEL", "ADD"}))
+			})
 
-				By("checking that other CNI plugins got called with ADD")
-				for i := 1; i < 3; i++ {
+			Context("when the failure's code is not retryable", func() {
+				It("fails on the first attempt", func() {
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(4))
+					Expect(upSession.Err.Contents()).To(ContainSubstring("cni up failed: add network failed: ipam backend busy"))
+
+					By("checking that the only DEL was the rollback's")
+					Expect(calls()).To(Equal([]string{"ADD", "DEL"}))
+				})
+			})
+		})
+
+		Context("when a plugin fails to DEL", func() {
+			BeforeEach(func() {
+				downCommand.Env = append(downCommand.Env, "FAKE_DEL_FAILURE=ipam backend unavailable", "FAKE_FAILING_PLUGIN=plugin-1")
+			})
+
+			It("still tears down every other network and removes the mount", func() {
+				By("calling up")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("calling down")
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(4))
+				Expect(downSession.Err.Contents()).To(ContainSubstring("del network failed for name=some-net-1, type=plugin-1: ipam backend unavailable"))
+
+				By("checking that the other plugins got called with DEL")
+				for _, i := range []int{0, 2} {
 					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
 					Expect(err).NotTo(HaveOccurred())
 					var pluginCallInfo fakePluginLogData
 					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+				}
 
-					Expect(pluginCallInfo.Stdin).To(MatchJSON(getConfig(i)))
-					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "ADD"))
+				By("checking that the bind-mounted namespace has been removed")
+				Expect(expectedNetNSPath).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when a container is orphaned", func() {
+			var gcCommand func(args ...string) *exec.Cmd
+
+			BeforeEach(func() {
+				gcCommand = func(args ...string) *exec.Cmd {
+					cmd := exec.Command(pathToAdapter)
+					cmd.Env = []string{"FAKE_LOG_DIR=" + fakeLogDir}
+					cmd.Args = append([]string{
+						pathToAdapter,
+						"--configFile", fakeConfigFilePath,
+						"--action", "gc",
+						"--minAge", "0s",
+					}, args...)
+					return cmd
+				}
+			})
+
+			It("reports it on a dry run, then tears it down", func() {
+				By("calling up")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				stateFilePath := filepath.Join(stateDir, "some-container-handle.json")
+
+				By("calling gc while the container is live")
+				gcSession, err := gexec.Start(gcCommand("--liveHandles", "another-handle, some-container-handle,"), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(gcSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				Expect(gcSession.Out.Contents()).To(MatchJSON(`{ "dry_run": false, "orphans": [], "collected": [] }`))
+				Expect(expectedNetNSPath).To(BeAnExistingFile())
+
+				By("dry running gc with no live containers")
+				cmd := gcCommand("--dryRun")
+				cmd.Stdin = strings.NewReader(`[]`)
+				gcSession, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(gcSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				Expect(gcSession.Out.Contents()).To(MatchJSON(`{ "dry_run": true, "orphans": ["some-container-handle"], "collected": [] }`))
+				Expect(expectedNetNSPath).To(BeAnExistingFile())
+				Expect(stateFilePath).To(BeAnExistingFile())
+
+				By("calling gc with no live containers")
+				cmd = gcCommand()
+				cmd.Stdin = strings.NewReader(`[]`)
+				gcSession, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(gcSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				Expect(gcSession.Out.Contents()).To(MatchJSON(`{ "dry_run": false, "orphans": ["some-container-handle"], "collected": ["some-container-handle"] }`))
+
+				By("checking that every CNI plugin got called with DEL")
+				for i := 0; i < 3; i++ {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
 					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_CONTAINERID", containerHandle))
 					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", fmt.Sprintf("eth%d", i)))
-					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_PATH", cniPluginDir))
-					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_NETNS", expectedNetNSPath))
-					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_ARGS", ""))
 				}
 
-				By("logging the plugin output / result from up")
-				logContents, err := ioutil.ReadFile(adapterLogFilePath)
+				By("checking that the bind mount and state have been removed")
+				Expect(expectedNetNSPath).NotTo(BeAnExistingFile())
+				Expect(stateFilePath).NotTo(BeAnExistingFile())
+			})
+
+			Context("when the live handles are not given", func() {
+				It("refuses to guess", func() {
+					cmd := gcCommand()
+					cmd.Stdin = strings.NewReader("")
+					gcSession, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(gcSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+					Expect(gcSession.Err.Contents()).To(ContainSubstring("gc requires the live handles, via --liveHandles or a JSON list on stdin"))
+				})
+
+				It("refuses a flag that lists no handles", func() {
+					gcSession, err := gexec.Start(gcCommand("--liveHandles", " , "), GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(gcSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+					Expect(gcSession.Err.Contents()).To(ContainSubstring("--liveHandles lists no handles"))
+				})
+			})
+		})
+
+		Context("when log rotation and retention are configured", func() {
+			BeforeEach(func() {
+				configBytes, err := ioutil.ReadFile(fakeConfigFilePath)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(logContents).To(ContainSubstring("up result for name=some-net-1, type=plugin-1: "))
-				Expect(logContents).To(ContainSubstring("up result for name=some-net-2, type=plugin-2: "))
-				Expect(logContents).To(ContainSubstring("169.254.1.2"))
+				var config map[string]interface{}
+				Expect(json.Unmarshal(configBytes, &config)).To(Succeed())
+				config["log_max_size"] = "1KB"
+				config["log_max_backups"] = 1
+				config["log_retention"] = "1ms"
+				config["log_archive"] = true
+				configBytes, err = json.Marshal(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(fakeConfigFilePath, configBytes, 0600)).To(Succeed())
+			})
+
+			It("rotates the container's log, and archives it once the container is down", func() {
+				By("calling up and down")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("checking that the log was rotated, but kept while it was being written")
+				Expect(adapterLogFilePath).To(BeAnExistingFile())
+				Expect(adapterLogFilePath + ".1").To(BeAnExistingFile())
+				Expect(adapterLogFilePath + ".2").NotTo(BeAnExistingFile())
+
+				By("calling gc, which sweeps the logs of containers that are down")
+				gcCommand := exec.Command(pathToAdapter, "--configFile", fakeConfigFilePath, "--action", "gc", "--liveHandles", "another-handle")
+				gcSession, err := gexec.Start(gcCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(gcSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("checking that the container's logs were archived")
+				logDir := filepath.Dir(adapterLogFilePath)
+				Expect(adapterLogFilePath).NotTo(BeAnExistingFile())
+				Expect(adapterLogFilePath + ".1").NotTo(BeAnExistingFile())
+				Expect(filepath.Join(logDir, "gc.log")).To(BeAnExistingFile())
+
+				archived, err := filepath.Glob(filepath.Join(logDir, "archive", "some-container-handle.log*.gz"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(archived).To(HaveLen(2))
+			})
+		})
+
+		Context("when tracing to a file", func() {
+			var traceFilePath string
+
+			BeforeEach(func() {
+				traceFilePath = filepath.Join(stateDir, "traces.json")
+
+				configBytes, err := ioutil.ReadFile(fakeConfigFilePath)
+				Expect(err).NotTo(HaveOccurred())
+				var config map[string]interface{}
+				Expect(json.Unmarshal(configBytes, &config)).To(Succeed())
+				config["trace_file"] = traceFilePath
+				configBytes, err = json.Marshal(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(fakeConfigFilePath, configBytes, 0600)).To(Succeed())
+			})
+
+			It("exports spans for the action, the mount, the config loading and each plugin, under Garden's span", func() {
+				upCommand.Args = append(upCommand.Args, "--traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				contents, err := ioutil.ReadFile(traceFilePath)
+				Expect(err).NotTo(HaveOccurred())
+				var export struct {
+					ResourceSpans []struct {
+						ScopeSpans []struct {
+							Spans []struct {
+								TraceID      string `json:"traceId"`
+								SpanID       string `json:"spanId"`
+								ParentSpanID string `json:"parentSpanId"`
+								Name         string `json:"name"`
+							}
+						}
+					}
+				}
+				Expect(json.Unmarshal(contents, &export)).To(Succeed())
+
+				spans := export.ResourceSpans[0].ScopeSpans[0].Spans
+				var names []string
+				for _, span := range spans {
+					Expect(span.TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+					names = append(names, span.Name)
+				}
+				Expect(names).To(Equal([]string{"IdempotentlyMount", "ensureInitialized", "AddNetwork", "AddNetwork", "AddNetwork", "up"}))
+
+				root := spans[len(spans)-1]
+				Expect(root.ParentSpanID).To(Equal("00f067aa0ba902b7"))
+				for _, span := range spans[:len(spans)-1] {
+					Expect(span.ParentSpanID).To(Equal(root.SpanID))
+				}
+			})
+		})
+
+		Context("when listing and inspecting containers", func() {
+			var adapterCommand func(args ...string) *exec.Cmd
+
+			BeforeEach(func() {
+				adapterCommand = func(args ...string) *exec.Cmd {
+					cmd := exec.Command(pathToAdapter)
+					cmd.Args = append([]string{pathToAdapter, "--configFile", fakeConfigFilePath}, args...)
+					return cmd
+				}
+			})
+
+			It("reports each container's mount and networks as a table or JSON", func() {
+				By("calling up")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("listing the containers as a table")
+				listSession, err := gexec.Start(adapterCommand("--action", "list"), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(listSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				lines := strings.Split(strings.TrimSpace(string(listSession.Out.Contents())), "\n")
+				Expect(lines).To(HaveLen(4))
+				Expect(strings.Fields(lines[0])).To(Equal([]string{"HANDLE", "STATUS", "MOUNTED", "NETNS", "NETWORK", "INTERFACE", "IPS"}))
+				for i := 0; i < 3; i++ {
+					Expect(strings.Fields(lines[i+1])).To(Equal([]string{
+						containerHandle, "attached", "yes", expectedNetNSPath,
+						fmt.Sprintf("some-net-%d", i), fmt.Sprintf("eth%d", i), "169.254.1.2/24",
+					}))
+				}
+
+				By("inspecting the container as JSON")
+				inspectSession, err := gexec.Start(adapterCommand("--action", "inspect", "--handle", containerHandle, "--format", "json"), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(inspectSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				Expect(inspectSession.Out.Contents()).To(MatchJSON(fmt.Sprintf(`{
+					"handle": "some-container-handle",
+					"pid": %d,
+					"netns_path": %q,
+					"mounted": true,
+					"status": "attached",
+					"networks": [
+						{ "network": "some-net-0", "interface": "eth0", "ips": [{ "version": "4", "address": "169.254.1.2/24" }] },
+						{ "network": "some-net-1", "interface": "eth1", "ips": [{ "version": "4", "address": "169.254.1.2/24" }] },
+						{ "network": "some-net-2", "interface": "eth2", "ips": [{ "version": "4", "address": "169.254.1.2/24" }] }
+					]
+				}`, fakePid, expectedNetNSPath)))
+
+				By("calling down")
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("listing no containers as JSON")
+				listSession, err = gexec.Start(adapterCommand("--action", "list", "--format", "json"), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(listSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				Expect(listSession.Out.Contents()).To(MatchJSON(`[]`))
+
+				By("inspecting the removed container")
+				inspectSession, err = gexec.Start(adapterCommand("--action", "inspect", "--handle", containerHandle), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(inspectSession, DEFAULT_TIMEOUT).Should(gexec.Exit(1))
+				Expect(inspectSession.Err.Contents()).To(ContainSubstring("no state recorded for some-container-handle"))
+			})
+
+			It("lists a container whose state cannot be loaded with the error, rather than failing", func() {
+				Expect(os.MkdirAll(stateDir, 0700)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(stateDir, "broken-handle.json"), []byte("%%%"), 0600)).To(Succeed())
+
+				listSession, err := gexec.Start(adapterCommand("--action", "list", "--format", "json"), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(listSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				var infos []map[string]interface{}
+				Expect(json.Unmarshal(listSession.Out.Contents(), &infos)).To(Succeed())
+				Expect(infos).To(HaveLen(1))
+				Expect(infos[0]).To(HaveKeyWithValue("handle", "broken-handle"))
+				Expect(infos[0]).To(HaveKeyWithValue("error", HavePrefix("failed loading state: parsing state file failed")))
+			})
+		})
+
+		Context("when plugins are searched for in several directories and verified", func() {
+			var (
+				emptyPluginDir string
+				config         map[string]interface{}
+			)
+
+			writeAdapterConfig := func() {
+				configBytes, err := json.Marshal(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(fakeConfigFilePath, configBytes, 0600)).To(Succeed())
+			}
+
+			BeforeEach(func() {
+				var err error
+				emptyPluginDir, err = ioutil.TempDir("", "cni-plugin-empty-")
+				Expect(err).NotTo(HaveOccurred())
+
+				pluginBytes, err := ioutil.ReadFile(filepath.Join(cniPluginDir, "plugin-0"))
+				Expect(err).NotTo(HaveOccurred())
+				sum := sha256.Sum256(pluginBytes)
+
+				configBytes, err := ioutil.ReadFile(fakeConfigFilePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(configBytes, &config)).To(Succeed())
+				config["cni_plugin_dir"] = []string{emptyPluginDir, cniPluginDir}
+				config["plugin_checksums"] = map[string]string{
+					"plugin-0": hex.EncodeToString(sum[:]),
+					"plugin-1": hex.EncodeToString(sum[:]),
+					"plugin-2": hex.EncodeToString(sum[:]),
+				}
+				writeAdapterConfig()
+			})
+
+			AfterEach(func() {
+				Expect(os.RemoveAll(emptyPluginDir)).To(Succeed())
+			})
+
+			It("runs the verified plugins, passing every directory as the CNI path", func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-0.log"))
+				Expect(err).NotTo(HaveOccurred())
+				var pluginCallInfo fakePluginLogData
+				Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+				Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_PATH", emptyPluginDir+":"+cniPluginDir))
+
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+			})
+
+			Context("when a plugin's checksum does not match", func() {
+				BeforeEach(func() {
+					config["plugin_checksums"].(map[string]string)["plugin-1"] = strings.Repeat("0", 64)
+					writeAdapterConfig()
+				})
+
+				It("refuses to run it, failing with a config error", func() {
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+					Expect(lastLine(upSession.Err.Contents())).To(ContainSubstring(`plugin plugin-1 at ` + filepath.Join(cniPluginDir, "plugin-1") + ` has checksum`))
+					Expect(filepath.Join(fakeLogDir, "plugin-1.log")).NotTo(BeAnExistingFile())
+				})
+			})
+		})
+
+		Context("when the config directory has invalid configs", func() {
+			var validateCommand *exec.Cmd
+
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "30-plugin-1.conf"), []byte(getConfig(1)), 0600)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "40-missing.conf"), []byte(`{ "cniVersion": "0.1.0", "name": "some-net-4", "type": "missing" }`), 0600)).To(Succeed())
+
+				validateCommand = exec.Command(pathToAdapter)
+				validateCommand.Args = []string{
+					pathToAdapter,
+					"--action", "validate-config",
+					"--configFile", fakeConfigFilePath,
+				}
+			})
+
+			It("reports every problem found by validate-config", func() {
+				validateSession, err := gexec.Start(validateCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(validateSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+
+				lines := strings.Split(strings.TrimSpace(string(validateSession.Out.Contents())), "\n")
+				Expect(lines).To(HaveLen(6))
+				Expect(strings.Fields(lines[0])).To(Equal([]string{"PATH", "NETWORK", "PRIORITY", "INTERFACE", "STATUS"}))
+				Expect(strings.Fields(lines[1])).To(Equal([]string{filepath.Join(cniConfigDir, "0-plugin-0.conf"), "some-net-0", "0", "eth0", "ok"}))
+				Expect(strings.Fields(lines[2])).To(Equal([]string{filepath.Join(cniConfigDir, "10-plugin-1.conf"), "some-net-1", "0", "eth1", "ok"}))
+				Expect(strings.Fields(lines[3])).To(Equal([]string{filepath.Join(cniConfigDir, "20-plugin-2.conf"), "some-net-2", "0", "eth2", "ok"}))
+				Expect(lines[4]).To(ContainSubstring("network some-net-1 is already configured by " + filepath.Join(cniConfigDir, "10-plugin-1.conf")))
+				Expect(lines[5]).To(ContainSubstring("plugin missing not found in " + cniPluginDir))
+
+				Expect(lastLine(validateSession.Err.Contents())).To(MatchJSON(fmt.Sprintf(`{
+					"kind": "config",
+					"msg": "validate-config failed: 2 invalid configs in %s"
+				}`, cniConfigDir)))
+			})
+
+			It("reports the configs as JSON", func() {
+				validateCommand.Args = append(validateCommand.Args, "--format", "json")
+				validateSession, err := gexec.Start(validateCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(validateSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+
+				var report struct {
+					Configs []struct {
+						Path     string   `json:"path"`
+						Network  string   `json:"network"`
+						Problems []string `json:"problems"`
+					} `json:"configs"`
+				}
+				Expect(json.Unmarshal(validateSession.Out.Contents(), &report)).To(Succeed())
+				Expect(report.Configs).To(HaveLen(5))
+				Expect(report.Configs[4].Network).To(Equal("some-net-4"))
+				Expect(report.Configs[4].Problems).To(Equal([]string{"plugin missing not found in " + cniPluginDir}))
+			})
+
+			It("fails up with a config error", func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+				Expect(upSession.Err.Contents()).To(ContainSubstring("network some-net-1 is already configured by"))
+			})
+
+			Context("when invalid configs are skipped", func() {
+				BeforeEach(func() {
+					// a missing plugin is only found by validate-config, or by
+					// the containers that attach to its network
+					Expect(os.Remove(filepath.Join(cniConfigDir, "40-missing.conf"))).To(Succeed())
+
+					configBytes, err := ioutil.ReadFile(fakeConfigFilePath)
+					Expect(err).NotTo(HaveOccurred())
+					var config map[string]interface{}
+					Expect(json.Unmarshal(configBytes, &config)).To(Succeed())
+					config["skip_invalid_configs"] = true
+					configBytes, err = json.Marshal(config)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ioutil.WriteFile(fakeConfigFilePath, configBytes, 0600)).To(Succeed())
+				})
+
+				It("attaches the valid networks and logs the skipped ones", func() {
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+					Expect(upSession.Err.Contents()).To(ContainSubstring(`"message":"skipping invalid network config"`))
+					Expect(upSession.Err.Contents()).To(ContainSubstring(filepath.Join(cniConfigDir, "30-plugin-1.conf")))
+
+					downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				})
+			})
+		})
+
+		Context("when the config directory changes between up and down", func() {
+			BeforeEach(func() {
+				upCommand.Args = append(
+					upCommand.Args,
+					"--properties", `{ "some-key": "some-value", "some-other-key": "some-other-value" }`,
+				)
+
+				downCommand.Args = append(
+					downCommand.Args,
+					"--properties", `{ "some-key": "some-value", "some-other-key": "some-other-value" }`,
+				)
+			})
+
+			It("replays DEL against the configs used at up time", func() {
+				By("calling up")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("removing a config and changing another")
+				Expect(os.Remove(filepath.Join(cniConfigDir, "10-plugin-1.conf"))).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "20-plugin-2.conf"), []byte(getConfig(3)), 0600)).To(Succeed())
+
+				By("calling down")
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("checking that the original plugins got called with DEL on their original interfaces")
+				for i := 0; i < 3; i++ {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+
+					Expect(pluginCallInfo.Stdin).To(MatchJSON(expectedStdin(i)))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", fmt.Sprintf("eth%d", i)))
+				}
+
+				By("checking that the newly configured plugin was not called")
+				Expect(filepath.Join(fakeLogDir, "plugin-3.log")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when the network spec requests a static address", func() {
+			BeforeEach(func() {
+				config := `{ "cniVersion": "0.1.0", "name": "some-net-0", "type": "plugin-0", "ipam": { "type": "host-local" } }`
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "0-plugin-0.conf"), []byte(config), 0600)).To(Succeed())
+
+				upCommand.Args = append(upCommand.Args, "--network", "10.255.0.5/24")
+			})
+
+			It("passes the subnet and address hints to the primary network's plugins only", func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-0.log"))
+				Expect(err).NotTo(HaveOccurred())
+				var pluginCallInfo fakePluginLogData
+				Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+				Expect(pluginCallInfo.Stdin).To(MatchJSON(`{
+					"cniVersion": "0.1.0",
+					"name": "some-net-0",
+					"type": "plugin-0",
+					"ipam": { "type": "host-local", "subnet": "10.255.0.0/24" },
+					"args": { "cni": { "ips": ["10.255.0.5"] } }
+				}`))
+
+				logFileContents, err = ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-1.log"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+				Expect(pluginCallInfo.Stdin).To(MatchJSON(`{
+					"cniVersion": "0.1.0",
+					"name": "some-net-1",
+					"type": "plugin-1"
+				}`))
+			})
+		})
+
+		Context("when the container requests port mappings, bandwidth and a MAC", func() {
+			BeforeEach(func() {
+				config := `{
+					"cniVersion": "0.1.0",
+					"name": "some-net-0",
+					"type": "plugin-0",
+					"capabilities": { "portMappings": true, "bandwidth": true, "ips": false }
+				}`
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "0-plugin-0.conf"), []byte(config), 0600)).To(Succeed())
+
+				properties := `{
+					"port_mappings": "[{\"host_port\": 8080, \"container_port\": 80}]",
+					"bandwidth": { "ingress_rate": 1000, "ingress_burst": 2000 },
+					"mac": "0A:00:00:00:00:01"
+				}`
+				upCommand.Args = append(upCommand.Args, "--properties", properties)
+				downCommand.Args = append(downCommand.Args, "--properties", properties)
+			})
+
+			It("passes runtimeConfig to plugins with the capability and the MAC to the primary network only", func() {
+				By("calling up")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				expectedPlugin0Stdin := `{
+					"cniVersion": "0.1.0",
+					"name": "some-net-0",
+					"type": "plugin-0",
+					"capabilities": { "portMappings": true, "bandwidth": true, "ips": false },
+					"runtimeConfig": {
+						"portMappings": [{ "hostPort": 8080, "containerPort": 80, "protocol": "tcp" }],
+						"bandwidth": { "ingressRate": 1000, "ingressBurst": 2000 }
+					},
+					"network": { "properties": {
+						"port_mappings": "[{\"host_port\": 8080, \"container_port\": 80}]",
+						"bandwidth": { "ingress_rate": 1000, "ingress_burst": 2000 },
+						"mac": "0A:00:00:00:00:01"
+					} }
+				}`
+
+				for _, command := range []string{"ADD", "DEL"} {
+					if command == "DEL" {
+						By("calling down")
+						downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+						Expect(err).NotTo(HaveOccurred())
+						Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+					}
+
+					for i := 0; i < 3; i++ {
+						logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+						Expect(err).NotTo(HaveOccurred())
+						var pluginCallInfo fakePluginLogData
+						Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+
+						Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", command))
+						if i == 0 {
+							Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_ARGS", "IgnoreUnknown=1;MAC=0a:00:00:00:00:01"))
+							Expect(pluginCallInfo.Stdin).To(MatchJSON(expectedPlugin0Stdin))
+						} else {
+							Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_ARGS", ""))
+							Expect(pluginCallInfo.Stdin).NotTo(ContainSubstring("runtimeConfig"))
+						}
+					}
+				}
+			})
+
+			Context("when a port mapping is invalid", func() {
+				BeforeEach(func() {
+					upCommand.Args = append(upCommand.Args, "--properties", `{ "port_mappings": [{ "host_port": 0, "container_port": 80 }] }`)
+				})
+
+				It("fails without calling any plugin", func() {
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+					Expect(upSession.Err.Contents()).To(ContainSubstring(`invalid \"port_mappings\" property: ports must be between 1 and 65535`))
+
+					files, err := ioutil.ReadDir(fakeLogDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(files).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the container selects networks by name", func() {
+			BeforeEach(func() {
+				upCommand.Args = append(upCommand.Args, "--properties", `{ "networks": "some-net-0,some-net-2" }`)
+				downCommand.Args = append(downCommand.Args, "--properties", `{ "networks": "some-net-0,some-net-2" }`)
+			})
+
+			It("joins and tears down only the named networks", func() {
+				By("calling up")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				Expect(filepath.Join(fakeLogDir, "plugin-1.log")).NotTo(BeAnExistingFile())
+
+				By("keeping each network's interface, whichever others are joined")
+				var upResult map[string]interface{}
+				Expect(json.Unmarshal(upSession.Out.Contents(), &upResult)).To(Succeed())
+				Expect(upResult["networks"]).To(HaveKeyWithValue("some-net-0", HaveKeyWithValue("interface", "eth0")))
+				Expect(upResult["networks"]).To(HaveKeyWithValue("some-net-2", HaveKeyWithValue("interface", "eth2")))
+				Expect(upSession.Err.Contents()).To(ContainSubstring(`"networks":["some-net-0=eth0","some-net-1=eth1","some-net-2=eth2"]`))
+
+				By("calling down")
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+				Expect(filepath.Join(fakeLogDir, "plugin-1.log")).NotTo(BeAnExistingFile())
+
+				for _, i := range []int{0, 2} {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+				}
+			})
+
+			Context("when a named network does not exist", func() {
+				BeforeEach(func() {
+					upCommand.Args = append(upCommand.Args, "--properties", `{ "networks": "some-missing-net" }`)
+				})
+
+				It("fails without calling any plugin", func() {
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+					Expect(upSession.Err.Contents()).To(ContainSubstring("requested networks are not configured: some-missing-net"))
+
+					files, err := ioutil.ReadDir(fakeLogDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(files).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the networks declare their priorities", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "20-plugin-2.conf"), []byte(`{
+					"cniVersion": "0.1.0",
+					"name": "some-net-2",
+					"type": "plugin-2",
+					"priority": -1
+				}`), 0600)).To(Succeed())
+			})
+
+			It("attaches them in priority order and logs the resolved order", func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				var upResult map[string]interface{}
+				Expect(json.Unmarshal(upSession.Out.Contents(), &upResult)).To(Succeed())
+				Expect(upResult["networks"]).To(HaveKeyWithValue("some-net-2", HaveKeyWithValue("interface", "eth0")))
+				Expect(upResult["networks"]).To(HaveKeyWithValue("some-net-0", HaveKeyWithValue("interface", "eth1")))
+				Expect(upResult["networks"]).To(HaveKeyWithValue("some-net-1", HaveKeyWithValue("interface", "eth2")))
+
+				Expect(upSession.Err.Contents()).To(ContainSubstring(`"message":"resolved network order","networks":["some-net-2=eth0","some-net-0=eth1","some-net-1=eth2"]`))
+
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+			})
+		})
+
+		Context("when the networks declare their interfaces", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "0-plugin-0.conf"), []byte(`{
+					"cniVersion": "0.1.0",
+					"name": "some-net-0",
+					"type": "plugin-0",
+					"interface": "{{.Name}}"
+				}`), 0600)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "20-plugin-2.conf"), []byte(`{
+					"cniVersion": "0.1.0",
+					"name": "some-net-2",
+					"type": "plugin-2",
+					"interface": "overlay"
+				}`), 0600)).To(Succeed())
+
+				upCommand.Args = append(upCommand.Args, "--properties", `{ "networks": "some-net-0,some-net-2" }`)
+				downCommand.Args = append(downCommand.Args, "--properties", `{ "networks": "some-net-0,some-net-2" }`)
+			})
+
+			It("adds and deletes each network on its declared interface", func() {
+				By("calling up")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				var upResult map[string]interface{}
+				Expect(json.Unmarshal(upSession.Out.Contents(), &upResult)).To(Succeed())
+				Expect(upResult["networks"]).To(HaveKeyWithValue("some-net-0", HaveKeyWithValue("interface", "some-net-0")))
+				Expect(upResult["networks"]).To(HaveKeyWithValue("some-net-2", HaveKeyWithValue("interface", "overlay")))
+
+				By("changing the declared interfaces before calling down")
+				Expect(writeConfig(0, cniConfigDir)).To(Succeed())
+				Expect(writeConfig(2, cniConfigDir)).To(Succeed())
+
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("checking that DEL targeted the interfaces recorded at up time")
+				for i, ifName := range map[int]string{0: "some-net-0", 2: "overlay"} {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", ifName))
+				}
+			})
+
+			Context("when two networks declare the same interface", func() {
+				BeforeEach(func() {
+					Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "20-plugin-2.conf"), []byte(`{
+						"cniVersion": "0.1.0",
+						"name": "some-net-2",
+						"type": "plugin-2",
+						"interface": "some-net-0"
+					}`), 0600)).To(Succeed())
+				})
+
+				It("fails without calling any plugin", func() {
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+					Expect(upSession.Err.Contents()).To(ContainSubstring("interface some-net-0 is already claimed by some-net-0"))
+
+					files, err := ioutil.ReadDir(fakeLogDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(files).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when a network config list is present", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(cniConfigDir, "20-plugin-2.conf"))).To(Succeed())
+				confList := `{
+					"cniVersion": "0.1.0",
+					"name": "some-chain",
+					"plugins": [
+						{ "type": "plugin-2" },
+						{ "type": "plugin-3" }
+					]
+				}`
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "20-chain.conflist"), []byte(confList), 0600)).To(Succeed())
+
+				upCommand.Args = append(upCommand.Args, "--properties", `{}`)
+				downCommand.Args = append(downCommand.Args, "--properties", `{}`)
+			})
+
+			It("chains the plugins, passing each plugin's result to the next", func() {
+				By("calling up")
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("checking that every plugin in the list got called with ADD on the same interface")
+				for _, name := range []string{"plugin-2", "plugin-3"} {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, name+".log"))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "ADD"))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", "eth2"))
+				}
+
+				By("checking that the second plugin received the first plugin's result")
+				logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-3.log"))
+				Expect(err).NotTo(HaveOccurred())
+				var pluginCallInfo fakePluginLogData
+				Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+				Expect(pluginCallInfo.Stdin).To(MatchJSON(`{
+					"cniVersion": "0.1.0",
+					"name": "some-chain",
+					"type": "plugin-3",
+					"prevResult": {
+						"ip4": { "ip": "169.254.1.2/24" },
+						"dns": {}
+					}
+				}`))
+
+				By("calling down")
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("checking that every plugin in the list got called with DEL")
+				for _, name := range []string{"plugin-2", "plugin-3"} {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, name+".log"))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", "eth2"))
+				}
+			})
+		})
+
+		Context("when the networks use newer spec versions", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "0-plugin-0.conf"), []byte(`{
+					"cniVersion": "0.3.1",
+					"name": "some-net-0",
+					"type": "plugin-0"
+				}`), 0600)).To(Succeed())
+				Expect(os.Remove(filepath.Join(cniConfigDir, "20-plugin-2.conf"))).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "20-chain.conflist"), []byte(`{
+					"cniVersion": "1.0.0",
+					"name": "some-chain",
+					"plugins": [
+						{ "type": "plugin-2" },
+						{ "type": "plugin-3" }
+					]
+				}`), 0600)).To(Succeed())
+			})
+
+			It("converts every result into the same model, and chains results as printed", func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				Expect(upSession.Out.Contents()).To(MatchJSON(`{
+					"handle": "some-container-handle",
+					"networks": {
+						"some-net-0": { "interface": "eth0", "mac": "ee:ee:00:00:00:02", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} },
+						"some-net-1": { "interface": "eth1", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} },
+						"some-chain": { "interface": "eth2", "mac": "ee:ee:00:00:00:02", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} }
+					}
+				}`))
+
+				By("checking that the second plugin of the chain received the first plugin's result")
+				logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-3.log"))
+				Expect(err).NotTo(HaveOccurred())
+				var pluginCallInfo fakePluginLogData
+				Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+				var stdin map[string]interface{}
+				Expect(json.Unmarshal([]byte(pluginCallInfo.Stdin), &stdin)).To(Succeed())
+				prevResult, err := json.Marshal(stdin["prevResult"])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(prevResult).To(MatchJSON(fmt.Sprintf(`{
+					"cniVersion": "1.0.0",
+					"interfaces": [
+						{ "name": "veth-host", "mac": "ee:ee:00:00:00:01" },
+						{ "name": "eth2", "mac": "ee:ee:00:00:00:02", "sandbox": %q }
+					],
+					"ips": [{ "interface": 1, "address": "169.254.1.2/24" }],
+					"dns": {}
+				}`, expectedNetNSPath)))
+
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("checking that DEL received the chain's result as of 0.4.0, and not before")
+				logFileContents, err = ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-2.log"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+				Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+				stdin = nil
+				Expect(json.Unmarshal([]byte(pluginCallInfo.Stdin), &stdin)).To(Succeed())
+				prevResult, err = json.Marshal(stdin["prevResult"])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(prevResult).To(MatchJSON(fmt.Sprintf(`{
+					"cniVersion": "1.0.0",
+					"interfaces": [
+						{ "name": "veth-host", "mac": "ee:ee:00:00:00:01" },
+						{ "name": "eth2", "mac": "ee:ee:00:00:00:02", "sandbox": %q }
+					],
+					"ips": [{ "interface": 1, "address": "169.254.1.2/24" }],
+					"dns": {}
+				}`, expectedNetNSPath)))
+
+				logFileContents, err = ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-0.log"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+				Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+				Expect(pluginCallInfo.Stdin).NotTo(ContainSubstring("prevResult"))
+			})
+
+			Context("when a network's version is not supported", func() {
+				BeforeEach(func() {
+					Expect(ioutil.WriteFile(filepath.Join(cniConfigDir, "0-plugin-0.conf"), []byte(`{
+						"cniVersion": "2.0.0",
+						"name": "some-net-0",
+						"type": "plugin-0"
+					}`), 0600)).To(Succeed())
+				})
+
+				It("rejects the config with a config error", func() {
+					upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+					Expect(lastLine(upSession.Err.Contents())).To(ContainSubstring(`unsupported cniVersion \"2.0.0\": must be one of 0.1.0, 0.2.0, 0.3.0, 0.3.1, 0.4.0, 1.0.0`))
+					Expect(filepath.Join(fakeLogDir, "plugin-1.log")).NotTo(BeAnExistingFile())
+				})
+			})
+		})
+
+		Context("when checking a container's networking", func() {
+			var checkCommand *exec.Cmd
+
+			BeforeEach(func() {
+				for i := 0; i < 3; i++ {
+					config := fmt.Sprintf(`{ "cniVersion": "0.4.0", "name": "some-net-%d", "type": "plugin-%d" }`, i, i)
+					outpath := filepath.Join(cniConfigDir, fmt.Sprintf("%d-plugin-%d.conf", 10*i, i))
+					Expect(ioutil.WriteFile(outpath, []byte(config), 0600)).To(Succeed())
+				}
+
+				checkCommand = exec.Command(pathToAdapter)
+				checkCommand.Env = []string{"FAKE_LOG_DIR=" + fakeLogDir}
+				checkCommand.Stdin = strings.NewReader(`{}`)
+				checkCommand.Args = []string{
+					pathToAdapter,
+					"--action", "check",
+					"--handle", "some-container-handle",
+					"--configFile", fakeConfigFilePath,
+				}
+			})
+
+			JustBeforeEach(func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+			})
+
+			AfterEach(func() {
+				downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+			})
+
+			It("calls CNI CHECK with the stored result, as the plugin printed it, and reports every network", func() {
+				checkSession, err := gexec.Start(checkCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(checkSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				Expect(checkSession.Out.Contents()).To(MatchJSON(`{
+					"some-net-0": { "interface": "eth0", "status": "ok" },
+					"some-net-1": { "interface": "eth1", "status": "ok" },
+					"some-net-2": { "interface": "eth2", "status": "ok" }
+				}`))
+
+				for i := 0; i < 3; i++ {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+
+					Expect(pluginCallInfo.Stdin).To(MatchJSON(fmt.Sprintf(`{
+						"cniVersion": "0.4.0",
+						"name": "some-net-%d",
+						"type": "plugin-%d",
+						"prevResult": {
+							"cniVersion": "0.4.0",
+							"interfaces": [
+								{ "name": "veth-host", "mac": "ee:ee:00:00:00:01" },
+								{ "name": "eth%d", "mac": "ee:ee:00:00:00:02", "sandbox": %q }
+							],
+							"ips": [{ "version": "4", "interface": 1, "address": "169.254.1.2/24" }],
+							"dns": {}
+						}
+					}`, i, i, i, expectedNetNSPath)))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "CHECK"))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", fmt.Sprintf("eth%d", i)))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_NETNS", expectedNetNSPath))
+				}
+			})
+
+			Context("when a plugin reports that the attachment has drifted", func() {
+				BeforeEach(func() {
+					checkCommand.Env = append(checkCommand.Env, "FAKE_CHECK_FAILURE=interface eth0 is missing")
+				})
+
+				It("exits non-zero with a per-network report", func() {
+					checkSession, err := gexec.Start(checkCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(checkSession, DEFAULT_TIMEOUT).Should(gexec.Exit(1))
+
+					var report map[string]map[string]string
+					Expect(json.Unmarshal(checkSession.Out.Contents(), &report)).To(Succeed())
+					Expect(report).To(HaveLen(3))
+					Expect(report["some-net-0"]).To(HaveKeyWithValue("status", "failed"))
+					Expect(report["some-net-0"]).To(HaveKeyWithValue("error", "check failed for type=plugin-0: interface eth0 is missing"))
+
+					Expect(checkSession.Err.Contents()).To(ContainSubstring("networks failed check: some-net-0, some-net-1, some-net-2"))
+				})
+			})
+
+			Context("when the network configs predate CHECK", func() {
+				BeforeEach(func() {
+					Expect(writeConfig(0, cniConfigDir)).To(Succeed())
+				})
+
+				It("reports the network as unsupported without calling the plugin", func() {
+					checkSession, err := gexec.Start(checkCommand, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(checkSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+					var report map[string]map[string]string
+					Expect(json.Unmarshal(checkSession.Out.Contents(), &report)).To(Succeed())
+					Expect(report["some-net-0"]).To(HaveKeyWithValue("status", "unsupported"))
+
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, "plugin-0.log"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(logFileContents)).NotTo(ContainSubstring("CHECK"))
+				})
+			})
+		})
+
+		Context("when the network spec is missing", func() {
+			BeforeEach(func() {
+				Expect(writeSkipConfig(0, cniConfigDir)).To(Succeed())
+				Expect(writeConfig(1, cniConfigDir)).To(Succeed())
+				Expect(writeConfig(2, cniConfigDir)).To(Succeed())
+				Expect(writeSkipConfig(3, cniConfigDir)).To(Succeed())
+
+				upCommand.Args = append(
+					upCommand.Args,
+					"--properties", `{}`,
+				)
+
+				downCommand.Args = append(
+					downCommand.Args,
+					"--properties", `{}`,
+				)
+			})
+
+			It("calls CNI plugins for which skip_without_network is false", func() {
+				upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				By("checking plugins with skip_without_network were not called")
+				Expect(filepath.Join(fakeLogDir, "plugin-0.log")).NotTo(BeAnExistingFile())
+				Expect(filepath.Join(fakeLogDir, "plugin-3.log")).NotTo(BeAnExistingFile())
+
+				By("checking that other CNI plugins got called with ADD")
+				for i := 1; i < 3; i++ {
+					logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+					Expect(err).NotTo(HaveOccurred())
+					var pluginCallInfo fakePluginLogData
+					Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+
+					Expect(pluginCallInfo.Stdin).To(MatchJSON(getConfig(i)))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "ADD"))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_CONTAINERID", containerHandle))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_IFNAME", fmt.Sprintf("eth%d", i)))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_PATH", cniPluginDir))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_NETNS", expectedNetNSPath))
+					Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_ARGS", ""))
+				}
+
+				By("logging the plugin output / result from up")
+				logContents, err := ioutil.ReadFile(adapterLogFilePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logContents).To(ContainSubstring("up result for name=some-net-1, type=plugin-1"))
+				Expect(logContents).To(ContainSubstring("up result for name=some-net-2, type=plugin-2"))
+				Expect(logContents).To(ContainSubstring("169.254.1.2"))
+
+				By("logging JSON lines that carry the handle, action, network, plugin and duration")
+				var pluginLine map[string]interface{}
+				for _, line := range logLines(logContents) {
+					Expect(line).To(HaveKeyWithValue("handle", containerHandle))
+					Expect(line).To(HaveKeyWithValue("action", "up"))
+					Expect(line).To(HaveKey("timestamp"))
+					Expect(line).NotTo(HaveKeyWithValue("level", "debug"))
+					if line["message"] == "plugin succeeded" && line["network"] == "some-net-2" {
+						pluginLine = line
+					}
+				}
+				Expect(pluginLine).To(HaveKeyWithValue("level", "info"))
+				Expect(pluginLine).To(HaveKeyWithValue("plugin", "plugin-2"))
+				Expect(pluginLine).To(HaveKeyWithValue("command", "ADD"))
+				Expect(pluginLine["duration"]).To(BeNumerically(">", 0))
 
 				By("checking that the fake process's network namespace has been bind-mounted into the filesystem")
 				Expect(sameFile(expectedNetNSPath, fmt.Sprintf("/proc/%d/ns/net", fakePid))).To(BeTrue())
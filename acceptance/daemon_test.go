@@ -0,0 +1,216 @@
+package acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Guardian CNI adapter daemon", func() {
+	var (
+		cniConfigDir       string
+		fakeLogDir         string
+		bindMountRoot      string
+		stateDir           string
+		socketDir          string
+		socketPath         string
+		fakeConfigFilePath string
+		adapterLogDir      string
+		fakeProcess        *os.Process
+		daemonSession      *gexec.Session
+	)
+
+	var adapterCommand = func(stdin string, args ...string) *exec.Cmd {
+		command := exec.Command(pathToAdapter)
+		command.Env = []string{"FAKE_LOG_DIR=" + fakeLogDir}
+		command.Stdin = strings.NewReader(stdin)
+		command.Args = append([]string{pathToAdapter, "--configFile", fakeConfigFilePath}, args...)
+		return command
+	}
+
+	var status = func() map[string]interface{} {
+		session, err := gexec.Start(adapterCommand("", "--action", "status"), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+		var status map[string]interface{}
+		Expect(json.Unmarshal(session.Out.Contents(), &status)).To(Succeed())
+		return status
+	}
+
+	BeforeEach(func() {
+		var err error
+		cniConfigDir, err = ioutil.TempDir("", "cni-config-")
+		Expect(err).NotTo(HaveOccurred())
+		fakeLogDir, err = ioutil.TempDir("", "fake-logs-")
+		Expect(err).NotTo(HaveOccurred())
+		bindMountRoot, err = ioutil.TempDir("", "bind-mount-root")
+		Expect(err).NotTo(HaveOccurred())
+		stateDir, err = ioutil.TempDir("", "state-dir")
+		Expect(err).NotTo(HaveOccurred())
+		socketDir, err = ioutil.TempDir("", "socket-dir")
+		Expect(err).NotTo(HaveOccurred())
+		socketPath = filepath.Join(socketDir, "adapter.sock")
+
+		adapterLogDir, err = ioutil.TempDir("", "adapter-log-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(writeConfig(0, cniConfigDir)).To(Succeed())
+		Expect(writeConfig(1, cniConfigDir)).To(Succeed())
+
+		sleepCmd := exec.Command("/bin/sleep", "1000")
+		Expect(sleepCmd.Start()).To(Succeed())
+		fakeProcess = sleepCmd.Process
+
+		configFile, err := ioutil.TempFile("", "adapter-config-")
+		Expect(err).NotTo(HaveOccurred())
+		fakeConfigFilePath = configFile.Name()
+		configBytes, err := json.Marshal(map[string]string{
+			"cni_plugin_dir": cniPluginDir,
+			"cni_config_dir": cniConfigDir,
+			"bind_mount_dir": bindMountRoot,
+			"log_dir":        adapterLogDir,
+			"state_dir":      stateDir,
+			"socket_path":    socketPath,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = configFile.Write(configBytes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configFile.Close()).To(Succeed())
+
+		daemonCommand := adapterCommand("", "--action", "daemon")
+		daemonSession, err = gexec.Start(daemonCommand, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(socketPath, DEFAULT_TIMEOUT).Should(BeAnExistingFile())
+	})
+
+	AfterEach(func() {
+		daemonSession.Kill().Wait(DEFAULT_TIMEOUT)
+		Expect(fakeProcess.Kill()).To(Succeed())
+		Expect(os.Remove(fakeConfigFilePath)).To(Succeed())
+		Expect(os.RemoveAll(cniConfigDir)).To(Succeed())
+		Expect(os.RemoveAll(fakeLogDir)).To(Succeed())
+		Expect(os.RemoveAll(adapterLogDir)).To(Succeed())
+		Expect(os.RemoveAll(stateDir)).To(Succeed())
+		Expect(os.RemoveAll(socketDir)).To(Succeed())
+	})
+
+	It("handles up and down forwarded by the hook", func() {
+		By("calling up")
+		upCommand := adapterCommand(fmt.Sprintf(`{ "pid": %d }`, fakeProcess.Pid), "--action", "up", "--handle", "some-container-handle")
+		upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+		Expect(upSession.Out.Contents()).To(MatchJSON(`{
+			"handle": "some-container-handle",
+			"networks": {
+				"some-net-0": { "interface": "eth0", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} },
+				"some-net-1": { "interface": "eth1", "ips": [{ "version": "4", "address": "169.254.1.2/24" }], "dns": {} }
+			}
+		}`))
+		Expect(sameFile(fmt.Sprintf("/proc/%d/ns/net", fakeProcess.Pid), filepath.Join(bindMountRoot, "some-container-handle"))).To(BeTrue())
+
+		By("calling down")
+		downCommand := adapterCommand(`{}`, "--action", "down", "--handle", "some-container-handle")
+		downSession, err := gexec.Start(downCommand, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+		for i := 0; i < 2; i++ {
+			logFileContents, err := ioutil.ReadFile(filepath.Join(fakeLogDir, fmt.Sprintf("plugin-%d.log", i)))
+			Expect(err).NotTo(HaveOccurred())
+			var pluginCallInfo fakePluginLogData
+			Expect(json.Unmarshal(logFileContents, &pluginCallInfo)).To(Succeed())
+			Expect(pluginCallInfo.Env).To(HaveKeyWithValue("CNI_COMMAND", "DEL"))
+		}
+		Expect(filepath.Join(bindMountRoot, "some-container-handle")).NotTo(BeAnExistingFile())
+
+		By("writing its lines for the container to the container's log as well as its own")
+		handleLog, err := ioutil.ReadFile(filepath.Join(adapterLogDir, "some-container-handle.log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(handleLog)).To(ContainSubstring(`"message":"handling up for some-container-handle"`))
+		Expect(string(handleLog)).To(ContainSubstring(`"message":"plugin succeeded"`))
+		Expect(string(handleLog)).To(ContainSubstring(`"message":"handling down for some-container-handle"`))
+		daemonLog, err := ioutil.ReadFile(filepath.Join(adapterLogDir, "daemon.log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(daemonLog)).To(ContainSubstring(`"message":"handling up for some-container-handle"`))
+
+		By("reporting the requests in its status")
+		Expect(status()).To(HaveKeyWithValue("requests", map[string]interface{}{"up": 1.0, "down": 1.0}))
+	})
+
+	It("returns failures to the hook", func() {
+		upCommand := adapterCommand(`{ "pid": 0 }`, "--action", "up", "--handle", "some-container-handle")
+		upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(2))
+		Expect(upSession.Err.Contents()).To(ContainSubstring("up failed: up missing pid"))
+
+		Expect(status()).To(HaveKeyWithValue("failures", map[string]interface{}{"up": 1.0}))
+	})
+
+	It("reloads the network configs on SIGHUP", func() {
+		Expect(status()).To(HaveKeyWithValue("networks", []interface{}{"some-net-0", "some-net-1"}))
+
+		Expect(writeConfig(2, cniConfigDir)).To(Succeed())
+		Expect(status()).To(HaveKeyWithValue("networks", []interface{}{"some-net-0", "some-net-1"}))
+
+		daemonSession.Signal(syscall.SIGHUP)
+		Eventually(status, DEFAULT_TIMEOUT).Should(HaveKeyWithValue("networks", []interface{}{"some-net-0", "some-net-1", "some-net-2"}))
+	})
+
+	It("exits cleanly and removes its socket on SIGTERM", func() {
+		daemonSession.Terminate()
+		Eventually(daemonSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+		Expect(socketPath).NotTo(BeAnExistingFile())
+	})
+
+	Context("when terminated during a slow up", func() {
+		BeforeEach(func() {
+			daemonSession.Terminate()
+			Eventually(daemonSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+			daemonCommand := adapterCommand("", "--action", "daemon")
+			daemonCommand.Env = append(daemonCommand.Env, "FAKE_ADD_DELAY=2s")
+			var err error
+			daemonSession, err = gexec.Start(daemonCommand, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(socketPath, DEFAULT_TIMEOUT).Should(BeAnExistingFile())
+		})
+
+		It("finishes the up before removing its socket and exiting", func() {
+			upCommand := adapterCommand(fmt.Sprintf(`{ "pid": %d }`, fakeProcess.Pid), "--action", "up", "--handle", "some-container-handle")
+			upSession, err := gexec.Start(upCommand, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(filepath.Join(fakeLogDir, "plugin-0.log"), DEFAULT_TIMEOUT).Should(BeAnExistingFile())
+
+			daemonSession.Terminate()
+			Consistently(socketPath, "1s").Should(BeAnExistingFile())
+
+			Eventually(upSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+			Expect(upSession.Out.Contents()).To(ContainSubstring(`"some-net-1"`))
+			Eventually(daemonSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+			Expect(socketPath).NotTo(BeAnExistingFile())
+
+			state, err := ioutil.ReadFile(filepath.Join(stateDir, "some-container-handle.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(state)).To(ContainSubstring(`"status":"attached"`))
+
+			By("handling the down in the hook, as the daemon is gone")
+			downSession, err := gexec.Start(adapterCommand(`{}`, "--action", "down", "--handle", "some-container-handle"), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(downSession, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+			Expect(filepath.Join(bindMountRoot, "some-container-handle")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(stateDir, "some-container-handle.json")).NotTo(BeAnExistingFile())
+		})
+	})
+})
@@ -52,6 +52,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 			"cni_config_dir": "/some/cni/config/dir",
 			"bind_mount_dir": "/some/bind/mount/dir",
 			"log_dir":        adapterLogDir,
+			"state_dir":      "/some/state/dir",
 		}
 		writeConfig(defaultConfig)
 
@@ -74,39 +75,44 @@ var _ = Describe("Guardian CNI adapter", func() {
 
 	Context("when inputs are invalid", func() {
 		Context("when stdin is not valid JSON", func() {
-			It("should exit status 1 and print an error to stderr", func() {
+			It("should exit status 2 and print an error to stderr", func() {
 				command.Stdin = strings.NewReader("{{{bad")
 				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 				Expect(err).NotTo(HaveOccurred())
 
-				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session).Should(gexec.Exit(2))
 				Expect(session.Out.Contents()).To(BeEmpty())
 				By("checking that the error was logged to stderr")
 				Expect(session.Err.Contents()).To(ContainSubstring("json"))
 				Expect(session.Err.Contents()).To(ContainSubstring("{{{bad"))
 
+				By("checking that the error was reported as JSON")
+				Expect(lastLine(session.Err.Contents())).To(ContainSubstring(`"kind":"config"`))
+				Expect(lastLine(session.Err.Contents())).To(ContainSubstring(`input is not valid json`))
+
 			})
 		})
 
 		Context("when the stdin JSON is missing a pid field", func() {
-			It("should exit status 1 and print an error to stderr", func() {
+			It("should exit status 2 and print an error to stderr", func() {
 				command.Stdin = strings.NewReader(`{ "something": 12 }`)
 				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 				Expect(err).NotTo(HaveOccurred())
 
-				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session).Should(gexec.Exit(2))
 				Expect(session.Out.Contents()).To(BeEmpty())
 				Expect(session.Err.Contents()).To(ContainSubstring("missing pid"))
+				Expect(lastLine(session.Err.Contents())).To(ContainSubstring(`"kind":"config"`))
 			})
 		})
 
 		Context("when the provided pid is not an integer", func() {
-			It("should exit status 1 and print an error to stderr", func() {
+			It("should exit status 2 and print an error to stderr", func() {
 				command.Stdin = strings.NewReader(`{ "pid": "not-a-number"  }`)
 				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 				Expect(err).NotTo(HaveOccurred())
 
-				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session).Should(gexec.Exit(2))
 				Expect(session.Out.Contents()).To(BeEmpty())
 				Expect(session.Err.Contents()).To(ContainSubstring(`cannot unmarshal string into Go value of type int`))
 			})
@@ -119,9 +125,10 @@ var _ = Describe("Guardian CNI adapter", func() {
 				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 				Expect(err).NotTo(HaveOccurred())
 
-				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session).Should(gexec.Exit(2))
 				Expect(session.Out.Contents()).To(BeEmpty())
 				Expect(session.Err.Contents()).To(ContainSubstring(`action: some-invalid-action is unrecognized`))
+				Expect(lastLine(session.Err.Contents())).To(ContainSubstring(`"kind":"config"`))
 
 				By("checking that the error was logged to a file")
 				Expect(ioutil.ReadFile(adapterLogFilePath)).To(ContainSubstring("action: some-invalid-action"))
@@ -136,13 +143,147 @@ var _ = Describe("Guardian CNI adapter", func() {
 					session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 					Expect(err).NotTo(HaveOccurred())
 
-					Eventually(session).Should(gexec.Exit(1))
+					Eventually(session).Should(gexec.Exit(2))
 					Expect(ioutil.ReadFile(adapterLogFilePath)).To(HavePrefix("some existing logs"))
 					Expect(ioutil.ReadFile(adapterLogFilePath)).To(ContainSubstring("action: some-invalid-action"))
 				})
 			})
 		})
 
+		Context("when the network spec is invalid", func() {
+			It("should exit status 2 and print an error to stderr", func() {
+				command.Args = append(command.Args, "--network=banana")
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(2))
+				Expect(session.Out.Contents()).To(BeEmpty())
+				Expect(session.Err.Contents()).To(ContainSubstring(`invalid network spec \"banana\": must be an IP or CIDR`))
+				Expect(lastLine(session.Err.Contents())).To(MatchJSON(`{
+					"kind": "config",
+					"msg": "up failed: invalid network spec \"banana\": must be an IP or CIDR"
+				}`))
+			})
+		})
+
+		Context("when the state dir is not configured", func() {
+			It("defaults to a directory in the bind mount dir", func() {
+				delete(defaultConfig, "state_dir")
+				writeConfig(defaultConfig)
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				By("checking that it got past the config, only to fail mounting")
+				Eventually(session).Should(gexec.Exit(3))
+				Expect(session.Err.Contents()).NotTo(ContainSubstring("missing required config"))
+			})
+		})
+
+		Context("when the lock timeout is invalid", func() {
+			It("should exit status 2 and print an error to stderr", func() {
+				defaultConfig["lock_timeout"] = "banana"
+				writeConfig(defaultConfig)
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(2))
+				Expect(session.Out.Contents()).To(BeEmpty())
+				Expect(session.Err.Contents()).To(ContainSubstring(`invalid config 'lock_timeout': must be a positive duration such as \"30s\"`))
+			})
+		})
+
+		Context("when the plugin timeout is invalid", func() {
+			It("should exit status 2 and print an error to stderr", func() {
+				defaultConfig["plugin_timeout"] = "-5s"
+				writeConfig(defaultConfig)
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(2))
+				Expect(session.Out.Contents()).To(BeEmpty())
+				Expect(session.Err.Contents()).To(ContainSubstring(`invalid config 'plugin_timeout': must be a positive duration such as \"30s\"`))
+			})
+		})
+
+		Context("when the log level is unknown", func() {
+			It("should exit status 2 and print an error to stderr", func() {
+				defaultConfig["log_level"] = "verbose"
+				writeConfig(defaultConfig)
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(2))
+				Expect(session.Out.Contents()).To(BeEmpty())
+				Expect(session.Err.Contents()).To(ContainSubstring(`invalid config 'log_level': unknown log level \"verbose\": must be debug, info, warn, error`))
+			})
+		})
+
+		Context("when the log max size is invalid", func() {
+			It("should exit status 2 and print an error to stderr", func() {
+				defaultConfig["log_max_size"] = "10 megs"
+				writeConfig(defaultConfig)
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(2))
+				Expect(session.Out.Contents()).To(BeEmpty())
+				Expect(session.Err.Contents()).To(ContainSubstring(`invalid config 'log_max_size': invalid size \"10 megs\": must be a positive number of B, KB, MB or GB`))
+			})
+		})
+
+		Context("when the trace endpoint is not a URL", func() {
+			It("should exit status 2 and print an error to stderr", func() {
+				defaultConfig["trace_endpoint"] = "127.0.0.1:4318"
+				writeConfig(defaultConfig)
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(2))
+				Expect(session.Out.Contents()).To(BeEmpty())
+				Expect(session.Err.Contents()).To(ContainSubstring(`invalid config 'trace_endpoint': must be an http or https URL such as \"http://127.0.0.1:4318\"`))
+			})
+		})
+
+		Context("when a plugin checksum is not a SHA-256", func() {
+			It("should exit status 2 and print an error to stderr", func() {
+				config := map[string]interface{}{"plugin_checksums": map[string]string{"bridge": "banana"}}
+				for key, value := range defaultConfig {
+					config[key] = value
+				}
+				configBytes, err := json.Marshal(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(fakeConfigFilePath, configBytes, 0600)).To(Succeed())
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(2))
+				Expect(session.Out.Contents()).To(BeEmpty())
+				Expect(session.Err.Contents()).To(ContainSubstring(`invalid config 'plugin_checksums': checksum of bridge must be a hex SHA-256`))
+			})
+		})
+
+		Context("when the output format is unknown", func() {
+			It("should exit status 2 and print an error to stderr", func() {
+				command.Args = append(command.Args, "--format", "yaml")
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(2))
+				Expect(session.Out.Contents()).To(BeEmpty())
+				Expect(session.Err.Contents()).To(ContainSubstring(`invalid flag 'format': must be table or json`))
+				Expect(lastLine(session.Err.Contents())).To(ContainSubstring(`"kind":"config"`))
+			})
+		})
+
 		Context("when an unknown flag is provided", func() {
 			It("should return an error", func() {
 				command.Args = append(command.Args, "--banana")
@@ -150,7 +291,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 				Expect(err).NotTo(HaveOccurred())
 
-				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session).Should(gexec.Exit(2))
 				Expect(session.Out.Contents()).To(BeEmpty())
 				Expect(session.Err.Contents()).To(ContainSubstring(`flag provided but not defined: -banana`))
 			})
@@ -163,7 +304,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 				Expect(err).NotTo(HaveOccurred())
 
-				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session).Should(gexec.Exit(2))
 				Expect(session.Out.Contents()).To(BeEmpty())
 				Expect(session.Err.Contents()).To(ContainSubstring(`unexpected extra args: [something-else]`))
 			})
@@ -188,12 +329,13 @@ var _ = Describe("Guardian CNI adapter", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				By("checking that process exits with an err")
-				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session).Should(gexec.Exit(2))
 
 				By("checking that the error was logged to stderr")
 				Expect(session.Out.Contents()).To(BeEmpty())
 				expectedErrorString := fmt.Sprintf("missing required flag '%s'", missingFlag)
 				Expect(session.Err.Contents()).To(ContainSubstring(expectedErrorString))
+				Expect(lastLine(session.Err.Contents())).To(ContainSubstring(`"kind":"config"`))
 
 				By("checking that the error was logged to a file")
 				if missingFlag != "handle" && missingFlag != "configFile" {
@@ -214,7 +356,7 @@ var _ = Describe("Guardian CNI adapter", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				By("checking that process exits with an err")
-				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session).Should(gexec.Exit(2))
 
 				By("checking that the error was logged to stderr")
 				Expect(session.Out.Contents()).To(BeEmpty())
@@ -7,8 +7,11 @@ import (
 	"log"
 	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/containernetworking/cni/pkg/types"
 )
@@ -16,7 +19,7 @@ import (
 func parseEnviron(pairs []string) (map[string]string, error) {
 	hash := make(map[string]string)
 	for i, p := range pairs {
-		parts := strings.Split(p, "=")
+		parts := strings.SplitN(p, "=", 2)
 		if len(parts) != 2 {
 			return nil, fmt.Errorf("can't parse env var %d: %s", i, p)
 		}
@@ -31,6 +34,80 @@ type LogInfo struct {
 	Stdin string
 }
 
+// recordCall appends the command to the plugin's calls file, and returns how
+// many times the plugin has now been called with it.
+func recordCall(callsFilePath, command string) (int, error) {
+	callsFile, err := os.OpenFile(callsFilePath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer callsFile.Close()
+
+	if _, err := callsFile.WriteString(command + "\n"); err != nil {
+		return 0, err
+	}
+
+	callBytes, err := ioutil.ReadFile(callsFilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	calls := 0
+	for _, call := range strings.Fields(string(callBytes)) {
+		if call == command {
+			calls++
+		}
+	}
+	return calls, nil
+}
+
+// hang starts a child that outlives the plugin unless its whole process group
+// is killed, as a delegated IPAM plugin would, and then waits forever.
+func hang(childPidFilePath string) {
+	child := exec.Command("/bin/sleep", "1000")
+	if err := child.Start(); err != nil {
+		log.Fatalf("unable to start child: %s", err)
+	}
+
+	err := ioutil.WriteFile(childPidFilePath, []byte(strconv.Itoa(child.Process.Pid)), 0600)
+	if err != nil {
+		log.Fatalf("unable to write child pid: %s", err)
+	}
+
+	time.Sleep(time.Hour)
+}
+
+// newResult prints a result in the spec version of the plugin's config, with
+// an address on the container's interface.
+func newResult(cniVersion string, env map[string]string) interface{} {
+	switch cniVersion {
+	case "", "0.1.0", "0.2.0":
+		return types.Result{
+			IP4: &types.IPConfig{
+				IP: net.IPNet{
+					IP:   net.ParseIP("169.254.1.2"),
+					Mask: net.IPv4Mask(255, 255, 255, 0),
+				},
+			},
+		}
+	}
+
+	ip := map[string]interface{}{"interface": 1, "address": "169.254.1.2/24"}
+	if cniVersion != "1.0.0" {
+		ip["version"] = "4"
+	}
+
+	return map[string]interface{}{
+		"cniVersion": cniVersion,
+		"interfaces": []map[string]string{
+			{"name": "veth-host", "mac": "ee:ee:00:00:00:01"},
+			{"name": env["CNI_IFNAME"], "mac": "ee:ee:00:00:00:02", "sandbox": env["CNI_NETNS"]},
+		},
+		"ips": []interface{}{ip},
+		"dns": map[string]interface{}{},
+	}
+}
+
 func main() {
 	const logDirEnvVar = "FAKE_LOG_DIR"
 	logDir := os.Getenv(logDirEnvVar)
@@ -67,16 +144,53 @@ func main() {
 		log.Fatalf("unable to write log file: %s", err)
 	}
 
-	result := types.Result{
-		IP4: &types.IPConfig{
-			IP: net.IPNet{
-				IP:   net.ParseIP("169.254.1.2"),
-				Mask: net.IPv4Mask(255, 255, 255, 0),
-			},
-		},
+	calls, err := recordCall(filepath.Join(logDir, filepath.Base(args[0])+".calls"), env["CNI_COMMAND"])
+	if err != nil {
+		log.Fatalf("unable to record call: %s", err)
+	}
+
+	failureMessage := os.Getenv("FAKE_" + env["CNI_COMMAND"] + "_FAILURE")
+	failingPlugin := os.Getenv("FAKE_FAILING_PLUGIN")
+
+	// FAKE_<COMMAND>_DELAY, such as "2s", slows the command down without
+	// failing it
+	if delay, err := time.ParseDuration(os.Getenv("FAKE_" + env["CNI_COMMAND"] + "_DELAY")); err == nil {
+		time.Sleep(delay)
+	}
+
+	if os.Getenv("FAKE_"+env["CNI_COMMAND"]+"_HANG") != "" && (failingPlugin == "" || failingPlugin == filepath.Base(args[0])) {
+		hang(filepath.Join(logDir, filepath.Base(args[0])+".child-pid"))
+	}
+
+	// FAKE_FAILURE_COUNT limits how many calls of the command fail, so that
+	// later attempts succeed
+	failureCount, err := strconv.Atoi(os.Getenv("FAKE_FAILURE_COUNT"))
+	stillFailing := err != nil || calls <= failureCount
+
+	if failureMessage != "" && stillFailing && (failingPlugin == "" || failingPlugin == filepath.Base(args[0])) {
+		failureCode, err := strconv.Atoi(os.Getenv("FAKE_FAILURE_CODE"))
+		if err != nil {
+			failureCode = 100
+		}
+
+		pluginErr := types.Error{
+			Code: uint(failureCode),
+			Msg:  failureMessage,
+		}
+		errBytes, err := json.Marshal(pluginErr)
+		if err != nil {
+			log.Fatalf("unable to json marshal error data: %s", err)
+		}
+		os.Stdout.Write(errBytes)
+		os.Exit(1)
+	}
+
+	var config struct {
+		CNIVersion string `json:"cniVersion"`
 	}
+	json.Unmarshal(stdin, &config)
 
-	outputBytes, err := json.Marshal(result)
+	outputBytes, err := json.Marshal(newResult(config.CNIVersion, env))
 	if err != nil {
 		log.Fatalf("unable to json marshal result data: %s", err)
 	}
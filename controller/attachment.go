@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+// NetworkAttachment records the exact plugin configs a network was added
+// with, so that DEL can be replayed against the same payloads even if the
+// config directory has changed since.  RawResult is the final plugin's result
+// as it printed it, which CHECK and DEL are passed as the prevResult.
+type NetworkAttachment struct {
+	Network    string            `json:"network"`
+	CNIVersion string            `json:"cniVersion,omitempty"`
+	Interface  string            `json:"interface"`
+	Args       [][2]string       `json:"args,omitempty"`
+	Timeout    time.Duration     `json:"timeout,omitempty"`
+	Retry      *RetryPolicy      `json:"retry,omitempty"`
+	Configs    []json.RawMessage `json:"configs"`
+	Result     NetworkResult     `json:"result"`
+	RawResult  json.RawMessage   `json:"rawResult,omitempty"`
+}
+
+func NewNetworkAttachment(networkConfigList *NetworkConfigList, ifName string, args [][2]string) NetworkAttachment {
+	attachment := NetworkAttachment{
+		Network:    networkConfigList.Name,
+		CNIVersion: networkConfigList.CNIVersion,
+		Interface:  ifName,
+		Args:       args,
+	}
+	for _, plugin := range networkConfigList.Plugins {
+		attachment.Configs = append(attachment.Configs, json.RawMessage(plugin.Bytes))
+	}
+	return attachment
+}
+
+func (a NetworkAttachment) RuntimeConf(handle, namespacePath string) *libcni.RuntimeConf {
+	return &libcni.RuntimeConf{
+		ContainerID: handle,
+		NetNS:       namespacePath,
+		IfName:      a.Interface,
+		Args:        a.Args,
+	}
+}
+
+// prevResult is the result recorded at up time, or, for attachments
+// recorded before the raw result was, one rebuilt from the adapter's result,
+// which knows only the container's interface.  It is nil if the network was
+// never added.
+func (a NetworkAttachment) prevResult(sandbox string) (json.RawMessage, error) {
+	if a.RawResult != nil {
+		return a.RawResult, nil
+	}
+	if a.Result.Interface == "" {
+		return nil, nil
+	}
+	return a.Result.PrevResult(a.CNIVersion, sandbox)
+}
+
+func (a NetworkAttachment) NetworkConfigs() ([]*libcni.NetworkConfig, error) {
+	networkConfigs := []*libcni.NetworkConfig{}
+	for i, config := range a.Configs {
+		networkConfig, err := libcni.ConfFromBytes(config)
+		if err != nil {
+			return nil, fmt.Errorf("parsing config %d for network %s: %s", i, a.Network, err)
+		}
+		networkConfigs = append(networkConfigs, networkConfig)
+	}
+	return networkConfigs, nil
+}
@@ -0,0 +1,61 @@
+package controller_test
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NetworkAttachment", func() {
+	var list *controller.NetworkConfigList
+
+	BeforeEach(func() {
+		var err error
+		list, err = controller.ConfListFromBytes([]byte(`{
+			"name": "some-chain",
+			"plugins": [
+				{ "type": "bridge" },
+				{ "type": "portmap" }
+			]
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("records the plugin configs of a network list", func() {
+		attachment := controller.NewNetworkAttachment(list, "eth3", nil)
+		Expect(attachment.Network).To(Equal("some-chain"))
+		Expect(attachment.Interface).To(Equal("eth3"))
+		Expect(attachment.Configs).To(HaveLen(2))
+		Expect([]byte(attachment.Configs[0])).To(MatchJSON(`{"name": "some-chain", "type": "bridge"}`))
+	})
+
+	It("round trips the recorded configs", func() {
+		attachment := controller.NewNetworkAttachment(list, "eth3", nil)
+
+		attachmentBytes, err := json.Marshal(attachment)
+		Expect(err).NotTo(HaveOccurred())
+
+		var loaded controller.NetworkAttachment
+		Expect(json.Unmarshal(attachmentBytes, &loaded)).To(Succeed())
+
+		networkConfigs, err := loaded.NetworkConfigs()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(networkConfigs).To(HaveLen(2))
+		Expect(networkConfigs[1].Network.Type).To(Equal("portmap"))
+		Expect(networkConfigs[1].Bytes).To(MatchJSON(list.Plugins[1].Bytes))
+	})
+
+	Context("when a recorded config is malformed", func() {
+		It("returns an error", func() {
+			attachment := controller.NetworkAttachment{
+				Network: "some-net",
+				Configs: []json.RawMessage{json.RawMessage(`"banana"`)},
+			}
+
+			_, err := attachment.NetworkConfigs()
+			Expect(err).To(MatchError(HavePrefix("parsing config 0 for network some-net")))
+		})
+	})
+})
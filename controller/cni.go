@@ -1,60 +1,154 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
 	"github.com/containernetworking/cni/libcni"
 )
 
 type CNIController struct {
-	PluginDir string
-	ConfigDir string
+	// PluginDirs are searched for plugins in order.
+	PluginDirs []string
+	ConfigDir  string
 
-	cniConfig      *libcni.CNIConfig
-	networkConfigs []*libcni.NetworkConfig
+	// PluginChecksums, if set, allows only the listed plugins to run, and
+	// only if their binaries have the listed SHA-256 checksums when checked
+	// just before each exec.
+	PluginChecksums map[string]string
+
+	// PluginTimeout bounds each plugin invocation of networks that do not
+	// declare their own timeout.  Zero means no timeout.
+	PluginTimeout time.Duration
+
+	// RecurseConfigDir loads configs from the subdirectories of the config
+	// directory too, in lexical order of their paths.
+	RecurseConfigDir bool
+
+	// SkipInvalidConfigs leaves out, and logs, any config that fails to
+	// load, rather than failing every container.
+	SkipInvalidConfigs bool
+
+	// mu guards the loaded networks, which Reload replaces while requests
+	// for other containers may be planning against them.
+	mu                 sync.RWMutex
+	networkConfigLists []*NetworkConfigList
 }
 
-func (c *CNIController) ensureInitialized() error {
-	if c.cniConfig == nil {
-		c.cniConfig = &libcni.CNIConfig{Path: []string{c.PluginDir}}
+// configs returns the loaded networks, loading them on first use.  A request
+// keeps the networks it got, even if they are reloaded meanwhile.
+func (c *CNIController) configs(ctx context.Context) ([]*NetworkConfigList, error) {
+	c.mu.RLock()
+	networkConfigLists := c.networkConfigLists
+	c.mu.RUnlock()
+	if networkConfigLists != nil {
+		return networkConfigLists, nil
 	}
 
-	if c.networkConfigs == nil {
-		c.networkConfigs = []*libcni.NetworkConfig{}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.networkConfigLists == nil {
+		networkConfigLists, err := c.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.networkConfigLists = networkConfigLists
+	}
 
-		err := filepath.Walk(c.ConfigDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				return nil
-			}
-			if !strings.HasSuffix(path, ".conf") {
-				return nil
-			}
+	return c.networkConfigLists, nil
+}
 
-			conf, err := libcni.ConfFromFile(path)
-			if err != nil {
-				return fmt.Errorf("unable to load config from %s: %s", path, err)
+// load reads the config directory.  Any invalid config fails the load,
+// unless SkipInvalidConfigs is set, in which case it is logged and left out.
+func (c *CNIController) load(ctx context.Context) ([]*NetworkConfigList, error) {
+	_, span := tracing.Start(ctx, "ensureInitialized", tracing.Attributes{"config_dir": c.ConfigDir})
+
+	loaded, err := c.loadConfigDir()
+	if err != nil {
+		err = &KindError{Kind: ErrorKindConfig, Err: fmt.Errorf("error loading config: %s", err)}
+		span.Finish(err)
+		return nil, err
+	}
+
+	networkConfigLists := []*NetworkConfigList{}
+	var problems MultiError
+	for _, config := range loaded {
+		if len(config.status.Problems) > 0 {
+			if c.SkipInvalidConfigs {
+				logging.FromContext(ctx).Error("skipping invalid network config", logging.Fields{"path": config.status.Path, "network": config.status.Network, "problems": config.status.Problems})
+				continue
 			}
-			c.networkConfigs = append(c.networkConfigs, conf)
-			log.Printf("loaded config %+v\n%s\n", conf.Network, string(conf.Bytes))
-			return nil
-		})
+			problems = append(problems, config.status)
+			continue
+		}
+
+		networkConfigList := config.networkConfigList
+		logging.FromContext(ctx).Debug("loaded config", logging.Fields{"path": config.status.Path, "network": networkConfigList.Name, "plugins": len(networkConfigList.Plugins), "config": string(networkConfigList.Bytes)})
+		networkConfigLists = append(networkConfigLists, networkConfigList)
+	}
+
+	if err := problems.errorOrNil(); err != nil {
+		err = &KindError{Kind: ErrorKindConfig, Err: fmt.Errorf("error loading config: %s", err)}
+		span.Finish(err)
+		return nil, err
+	}
+
+	logNetworkOrder(ctx, networkConfigLists)
+	span.Finish(nil)
+	return networkConfigLists, nil
+}
+
+// logNetworkOrder tells operators which network gets which interface, if a
+// container joins it.
+func logNetworkOrder(ctx context.Context, networkConfigLists []*NetworkConfigList) {
+	order := []string{}
+	for i, networkConfigList := range networkConfigLists {
+		ifName, err := InterfaceName(networkConfigList, i)
 		if err != nil {
-			return fmt.Errorf("error loading config: %s", err)
+			ifName = "?" // not tested, as loading checked it
 		}
+		order = append(order, fmt.Sprintf("%s=%s", networkConfigList.Name, ifName))
+	}
+	logging.FromContext(ctx).Info("resolved network order", logging.Fields{"networks": order})
+}
+
+// Reload reads the config directory again.  If it is no longer valid, the
+// configs already loaded are kept and the error returned.
+func (c *CNIController) Reload() error {
+	networkConfigLists, err := c.load(context.Background())
+	if err != nil {
+		return err
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.networkConfigLists = networkConfigLists
 	return nil
 }
 
+// Networks returns the names of the configured networks, in config
+// directory order.
+func (c *CNIController) Networks() ([]string, error) {
+	networkConfigLists, err := c.configs(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize controller: %w", err)
+	}
+
+	names := []string{}
+	for _, networkConfigList := range networkConfigLists {
+		names = append(names, networkConfigList.Name)
+	}
+	return names, nil
+}
+
 func isCIDR(spec string) bool {
 	_, _, err := net.ParseCIDR(spec)
 	return err == nil
@@ -65,6 +159,103 @@ func isIP(spec string) bool {
 	return ip != nil
 }
 
+// GardenNetwork is the parsed form of Garden's network spec, which is either
+// empty, a subnet (10.0.0.0/24), an address within a subnet (10.0.0.5/24) or
+// a bare address (10.0.0.5).
+type GardenNetwork struct {
+	Subnet string
+	IP     string
+}
+
+func ParseGardenNetwork(spec string) (*GardenNetwork, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case isCIDR(spec):
+		ip, ipNet, _ := net.ParseCIDR(spec)
+		gardenNetwork := &GardenNetwork{Subnet: ipNet.String()}
+		if !ip.Equal(ipNet.IP) {
+			gardenNetwork.IP = ip.String()
+		}
+		return gardenNetwork, nil
+	case isIP(spec):
+		return &GardenNetwork{IP: net.ParseIP(spec).String()}, nil
+	default:
+		return nil, fmt.Errorf("invalid network spec %q: must be an IP or CIDR", spec)
+	}
+}
+
+// AppendGardenNetwork hints the requested subnet to the config's IPAM section
+// as ipam.subnet, and the requested address as args.cni.ips, following the
+// CNI conventions for static addressing.  Configs without an IPAM section
+// only receive the address hint.  Only the container's primary network is
+// given the hints.
+func AppendGardenNetwork(existingNetConfig *libcni.NetworkConfig, gardenNetwork *GardenNetwork) (*libcni.NetworkConfig, error) {
+	if gardenNetwork == nil {
+		return existingNetConfig, nil
+	}
+
+	config := make(map[string]interface{})
+	err := json.Unmarshal(existingNetConfig.Bytes, &config)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal existing network bytes: %s", err)
+	}
+
+	if ipam, ok := config["ipam"].(map[string]interface{}); ok && gardenNetwork.Subnet != "" {
+		ipam["subnet"] = gardenNetwork.Subnet
+	}
+
+	if gardenNetwork.IP != "" {
+		args, ok := config["args"].(map[string]interface{})
+		if !ok {
+			args = make(map[string]interface{})
+			config["args"] = args
+		}
+		cniArgs, ok := args["cni"].(map[string]interface{})
+		if !ok {
+			cniArgs = make(map[string]interface{})
+			args["cni"] = cniArgs
+		}
+		cniArgs["ips"] = []string{gardenNetwork.IP}
+	}
+
+	newBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err // not tested
+	}
+
+	return &libcni.NetworkConfig{
+		Network: existingNetConfig.Network,
+		Bytes:   newBytes,
+	}, nil
+}
+
+// supportsCheck reports whether a config's cniVersion is at least 0.4.0, the
+// first spec version that defines the CHECK command.
+func supportsCheck(cniVersion string) bool {
+	return versionAtLeast(cniVersion, 0, 4)
+}
+
+// versionAtLeast compares a config's cniVersion, where a missing or
+// malformed version is taken to be 0.1.0.
+func versionAtLeast(cniVersion string, major, minor int) bool {
+	parts := strings.SplitN(cniVersion, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	actualMajor, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	actualMinor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+
+	return actualMajor > major || (actualMajor == major && actualMinor >= minor)
+}
+
 func AppendNetworkSpec(existingNetConfig *libcni.NetworkConfig, gardenNetworkSpec string) (*libcni.NetworkConfig, error) {
 	config := make(map[string]interface{})
 	err := json.Unmarshal(existingNetConfig.Bytes, &config)
@@ -103,64 +294,333 @@ func AppendNetworkSpec(existingNetConfig *libcni.NetworkConfig, gardenNetworkSpe
 	}, nil
 }
 
-func (c *CNIController) Up(namespacePath, handle, spec string) error {
-	err := c.ensureInitialized()
+// plan resolves which of the loaded networks a container with the given spec
+// should join, along with the interface name and fully enhanced plugin configs
+// for each.
+func (c *CNIController) plan(networkConfigLists []*NetworkConfigList, gardenNetworkSpec, spec string) ([]NetworkAttachment, error) {
+	gardenNetwork, err := ParseGardenNetwork(gardenNetworkSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := NewNetworkSelector(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = selector.CheckAllFound(networkConfigLists)
 	if err != nil {
-		return fmt.Errorf("failed to initialize controller: %s", err)
+		return nil, err
 	}
 
-	for i, networkConfig := range c.networkConfigs {
-		runtimeConfig := &libcni.RuntimeConf{
-			ContainerID: handle,
-			NetNS:       namespacePath,
-			IfName:      fmt.Sprintf("eth%d", i),
+	runtimeArgs, err := ParseRuntimeArgs(spec, gardenNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := []NetworkAttachment{}
+	for i, networkConfigList := range networkConfigLists {
+		selected, err := selector.Selects(networkConfigList)
+		if err != nil {
+			return nil, err
+		}
+
+		if !selected {
+			continue
 		}
 
-		enhancedNetConfig, err := AppendNetworkSpec(networkConfig, spec)
+		enhancedList, err := AppendNetworkSpecToList(networkConfigList, spec)
 		if err != nil {
-			return fmt.Errorf("adding garden network spec to CNI config: %s", err)
+			return nil, fmt.Errorf("adding garden network spec to CNI config: %s", err)
 		}
 
-		if enhancedNetConfig != nil {
-			result, err := c.cniConfig.AddNetwork(enhancedNetConfig, runtimeConfig)
+		if enhancedList == nil {
+			continue
+		}
+
+		// addresses and the MAC are for one interface, so are only given to
+		// the first network the container joins, its primary network
+		networkAddresses, networkArgs := gardenNetwork, runtimeArgs
+		if len(attachments) > 0 {
+			networkAddresses, networkArgs = nil, runtimeArgs.WithoutAddresses()
+		}
+
+		for j, plugin := range enhancedList.Plugins {
+			enhancedList.Plugins[j], err = AppendGardenNetwork(plugin, networkAddresses)
 			if err != nil {
-				return fmt.Errorf("add network failed: %s", err)
+				return nil, fmt.Errorf("adding garden network to CNI config: %s", err)
 			}
 
-			log.Printf("up result for name=%s, type=%s: \n%s\n", networkConfig.Network.Name, networkConfig.Network.Type, result.String())
+			enhancedList.Plugins[j], err = InjectRuntimeConfig(enhancedList.Plugins[j], networkArgs.Capabilities)
+			if err != nil {
+				return nil, fmt.Errorf("adding runtime config to CNI config: %s", err)
+			}
+		}
+
+		// numbered as when loading, which checked that it is valid and
+		// claimed by no other network
+		ifName, err := InterfaceName(networkConfigList, i)
+		if err != nil {
+			return nil, err // not tested
+		}
+
+		timeout, err := PluginTimeout(networkConfigList, c.PluginTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		retryPolicy, err := ParseRetryPolicy(networkConfigList)
+		if err != nil {
+			return nil, err
 		}
+
+		attachment := NewNetworkAttachment(enhancedList, ifName, networkArgs.CNIArgs)
+		attachment.Timeout = timeout
+		attachment.Retry = retryPolicy
+		attachments = append(attachments, attachment)
 	}
 
-	return nil
+	return attachments, nil
+}
+
+// Plan resolves the networks a container with the given specs should join,
+// without adding any of them.
+func (c *CNIController) Plan(ctx context.Context, gardenNetworkSpec, spec string) ([]NetworkAttachment, error) {
+	networkConfigLists, err := c.configs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize controller: %w", err)
+	}
+
+	attachments, err := c.plan(networkConfigLists, gardenNetworkSpec, spec)
+	if err != nil {
+		return nil, &KindError{Kind: ErrorKindConfig, Err: err}
+	}
+	return attachments, nil
+}
+
+// Add runs ADD for each plugin of a planned attachment in order, passing
+// each plugin's result to the next, and returns the final result, both
+// parsed and as the final plugin printed it.  If the
+// network's retry policy allows another attempt, the plugins already run are
+// deleted before the whole chain is added again.
+func (c *CNIController) Add(ctx context.Context, namespacePath, handle string, attachment NetworkAttachment) (NetworkResult, json.RawMessage, error) {
+	_, err := c.configs(ctx)
+	if err != nil {
+		return NetworkResult{}, nil, fmt.Errorf("failed to initialize controller: %w", err)
+	}
+
+	runtimeConfig := attachment.RuntimeConf(handle, namespacePath)
+
+	networkConfigs, err := attachment.NetworkConfigs()
+	if err != nil {
+		return NetworkResult{}, nil, err
+	}
+
+	timeout := c.timeoutFor(attachment)
+
+	var result NetworkResult
+	var rawResult []byte
+	var attempted []*libcni.NetworkConfig
+	fields := logging.Fields{"handle": handle, "network": attachment.Network}
+	err = attachment.Retry.retry(ctx, fmt.Sprintf("ADD for name=%s", attachment.Network), fields, func() error {
+		result, rawResult, attempted, err = c.addChain(ctx, networkConfigs, runtimeConfig, attachment.CNIVersion, timeout)
+		return err
+	}, func() {
+		c.delChain(ctx, attempted, runtimeConfig, attachment.CNIVersion, rawResult, timeout)
+	})
+	if err != nil {
+		return NetworkResult{}, nil, fmt.Errorf("add network failed: %w", err)
+	}
+
+	return result, rawResult, nil
+}
+
+// addChain returns the final result, parsed and as printed, or the error of
+// the first plugin to fail along with every plugin that ADD was run for and
+// the last result printed before it failed.  Each plugin is passed the
+// previous plugin's result as it was printed, in the chain's version.
+func (c *CNIController) addChain(ctx context.Context, networkConfigs []*libcni.NetworkConfig, runtimeConfig *libcni.RuntimeConf, cniVersion string, timeout time.Duration) (NetworkResult, []byte, []*libcni.NetworkConfig, error) {
+	var result NetworkResult
+	var prevResult []byte
+	for i, networkConfig := range networkConfigs {
+		var err error
+		if prevResult != nil {
+			networkConfig, err = injectPrevResult(networkConfig, prevResult)
+			if err != nil {
+				return NetworkResult{}, prevResult, networkConfigs[:i], fmt.Errorf("adding previous result to CNI config: %s", err)
+			}
+		}
+
+		output, err := c.addNetwork(ctx, networkConfig, runtimeConfig, timeout)
+		if err != nil {
+			return NetworkResult{}, prevResult, networkConfigs[:i+1], err
+		}
+
+		result, err = ParseResult(cniVersion, runtimeConfig.IfName, output)
+		if err != nil {
+			return NetworkResult{}, prevResult, networkConfigs[:i+1], err
+		}
+
+		fields := pluginFields(networkConfig, runtimeConfig)
+		fields["result"] = result
+		logging.FromContext(ctx).Info(fmt.Sprintf("up result for name=%s, type=%s", networkConfig.Network.Name, networkConfig.Network.Type), fields)
+		prevResult = output
+	}
+
+	return result, prevResult, networkConfigs, nil
 }
 
-func (c *CNIController) Down(namespacePath, handle, spec string) error {
-	err := c.ensureInitialized()
+// delChain cleans up after a failed attempt to add a chain, in reverse order,
+// passing the attempt's last result as the prevResult as of spec 0.4.0, as
+// Down does.  Failures are only logged, as the next attempt or the rollback
+// will try again.
+func (c *CNIController) delChain(ctx context.Context, networkConfigs []*libcni.NetworkConfig, runtimeConfig *libcni.RuntimeConf, cniVersion string, prevResult []byte, timeout time.Duration) {
+	for i := len(networkConfigs) - 1; i >= 0; i-- {
+		networkConfig := networkConfigs[i]
+		if prevResult != nil && versionAtLeast(cniVersion, 0, 4) {
+			// the attempt ran the same config, so this fails only if the result did
+			if injected, err := injectPrevResult(networkConfig, prevResult); err == nil {
+				networkConfig = injected
+			}
+		}
+
+		err := c.delNetwork(ctx, networkConfig, runtimeConfig, timeout)
+		if err != nil {
+			fields := pluginFields(networkConfig, runtimeConfig)
+			fields["error"] = err
+			logging.FromContext(ctx).Warn(fmt.Sprintf("cleanup failed for name=%s, type=%s", networkConfig.Network.Name, networkConfig.Network.Type), fields)
+		}
+	}
+}
+
+// Down deletes the given attachments, as recorded at up time, in reverse
+// order.  If no attachments were recorded, they are derived from the current
+// config directory and the given specs.  As of spec 0.4.0, each network's
+// plugins are passed its recorded result as the prevResult.  Every network is
+// attempted, and all failures are returned together.
+func (c *CNIController) Down(ctx context.Context, namespacePath, handle, gardenNetworkSpec, spec string, attachments []NetworkAttachment) error {
+	networkConfigLists, err := c.configs(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to initialize controller: %s", err)
+		return fmt.Errorf("failed to initialize controller: %w", err)
 	}
 
-	for i, networkConfig := range c.networkConfigs {
-		runtimeConfig := &libcni.RuntimeConf{
-			ContainerID: handle,
-			NetNS:       namespacePath,
-			IfName:      fmt.Sprintf("eth%d", i),
+	if attachments == nil {
+		attachments, err = c.plan(networkConfigLists, gardenNetworkSpec, spec)
+		if err != nil {
+			return &KindError{Kind: ErrorKindConfig, Err: err}
 		}
+	}
+
+	var errs MultiError
+	for i := len(attachments) - 1; i >= 0; i-- {
+		attachment := attachments[i]
+		runtimeConfig := attachment.RuntimeConf(handle, namespacePath)
 
-		enhancedNetConfig, err := AppendNetworkSpec(networkConfig, spec)
+		networkConfigs, err := attachment.NetworkConfigs()
 		if err != nil {
-			return fmt.Errorf("adding garden network spec to CNI config: %s", err)
+			errs = append(errs, err)
+			continue
 		}
 
-		if enhancedNetConfig != nil {
-			err = c.cniConfig.DelNetwork(networkConfig, runtimeConfig)
+		var prevResult json.RawMessage
+		if versionAtLeast(attachment.CNIVersion, 0, 4) {
+			prevResult, err = attachment.prevResult(namespacePath)
 			if err != nil {
-				return fmt.Errorf("del network failed: %s", err)
+				// deleting without it beats leaking the network
+				logging.FromContext(ctx).Warn(fmt.Sprintf("converting up result for name=%s", attachment.Network), logging.Fields{"handle": handle, "network": attachment.Network, "error": err})
 			}
+		}
 
-			log.Printf("down complete for name=%s, type=%s\n", networkConfig.Network.Name, networkConfig.Network.Type)
+		for j := len(networkConfigs) - 1; j >= 0; j-- {
+			networkConfig := networkConfigs[j]
+			if prevResult != nil {
+				// up ran the same config, so this fails only if the result did
+				if injected, err := injectPrevResult(networkConfig, prevResult); err == nil {
+					networkConfig = injected
+				}
+			}
+
+			description := fmt.Sprintf("DEL for name=%s, type=%s", networkConfig.Network.Name, networkConfig.Network.Type)
+			fields := pluginFields(networkConfig, runtimeConfig)
+			err = attachment.Retry.retry(ctx, description, fields, func() error {
+				return c.delNetwork(ctx, networkConfig, runtimeConfig, c.timeoutFor(attachment))
+			}, nil)
+			if err != nil {
+				fields["error"] = err
+				logging.FromContext(ctx).Error(fmt.Sprintf("down failed for name=%s, type=%s", networkConfig.Network.Name, networkConfig.Network.Type), fields)
+				errs = append(errs, fmt.Errorf("del network failed for name=%s, type=%s: %w", networkConfig.Network.Name, networkConfig.Network.Type, err))
+				continue
+			}
+
+			logging.FromContext(ctx).Info(fmt.Sprintf("down complete for name=%s, type=%s", networkConfig.Network.Name, networkConfig.Network.Type), fields)
 		}
 	}
 
-	return nil
+	return errs.errorOrNil()
+}
+
+// Check runs CHECK for each of the given attachments, as recorded at up time,
+// passing each network's recorded result as the prevResult.
+func (c *CNIController) Check(ctx context.Context, namespacePath, handle string, attachments []NetworkAttachment) (map[string]CheckResult, error) {
+	_, err := c.configs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize controller: %w", err)
+	}
+
+	report := make(map[string]CheckResult)
+	for _, attachment := range attachments {
+		report[attachment.Network] = c.checkAttachment(ctx, attachment, attachment.RuntimeConf(handle, namespacePath))
+	}
+
+	return report, nil
+}
+
+func (c *CNIController) checkAttachment(ctx context.Context, attachment NetworkAttachment, runtimeConfig *libcni.RuntimeConf) CheckResult {
+	checkResult := CheckResult{
+		Interface: runtimeConfig.IfName,
+		Status:    CheckStatusFailed,
+	}
+
+	if !supportsCheck(attachment.CNIVersion) {
+		checkResult.Status = CheckStatusUnsupported
+		return checkResult
+	}
+
+	prevResult, err := attachment.prevResult(runtimeConfig.NetNS)
+	if err != nil {
+		checkResult.Error = fmt.Sprintf("converting up result: %s", err)
+		return checkResult
+	}
+	if prevResult == nil {
+		checkResult.Error = "no result was recorded at up time"
+		return checkResult
+	}
+
+	networkConfigs, err := attachment.NetworkConfigs()
+	if err != nil {
+		checkResult.Error = err.Error()
+		return checkResult
+	}
+
+	for _, networkConfig := range networkConfigs {
+		networkConfig, err = injectPrevResult(networkConfig, prevResult)
+		if err != nil {
+			checkResult.Error = fmt.Sprintf("adding previous result to CNI config: %s", err)
+			return checkResult
+		}
+
+		err = c.checkNetwork(ctx, networkConfig, runtimeConfig, c.timeoutFor(attachment))
+		if err != nil {
+			checkResult.Error = fmt.Sprintf("check failed for type=%s: %s", networkConfig.Network.Type, err)
+			fields := pluginFields(networkConfig, runtimeConfig)
+			fields["error"] = err
+			logging.FromContext(ctx).Error(fmt.Sprintf("check failed for name=%s, type=%s", networkConfig.Network.Name, networkConfig.Network.Type), fields)
+			return checkResult
+		}
+
+		logging.FromContext(ctx).Info(fmt.Sprintf("check passed for name=%s, type=%s", networkConfig.Network.Name, networkConfig.Network.Type), pluginFields(networkConfig, runtimeConfig))
+	}
+
+	checkResult.Status = CheckStatusOK
+	return checkResult
 }
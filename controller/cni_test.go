@@ -1,6 +1,10 @@
 package controller_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
 	"github.com/containernetworking/cni/libcni"
 	. "github.com/onsi/ginkgo"
@@ -70,4 +74,138 @@ var _ = Describe("CNI", func() {
 			})
 		})
 	})
+
+	Describe("ParseGardenNetwork", func() {
+		It("parses a subnet", func() {
+			gardenNetwork, err := controller.ParseGardenNetwork("10.255.0.0/24")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gardenNetwork).To(Equal(&controller.GardenNetwork{Subnet: "10.255.0.0/24"}))
+		})
+
+		It("parses an address within a subnet", func() {
+			gardenNetwork, err := controller.ParseGardenNetwork("10.255.0.5/24")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gardenNetwork).To(Equal(&controller.GardenNetwork{Subnet: "10.255.0.0/24", IP: "10.255.0.5"}))
+		})
+
+		It("parses a bare address", func() {
+			gardenNetwork, err := controller.ParseGardenNetwork("10.255.0.5")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gardenNetwork).To(Equal(&controller.GardenNetwork{IP: "10.255.0.5"}))
+		})
+
+		Context("when the spec is empty", func() {
+			It("returns nil", func() {
+				gardenNetwork, err := controller.ParseGardenNetwork("")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(gardenNetwork).To(BeNil())
+			})
+		})
+
+		Context("when the spec is neither an IP nor a CIDR", func() {
+			It("returns an error", func() {
+				_, err := controller.ParseGardenNetwork("garden-network-spec")
+				Expect(err).To(MatchError(`invalid network spec "garden-network-spec": must be an IP or CIDR`))
+			})
+		})
+	})
+
+	Describe("AppendGardenNetwork", func() {
+		var existingConfig *libcni.NetworkConfig
+
+		BeforeEach(func() {
+			existingConfig = &libcni.NetworkConfig{
+				Bytes: []byte(`{"type": "bridge", "ipam": {"type": "host-local"}}`),
+			}
+		})
+
+		It("hints the subnet to IPAM and the address as a CNI arg", func() {
+			newConfig, err := controller.AppendGardenNetwork(existingConfig, &controller.GardenNetwork{
+				Subnet: "10.255.0.0/24",
+				IP:     "10.255.0.5",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(newConfig.Bytes).To(MatchJSON(`{
+				"type": "bridge",
+				"ipam": {"type": "host-local", "subnet": "10.255.0.0/24"},
+				"args": {"cni": {"ips": ["10.255.0.5"]}}
+			}`))
+		})
+
+		It("preserves existing args", func() {
+			existingConfig.Bytes = []byte(`{"type": "bridge", "args": {"cni": {"labels": ["a"]}, "other": true}}`)
+			newConfig, err := controller.AppendGardenNetwork(existingConfig, &controller.GardenNetwork{IP: "10.255.0.5"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(newConfig.Bytes).To(MatchJSON(`{
+				"type": "bridge",
+				"args": {"cni": {"labels": ["a"], "ips": ["10.255.0.5"]}, "other": true}
+			}`))
+		})
+
+		Context("when the config has no IPAM section", func() {
+			It("does not add a subnet", func() {
+				existingConfig.Bytes = []byte(`{"type": "portmap"}`)
+				newConfig, err := controller.AppendGardenNetwork(existingConfig, &controller.GardenNetwork{Subnet: "10.255.0.0/24"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(newConfig.Bytes).To(MatchJSON(`{"type": "portmap"}`))
+			})
+		})
+
+		Context("when there is no garden network", func() {
+			It("returns the config unchanged", func() {
+				newConfig, err := controller.AppendGardenNetwork(existingConfig, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(newConfig).To(Equal(existingConfig))
+			})
+		})
+
+		Context("when the existing config is malformed JSON", func() {
+			It("returns an error", func() {
+				existingConfig.Bytes = []byte("%%%%%%")
+				_, err := controller.AppendGardenNetwork(existingConfig, &controller.GardenNetwork{IP: "10.255.0.5"})
+				Expect(err).To(MatchError(ContainSubstring("unmarshal existing network bytes")))
+			})
+		})
+	})
+
+	Describe("Networks", func() {
+		var (
+			configDir     string
+			cniController *controller.CNIController
+		)
+
+		BeforeEach(func() {
+			var err error
+			configDir, err = ioutil.TempDir("", "cni-config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(configDir, "10-a.conf"), []byte(`{"name": "net-a", "type": "bridge"}`), 0600)).To(Succeed())
+
+			cniController = &controller.CNIController{ConfigDir: configDir}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(configDir)).To(Succeed())
+		})
+
+		It("caches the configured networks until reloaded", func() {
+			Expect(cniController.Networks()).To(Equal([]string{"net-a"}))
+
+			Expect(ioutil.WriteFile(filepath.Join(configDir, "20-b.conf"), []byte(`{"name": "net-b", "type": "bridge"}`), 0600)).To(Succeed())
+			Expect(cniController.Networks()).To(Equal([]string{"net-a"}))
+
+			Expect(cniController.Reload()).To(Succeed())
+			Expect(cniController.Networks()).To(Equal([]string{"net-a", "net-b"}))
+		})
+
+		Context("when a config fails to load", func() {
+			It("does not cache a partial set of networks", func() {
+				Expect(ioutil.WriteFile(filepath.Join(configDir, "20-b.conf"), []byte(`banana`), 0600)).To(Succeed())
+				_, err := cniController.Networks()
+				Expect(err).To(MatchError(HavePrefix("failed to initialize controller")))
+
+				Expect(os.Remove(filepath.Join(configDir, "20-b.conf"))).To(Succeed())
+				Expect(cniController.Networks()).To(Equal([]string{"net-a"}))
+			})
+		})
+	})
 })
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+// SupportedCNIVersions are the spec versions of the configs the adapter can
+// run.  A config without a cniVersion is taken to be 0.1.0.
+var SupportedCNIVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", "1.0.0"}
+
+// ConfigStatus is the outcome of validating one file of the config
+// directory.  A file with problems is not used.
+type ConfigStatus struct {
+	Path      string   `json:"path"`
+	Network   string   `json:"network,omitempty"`
+	Priority  int      `json:"priority"`
+	Interface string   `json:"interface,omitempty"`
+	Problems  []string `json:"problems,omitempty"`
+}
+
+func (s ConfigStatus) Error() string {
+	return fmt.Sprintf("%s: %s", s.Path, strings.Join(s.Problems, "; "))
+}
+
+// ConfigReport describes every file of the config directory, in the order
+// networks are attached.
+type ConfigReport struct {
+	Configs []ConfigStatus `json:"configs"`
+}
+
+// Invalid returns the files that have problems.
+func (r *ConfigReport) Invalid() []ConfigStatus {
+	invalid := []ConfigStatus{}
+	for _, status := range r.Configs {
+		if len(status.Problems) > 0 {
+			invalid = append(invalid, status)
+		}
+	}
+	return invalid
+}
+
+// loadedConfig is a file of the config directory, along with the network it
+// configures if it parses.
+type loadedConfig struct {
+	status            ConfigStatus
+	networkConfigList *NetworkConfigList
+	priority          int
+}
+
+// loadConfigDir loads the configs in the config directory, in the order
+// networks are attached, and checks those things about them that would fail
+// every container: that each parses, has a name of its own, a supported
+// cniVersion, a valid priority, timeout and retry policy, and an interface of
+// its own.  Every problem is collected, rather than stopping at the first.
+func (c *CNIController) loadConfigDir() ([]loadedConfig, error) {
+	loaded := []loadedConfig{}
+
+	err := filepath.Walk(c.ConfigDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != c.ConfigDir && !c.RecurseConfigDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		config := loadedConfig{status: ConfigStatus{Path: path}}
+		switch {
+		case strings.HasSuffix(path, ".conf"):
+			conf, err := libcni.ConfFromFile(path)
+			if err != nil {
+				config.status.Problems = append(config.status.Problems, fmt.Sprintf("unable to load config: %s", err))
+				break
+			}
+			config.networkConfigList = ConfListFromConf(conf)
+		case strings.HasSuffix(path, ".conflist"):
+			confList, err := ConfListFromFile(path)
+			if err != nil {
+				config.status.Problems = append(config.status.Problems, fmt.Sprintf("unable to load config list: %s", err))
+				break
+			}
+			config.networkConfigList = confList
+		default:
+			return nil
+		}
+
+		if networkConfigList := config.networkConfigList; networkConfigList != nil {
+			config.status.Network = networkConfigList.Name
+			config.status.Problems = append(config.status.Problems, checkConfigList(networkConfigList, c.PluginTimeout)...)
+
+			priority, err := NetworkPriority(networkConfigList)
+			if err != nil {
+				config.status.Problems = append(config.status.Problems, err.Error())
+			}
+			config.priority = priority
+			config.status.Priority = priority
+		}
+
+		loaded = append(loaded, config)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the walk is in lexical order, which breaks ties
+	sort.SliceStable(loaded, func(a, b int) bool {
+		return loaded[a].priority < loaded[b].priority
+	})
+
+	// of two networks with the same name, the one attached later is invalid
+	pathsByName := make(map[string]string)
+	for i, config := range loaded {
+		if config.networkConfigList == nil {
+			continue
+		}
+
+		name := config.networkConfigList.Name
+		if otherPath, ok := pathsByName[name]; ok {
+			loaded[i].status.Problems = append(loaded[i].status.Problems, fmt.Sprintf("network %s is already configured by %s", name, otherPath))
+		} else {
+			pathsByName[name] = config.status.Path
+		}
+	}
+
+	// interfaces are numbered among the networks that load, so a network
+	// with problems takes no number, and its interface is left unclaimed
+	networksByInterface := make(map[string]string)
+	index := 0
+	for i, config := range loaded {
+		if len(config.status.Problems) > 0 {
+			continue
+		}
+
+		name := config.networkConfigList.Name
+		ifName, err := InterfaceName(config.networkConfigList, index)
+		if err != nil {
+			loaded[i].status.Problems = append(loaded[i].status.Problems, err.Error())
+			continue
+		}
+		if otherName, ok := networksByInterface[ifName]; ok {
+			loaded[i].status.Problems = append(loaded[i].status.Problems, fmt.Sprintf("interface %s is already claimed by %s", ifName, otherName))
+			continue
+		}
+
+		networksByInterface[ifName] = name
+		loaded[i].status.Interface = ifName
+		index++
+	}
+
+	return loaded, nil
+}
+
+// NetworkPriority is the network's "priority" field.  Networks are attached
+// in ascending priority, and in lexical order of their config files within a
+// priority, so networks without one keep the order of their files.
+func NetworkPriority(networkConfigList *NetworkConfigList) (int, error) {
+	var declared struct {
+		Priority int `json:"priority"`
+	}
+	err := json.Unmarshal(networkConfigList.Bytes, &declared)
+	if err != nil {
+		return 0, fmt.Errorf("invalid priority for %s: must be an integer", networkConfigList.Name)
+	}
+
+	return declared.Priority, nil
+}
+
+func checkConfigList(networkConfigList *NetworkConfigList, defaultTimeout time.Duration) []string {
+	var problems []string
+
+	if !supportsCNIVersion(networkConfigList.CNIVersion) {
+		problems = append(problems, fmt.Sprintf("unsupported cniVersion %q: must be one of %s", networkConfigList.CNIVersion, strings.Join(SupportedCNIVersions, ", ")))
+	}
+
+	_, err := PluginTimeout(networkConfigList, defaultTimeout)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	_, err = ParseRetryPolicy(networkConfigList)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	return problems
+}
+
+func supportsCNIVersion(cniVersion string) bool {
+	if cniVersion == "" {
+		return true
+	}
+
+	for _, supported := range SupportedCNIVersions {
+		if cniVersion == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks every config in the config directory, as loading them
+// does, and also that the plugins each runs, and the IPAM plugins they
+// delegate to, are in the plugin directories, and pass verification.
+func (c *CNIController) Validate() (*ConfigReport, error) {
+	loaded, err := c.loadConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("reading config dir: %s", err)
+	}
+
+	report := &ConfigReport{Configs: []ConfigStatus{}}
+	for _, config := range loaded {
+		if config.networkConfigList != nil {
+			for _, plugin := range config.networkConfigList.Plugins {
+				_, err := c.findPlugin(plugin.Network.Type)
+				if err != nil {
+					config.status.Problems = append(config.status.Problems, err.Error())
+				}
+
+				if plugin.Network.IPAM.Type != "" {
+					_, err = c.findPlugin(plugin.Network.IPAM.Type)
+					if err != nil {
+						config.status.Problems = append(config.status.Problems, err.Error())
+					}
+				}
+			}
+		}
+
+		report.Configs = append(report.Configs, config.status)
+	}
+
+	return report, nil
+}
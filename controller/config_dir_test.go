@@ -0,0 +1,231 @@
+package controller_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config dir", func() {
+	var (
+		configDir     string
+		pluginDir     string
+		cniController *controller.CNIController
+	)
+
+	writeConfig := func(name, contents string) {
+		Expect(ioutil.WriteFile(filepath.Join(configDir, name), []byte(contents), 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		configDir, err = ioutil.TempDir("", "cni-config-")
+		Expect(err).NotTo(HaveOccurred())
+		pluginDir, err = ioutil.TempDir("", "cni-plugins-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(pluginDir, "bridge"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+
+		writeConfig("10-a.conf", `{"cniVersion": "0.2.0", "name": "net-a", "type": "bridge"}`)
+
+		cniController = &controller.CNIController{ConfigDir: configDir, PluginDirs: []string{pluginDir}}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(configDir)).To(Succeed())
+		Expect(os.RemoveAll(pluginDir)).To(Succeed())
+	})
+
+	Describe("Validate", func() {
+		It("reports each config as valid", func() {
+			writeConfig("20-b.conflist", `{"name": "net-b", "plugins": [{"type": "bridge"}]}`)
+
+			report, err := cniController.Validate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Configs).To(Equal([]controller.ConfigStatus{
+				{Path: filepath.Join(configDir, "10-a.conf"), Network: "net-a", Interface: "eth0"},
+				{Path: filepath.Join(configDir, "20-b.conflist"), Network: "net-b", Interface: "eth1"},
+			}))
+			Expect(report.Invalid()).To(BeEmpty())
+		})
+
+		It("reports every problem at once", func() {
+			writeConfig("20-b.conf", `banana`)
+			writeConfig("30-a.conf", `{"name": "net-a", "type": "bridge"}`)
+			writeConfig("40-c.conf", `{"cniVersion": "9.9.9", "name": "net-c", "type": "missing"}`)
+
+			report, err := cniController.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			invalid := report.Invalid()
+			Expect(invalid).To(HaveLen(3))
+
+			Expect(invalid[0].Path).To(Equal(filepath.Join(configDir, "20-b.conf")))
+			Expect(invalid[0].Problems).To(ConsistOf(HavePrefix("unable to load config")))
+
+			Expect(invalid[1].Network).To(Equal("net-a"))
+			Expect(invalid[1].Problems).To(ConsistOf("network net-a is already configured by " + filepath.Join(configDir, "10-a.conf")))
+
+			Expect(invalid[2].Network).To(Equal("net-c"))
+			Expect(invalid[2].Problems).To(ConsistOf(
+				`unsupported cniVersion "9.9.9": must be one of 0.1.0, 0.2.0, 0.3.0, 0.3.1, 0.4.0, 1.0.0`,
+				"plugin missing not found in "+pluginDir,
+			))
+		})
+
+		It("reports a missing IPAM plugin", func() {
+			writeConfig("20-b.conflist", `{"name": "net-b", "plugins": [{"type": "bridge", "ipam": {"type": "host-local"}}]}`)
+
+			report, err := cniController.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			invalid := report.Invalid()
+			Expect(invalid).To(HaveLen(1))
+			Expect(invalid[0].Network).To(Equal("net-b"))
+			Expect(invalid[0].Problems).To(ConsistOf("plugin host-local not found in " + pluginDir))
+		})
+
+		It("numbers interfaces as up does, counting networks whose plugins are missing", func() {
+			writeConfig("20-b.conf", `{"name": "net-b", "type": "missing"}`)
+			writeConfig("30-c.conf", `banana`)
+			writeConfig("40-d.conf", `{"name": "net-d", "type": "bridge"}`)
+
+			report, err := cniController.Validate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Configs).To(HaveLen(4))
+			Expect(report.Configs[1].Interface).To(Equal("eth1"))
+			Expect(report.Configs[1].Problems).To(ConsistOf("plugin missing not found in " + pluginDir))
+			Expect(report.Configs[2].Interface).To(BeEmpty())
+			Expect(report.Configs[3].Interface).To(Equal("eth2"))
+		})
+
+		It("reports invalid interfaces, and interfaces claimed by two networks", func() {
+			writeConfig("20-b.conf", `{"name": "net-b", "type": "bridge", "interface": "{{.Banana}}"}`)
+			writeConfig("30-c.conf", `{"name": "net-c", "type": "bridge", "interface": "eth0"}`)
+			writeConfig("40-d.conf", `{"name": "net-d", "type": "bridge"}`)
+
+			report, err := cniController.Validate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Configs[1].Problems).To(ConsistOf(HavePrefix("rendering interface template for net-b")))
+			Expect(report.Configs[2].Problems).To(ConsistOf("interface eth0 is already claimed by net-a"))
+			Expect(report.Configs[3].Interface).To(Equal("eth1"))
+			Expect(report.Configs[3].Problems).To(BeEmpty())
+		})
+
+		Context("when the config dir cannot be read", func() {
+			It("returns an error", func() {
+				cniController.ConfigDir = "/does/not/exist"
+				_, err := cniController.Validate()
+				Expect(err).To(MatchError(HavePrefix("reading config dir")))
+			})
+		})
+	})
+
+	Describe("ordering", func() {
+		It("attaches networks in ascending priority, then in lexical order of their files", func() {
+			writeConfig("20-b.conf", `{"name": "net-b", "type": "bridge", "priority": -1}`)
+			writeConfig("30-c.conf", `{"name": "net-c", "type": "bridge", "priority": 5}`)
+			writeConfig("40-d.conf", `{"name": "net-d", "type": "bridge"}`)
+
+			Expect(cniController.Networks()).To(Equal([]string{"net-b", "net-a", "net-d", "net-c"}))
+
+			report, err := cniController.Validate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Configs[0]).To(Equal(controller.ConfigStatus{
+				Path: filepath.Join(configDir, "20-b.conf"), Network: "net-b", Priority: -1, Interface: "eth0",
+			}))
+			Expect(report.Configs[3]).To(Equal(controller.ConfigStatus{
+				Path: filepath.Join(configDir, "30-c.conf"), Network: "net-c", Priority: 5, Interface: "eth3",
+			}))
+		})
+
+		It("rejects the later of two networks with the same name, by priority", func() {
+			writeConfig("20-a.conf", `{"name": "net-a", "type": "bridge", "priority": -1}`)
+
+			report, err := cniController.Validate()
+			Expect(err).NotTo(HaveOccurred())
+			invalid := report.Invalid()
+			Expect(invalid).To(HaveLen(1))
+			Expect(invalid[0].Path).To(Equal(filepath.Join(configDir, "10-a.conf")))
+			Expect(invalid[0].Problems).To(ConsistOf("network net-a is already configured by " + filepath.Join(configDir, "20-a.conf")))
+		})
+
+		It("rejects a priority that is not an integer", func() {
+			writeConfig("20-b.conf", `{"name": "net-b", "type": "bridge", "priority": "high"}`)
+
+			_, err := cniController.Networks()
+			Expect(err).To(MatchError(ContainSubstring("invalid priority for net-b: must be an integer")))
+		})
+
+		Context("when the config dir has subdirectories", func() {
+			BeforeEach(func() {
+				Expect(os.Mkdir(filepath.Join(configDir, "00-disabled"), 0700)).To(Succeed())
+				writeConfig("00-disabled/10-z.conf", `{"name": "net-z", "type": "bridge"}`)
+			})
+
+			It("ignores them", func() {
+				Expect(cniController.Networks()).To(Equal([]string{"net-a"}))
+			})
+
+			Context("when recursing into them", func() {
+				BeforeEach(func() {
+					cniController.RecurseConfigDir = true
+				})
+
+				It("loads their configs too, in lexical order of their paths", func() {
+					Expect(cniController.Networks()).To(Equal([]string{"net-z", "net-a"}))
+				})
+			})
+		})
+	})
+
+	Describe("loading", func() {
+		BeforeEach(func() {
+			writeConfig("20-b.conf", `{"cniVersion": "9.9.9", "name": "net-b", "type": "bridge"}`)
+		})
+
+		It("fails on any invalid config, naming its problems", func() {
+			_, err := cniController.Networks()
+			Expect(err).To(MatchError(ContainSubstring(filepath.Join(configDir, "20-b.conf") + `: unsupported cniVersion "9.9.9"`)))
+			Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindConfig))
+		})
+
+		It("does not check for plugins, so that a missing one only fails its own network", func() {
+			Expect(os.Remove(filepath.Join(configDir, "20-b.conf"))).To(Succeed())
+			writeConfig("20-b.conf", `{"name": "net-b", "type": "missing"}`)
+			Expect(cniController.Networks()).To(Equal([]string{"net-a", "net-b"}))
+		})
+
+		Context("when skipping invalid configs", func() {
+			BeforeEach(func() {
+				cniController.SkipInvalidConfigs = true
+			})
+
+			It("leaves them out", func() {
+				Expect(cniController.Networks()).To(Equal([]string{"net-a"}))
+			})
+
+			It("leaves out networks whose interface is invalid or already claimed", func() {
+				Expect(os.Remove(filepath.Join(configDir, "20-b.conf"))).To(Succeed())
+				writeConfig("20-b.conf", `{"name": "net-b", "type": "bridge", "interface": "not a name"}`)
+				writeConfig("30-c.conf", `{"name": "net-c", "type": "bridge", "interface": "eth0"}`)
+				writeConfig("40-d.conf", `{"name": "net-d", "type": "bridge"}`)
+
+				Expect(cniController.Networks()).To(Equal([]string{"net-a", "net-d"}))
+			})
+		})
+	})
+
+	Describe("Reload", func() {
+		It("keeps the previous networks when the config dir is no longer valid", func() {
+			Expect(cniController.Networks()).To(Equal([]string{"net-a"}))
+
+			writeConfig("20-b.conf", `banana`)
+			Expect(cniController.Reload()).To(MatchError(HavePrefix("error loading config")))
+			Expect(cniController.Networks()).To(Equal([]string{"net-a"}))
+		})
+	})
+})
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+type NetworkConfigList struct {
+	Name       string
+	CNIVersion string
+	Plugins    []*libcni.NetworkConfig
+	Bytes      []byte
+}
+
+func ConfListFromConf(conf *libcni.NetworkConfig) *NetworkConfigList {
+	var versioned struct {
+		CNIVersion string `json:"cniVersion"`
+	}
+	json.Unmarshal(conf.Bytes, &versioned)
+
+	return &NetworkConfigList{
+		Name:       conf.Network.Name,
+		CNIVersion: versioned.CNIVersion,
+		Plugins:    []*libcni.NetworkConfig{conf},
+		Bytes:      conf.Bytes,
+	}
+}
+
+func ConfListFromBytes(bytes []byte) (*NetworkConfigList, error) {
+	rawList := make(map[string]interface{})
+	if err := json.Unmarshal(bytes, &rawList); err != nil {
+		return nil, fmt.Errorf("error parsing configuration list: %s", err)
+	}
+
+	name, ok := rawList["name"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("error parsing configuration list: no name")
+	}
+
+	cniVersion, _ := rawList["cniVersion"].(string)
+
+	rawPlugins, ok := rawList["plugins"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error parsing configuration list: invalid 'plugins' type %T", rawList["plugins"])
+	}
+	if len(rawPlugins) == 0 {
+		return nil, fmt.Errorf("error parsing configuration list: no plugins in list")
+	}
+
+	list := &NetworkConfigList{
+		Name:       name,
+		CNIVersion: cniVersion,
+		Bytes:      bytes,
+	}
+
+	for i, rawPlugin := range rawPlugins {
+		pluginMap, ok := rawPlugin.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error parsing configuration list: plugin %d is not an object", i)
+		}
+
+		pluginMap["name"] = name
+		if cniVersion != "" {
+			pluginMap["cniVersion"] = cniVersion
+		}
+
+		pluginBytes, err := json.Marshal(pluginMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal plugin config %d: %s", i, err) // not tested
+		}
+
+		conf, err := libcni.ConfFromBytes(pluginBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse plugin config %d: %s", i, err)
+		}
+		list.Plugins = append(list.Plugins, conf)
+	}
+
+	return list, nil
+}
+
+func ConfListFromFile(filename string) (*NetworkConfigList, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", filename, err)
+	}
+	return ConfListFromBytes(bytes)
+}
+
+func AppendNetworkSpecToList(existingList *NetworkConfigList, gardenNetworkSpec string) (*NetworkConfigList, error) {
+	listConfig, err := AppendNetworkSpec(&libcni.NetworkConfig{Bytes: existingList.Bytes}, gardenNetworkSpec)
+	if err != nil {
+		return nil, err
+	}
+	if listConfig == nil {
+		return nil, nil
+	}
+
+	enhancedList := &NetworkConfigList{
+		Name:       existingList.Name,
+		CNIVersion: existingList.CNIVersion,
+		Bytes:      listConfig.Bytes,
+	}
+
+	for _, plugin := range existingList.Plugins {
+		enhancedPlugin, err := AppendNetworkSpec(plugin, gardenNetworkSpec)
+		if err != nil {
+			return nil, err
+		}
+		if enhancedPlugin != nil {
+			enhancedList.Plugins = append(enhancedList.Plugins, enhancedPlugin)
+		}
+	}
+
+	if len(enhancedList.Plugins) == 0 {
+		return nil, nil
+	}
+
+	return enhancedList, nil
+}
+
+func injectPrevResult(netConfig *libcni.NetworkConfig, prevResult []byte) (*libcni.NetworkConfig, error) {
+	config := make(map[string]interface{})
+	err := json.Unmarshal(netConfig.Bytes, &config)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal network bytes: %s", err)
+	}
+
+	var resultMap map[string]interface{}
+	err = json.Unmarshal(prevResult, &resultMap)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal previous result: %s", err)
+	}
+	config["prevResult"] = resultMap
+
+	newBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err // not tested
+	}
+
+	return &libcni.NetworkConfig{
+		Network: netConfig.Network,
+		Bytes:   newBytes,
+	}, nil
+}
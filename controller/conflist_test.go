@@ -0,0 +1,162 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/containernetworking/cni/libcni"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NetworkConfigList", func() {
+	var listBytes []byte
+
+	BeforeEach(func() {
+		listBytes = []byte(`{
+			"cniVersion": "0.2.0",
+			"name": "some-chain",
+			"plugins": [
+				{ "type": "bridge", "bridge": "cni0" },
+				{ "type": "portmap" }
+			]
+		}`)
+	})
+
+	Describe("ConfListFromBytes", func() {
+		It("injects the list name and cniVersion into each plugin", func() {
+			list, err := controller.ConfListFromBytes(listBytes)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(list.Name).To(Equal("some-chain"))
+			Expect(list.CNIVersion).To(Equal("0.2.0"))
+			Expect(list.Plugins).To(HaveLen(2))
+
+			Expect(list.Plugins[0].Network.Name).To(Equal("some-chain"))
+			Expect(list.Plugins[0].Network.Type).To(Equal("bridge"))
+			Expect(list.Plugins[0].Bytes).To(MatchJSON(`{
+				"cniVersion": "0.2.0",
+				"name": "some-chain",
+				"type": "bridge",
+				"bridge": "cni0"
+			}`))
+
+			Expect(list.Plugins[1].Network.Type).To(Equal("portmap"))
+		})
+
+		Context("when the list has no name", func() {
+			It("returns an error", func() {
+				_, err := controller.ConfListFromBytes([]byte(`{"plugins": [{"type": "bridge"}]}`))
+				Expect(err).To(MatchError("error parsing configuration list: no name"))
+			})
+		})
+
+		Context("when the list has no plugins", func() {
+			It("returns an error", func() {
+				_, err := controller.ConfListFromBytes([]byte(`{"name": "some-chain", "plugins": []}`))
+				Expect(err).To(MatchError("error parsing configuration list: no plugins in list"))
+			})
+		})
+
+		Context("when the plugins field is not a list", func() {
+			It("returns an error", func() {
+				_, err := controller.ConfListFromBytes([]byte(`{"name": "some-chain", "plugins": "banana"}`))
+				Expect(err).To(MatchError("error parsing configuration list: invalid 'plugins' type string"))
+			})
+		})
+
+		Context("when the bytes are malformed JSON", func() {
+			It("returns an error", func() {
+				_, err := controller.ConfListFromBytes([]byte(`%%%`))
+				Expect(err).To(MatchError(ContainSubstring("error parsing configuration list")))
+			})
+		})
+	})
+
+	Describe("ConfListFromFile", func() {
+		It("loads the list from disk", func() {
+			dir, err := ioutil.TempDir("", "conflist-")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "10-chain.conflist")
+			Expect(ioutil.WriteFile(path, listBytes, 0600)).To(Succeed())
+
+			list, err := controller.ConfListFromFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Plugins).To(HaveLen(2))
+		})
+
+		Context("when the file does not exist", func() {
+			It("returns an error", func() {
+				_, err := controller.ConfListFromFile("/some/missing/file.conflist")
+				Expect(err).To(MatchError(HavePrefix("error reading /some/missing/file.conflist")))
+			})
+		})
+	})
+
+	Describe("AppendNetworkSpecToList", func() {
+		var list *controller.NetworkConfigList
+
+		BeforeEach(func() {
+			var err error
+			list, err = controller.ConfListFromBytes(listBytes)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("inserts the garden network properties into every plugin", func() {
+			enhancedList, err := controller.AppendNetworkSpecToList(list, `{"key": "value"}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(enhancedList.Plugins).To(HaveLen(2))
+
+			for _, plugin := range enhancedList.Plugins {
+				var config map[string]interface{}
+				Expect(json.Unmarshal(plugin.Bytes, &config)).To(Succeed())
+				Expect(config).To(HaveKeyWithValue("network", map[string]interface{}{
+					"properties": map[string]interface{}{"key": "value"},
+				}))
+			}
+		})
+
+		Context("when the list sets skip_without_network and no spec is given", func() {
+			It("returns nil", func() {
+				list.Bytes = []byte(`{"name": "some-chain", "skip_without_network": true}`)
+				enhancedList, err := controller.AppendNetworkSpecToList(list, "")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(enhancedList).To(BeNil())
+			})
+		})
+
+		Context("when a single plugin sets skip_without_network and no spec is given", func() {
+			It("drops that plugin from the chain", func() {
+				list.Plugins[1].Bytes = []byte(`{"name": "some-chain", "type": "portmap", "skip_without_network": true}`)
+				enhancedList, err := controller.AppendNetworkSpecToList(list, "")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(enhancedList.Plugins).To(HaveLen(1))
+				Expect(enhancedList.Plugins[0].Network.Type).To(Equal("bridge"))
+			})
+		})
+
+		Context("when a plugin config is malformed", func() {
+			It("returns an error", func() {
+				list.Plugins[0].Bytes = []byte(`%%%`)
+				_, err := controller.AppendNetworkSpecToList(list, "")
+				Expect(err).To(MatchError(ContainSubstring("unmarshal existing network bytes")))
+			})
+		})
+	})
+
+	Describe("ConfListFromConf", func() {
+		It("wraps a single network config", func() {
+			conf, err := libcni.ConfFromBytes([]byte(`{"name": "some-net", "type": "bridge"}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			list := controller.ConfListFromConf(conf)
+			Expect(list.Name).To(Equal("some-net"))
+			Expect(list.Plugins).To(Equal([]*libcni.NetworkConfig{conf}))
+		})
+	})
+})
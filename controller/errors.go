@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// MultiError collects every failure of a best-effort operation.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	messages := make([]string, len(m))
+	for i, err := range m {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the first failure, which classifies the whole operation.
+func (m MultiError) Unwrap() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m[0]
+}
+
+func (m MultiError) errorOrNil() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// ErrorKind classifies failures so that callers can react to them
+// differently, for instance by retrying timeouts but not config errors.
+type ErrorKind string
+
+const (
+	ErrorKindInternal ErrorKind = "internal"
+	ErrorKindConfig   ErrorKind = "config"
+	ErrorKindMount    ErrorKind = "mount"
+	ErrorKindPlugin   ErrorKind = "plugin"
+	ErrorKindTimeout  ErrorKind = "timeout"
+)
+
+// KindError classifies an error that is not otherwise recognizable, such as
+// a config or mount error.
+type KindError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *KindError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *KindError) Unwrap() error {
+	return e.Err
+}
+
+// KindOf classifies an error by the first failure it wraps.  Unclassified
+// errors are internal.
+func KindOf(err error) ErrorKind {
+	var report *ErrorReport
+	var timeoutErr *TimeoutError
+	var pluginErr *PluginError
+	var kindErr *KindError
+
+	switch {
+	case errors.As(err, &report):
+		return report.Kind
+	case errors.As(err, &timeoutErr):
+		return ErrorKindTimeout
+	case errors.As(err, &pluginErr):
+		return ErrorKindPlugin
+	case errors.As(err, &kindErr):
+		return kindErr.Kind
+	default:
+		return ErrorKindInternal
+	}
+}
+
+// ErrorReport is the machine-readable form of an error, identifying the
+// network and plugin that failed and preserving the plugin's CNI error.  It
+// is itself an error, so that a report received from the daemon reads and
+// classifies like the original.
+type ErrorReport struct {
+	Kind     ErrorKind    `json:"kind"`
+	Msg      string       `json:"msg"`
+	Network  string       `json:"network,omitempty"`
+	Plugin   string       `json:"plugin,omitempty"`
+	CNIError *types.Error `json:"cni_error,omitempty"`
+}
+
+func (r *ErrorReport) Error() string {
+	return r.Msg
+}
+
+func NewErrorReport(err error) *ErrorReport {
+	report := &ErrorReport{}
+
+	var received *ErrorReport
+	var timeoutErr *TimeoutError
+	var pluginErr *PluginError
+
+	switch {
+	case errors.As(err, &received):
+		*report = *received
+	case errors.As(err, &timeoutErr):
+		report.Network = timeoutErr.Network
+		report.Plugin = timeoutErr.Plugin
+	case errors.As(err, &pluginErr):
+		report.Network = pluginErr.Network
+		report.Plugin = pluginErr.Plugin
+		report.CNIError = &types.Error{
+			Code:    pluginErr.Code,
+			Msg:     pluginErr.Msg,
+			Details: pluginErr.Details,
+		}
+	}
+
+	report.Kind = KindOf(err)
+	report.Msg = err.Error()
+	return report
+}
@@ -0,0 +1,84 @@
+package controller_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MultiError", func() {
+	It("joins every error message", func() {
+		err := controller.MultiError{errors.New("boom"), errors.New("bang")}
+		Expect(err).To(MatchError("boom; bang"))
+	})
+
+	Context("when there is a single error", func() {
+		It("reads like that error", func() {
+			err := controller.MultiError{errors.New("boom")}
+			Expect(err).To(MatchError("boom"))
+		})
+	})
+
+	It("is classified by its first error", func() {
+		err := controller.MultiError{
+			&controller.KindError{Kind: controller.ErrorKindMount, Err: errors.New("boom")},
+			&controller.PluginError{Msg: "bang"},
+		}
+		Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindMount))
+	})
+})
+
+var _ = Describe("KindOf", func() {
+	It("classifies wrapped errors", func() {
+		Expect(controller.KindOf(fmt.Errorf("up: %w", &controller.TimeoutError{}))).To(Equal(controller.ErrorKindTimeout))
+		Expect(controller.KindOf(fmt.Errorf("up: %w", &controller.PluginError{}))).To(Equal(controller.ErrorKindPlugin))
+		Expect(controller.KindOf(fmt.Errorf("up: %w", &controller.KindError{Kind: controller.ErrorKindConfig, Err: errors.New("boom")}))).To(Equal(controller.ErrorKindConfig))
+		Expect(controller.KindOf(fmt.Errorf("up: %w", &controller.ErrorReport{Kind: controller.ErrorKindMount}))).To(Equal(controller.ErrorKindMount))
+	})
+
+	It("classifies anything else as internal", func() {
+		Expect(controller.KindOf(errors.New("boom"))).To(Equal(controller.ErrorKindInternal))
+		Expect(controller.KindOf(fmt.Errorf("up: %s", &controller.PluginError{}))).To(Equal(controller.ErrorKindInternal))
+	})
+})
+
+var _ = Describe("NewErrorReport", func() {
+	It("preserves the failed plugin's CNI error", func() {
+		err := fmt.Errorf("up failed: %w", &controller.PluginError{
+			Code:    11,
+			Msg:     "busy",
+			Details: "try later",
+			Network: "some-net",
+			Plugin:  "some-plugin",
+		})
+
+		Expect(controller.NewErrorReport(err)).To(Equal(&controller.ErrorReport{
+			Kind:     controller.ErrorKindPlugin,
+			Msg:      "up failed: busy; try later",
+			Network:  "some-net",
+			Plugin:   "some-plugin",
+			CNIError: &types.Error{Code: 11, Msg: "busy", Details: "try later"},
+		}))
+	})
+
+	It("identifies the plugin that timed out", func() {
+		err := fmt.Errorf("up failed: %w", &controller.TimeoutError{Command: "ADD", Network: "some-net", Plugin: "some-plugin"})
+
+		report := controller.NewErrorReport(err)
+		Expect(report.Kind).To(Equal(controller.ErrorKindTimeout))
+		Expect(report.Network).To(Equal("some-net"))
+		Expect(report.Plugin).To(Equal("some-plugin"))
+		Expect(report.CNIError).To(BeNil())
+	})
+
+	It("keeps the details of a received report, but not its message", func() {
+		received := &controller.ErrorReport{Kind: controller.ErrorKindPlugin, Msg: "busy", Network: "some-net"}
+
+		report := controller.NewErrorReport(fmt.Errorf("up failed: %w", received))
+		Expect(report).To(Equal(&controller.ErrorReport{Kind: controller.ErrorKindPlugin, Msg: "up failed: busy", Network: "some-net"}))
+	})
+})
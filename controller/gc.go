@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GCReport lists the orphaned containers found by GC: those with a bind
+// mount or recorded state that are not live.  Orphans whose state changed
+// more recently than the minimum age may still be coming up, so are skipped.
+type GCReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Orphans   []string          `json:"orphans"`
+	Skipped   []string          `json:"skipped,omitempty"`
+	Collected []string          `json:"collected"`
+	Failed    map[string]string `json:"failed,omitempty"`
+}
+
+// ParseHandleList splits a comma separated list of handles, such as the live
+// handles given to gc, ignoring spaces around each and empty entries.
+func ParseHandleList(list string) []string {
+	handles := []string{}
+	for _, handle := range strings.Split(list, ",") {
+		handle = strings.TrimSpace(handle)
+		if handle != "" {
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// GC tears down every orphaned container, as down would, unless dryRun is
+// set, in which case it only reports them.  Orphans without recorded state
+// are torn down against the current configs, as if they had no properties.
+func (m *Manager) GC(ctx context.Context, liveHandles []string, minAge time.Duration, dryRun bool) (*GCReport, error) {
+	orphans, err := m.findOrphans(liveHandles)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{
+		DryRun:    dryRun,
+		Orphans:   orphans,
+		Collected: []string{},
+	}
+
+	for _, handle := range orphans {
+		state, err := m.StateStore.Load(handle)
+		if err == nil && state != nil && time.Since(state.UpdatedAt) < minAge {
+			report.Skipped = append(report.Skipped, handle)
+			continue
+		}
+
+		if dryRun {
+			continue
+		}
+
+		err = m.collect(ctx, handle)
+		if err != nil {
+			if report.Failed == nil {
+				report.Failed = make(map[string]string)
+			}
+			report.Failed[handle] = err.Error()
+			continue
+		}
+
+		report.Collected = append(report.Collected, handle)
+	}
+
+	if len(report.Failed) > 0 {
+		failed := []string{}
+		for handle := range report.Failed {
+			failed = append(failed, handle)
+		}
+		sort.Strings(failed)
+		return report, fmt.Errorf("failed collecting: %s", strings.Join(failed, ", "))
+	}
+
+	return report, nil
+}
+
+func (m *Manager) collect(ctx context.Context, containerHandle string) error {
+	err := m.Locker.Lock(containerHandle)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(ctx, containerHandle)
+
+	return m.teardown(ctx, containerHandle, "", "", true)
+}
+
+// findOrphans returns, sorted, the known handles that are not live.
+func (m *Manager) findOrphans(liveHandles []string) ([]string, error) {
+	live := make(map[string]bool)
+	for _, handle := range liveHandles {
+		live[handle] = true
+	}
+
+	handles, err := m.knownHandles()
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []string{}
+	for _, handle := range handles {
+		if !live[handle] {
+			orphans = append(orphans, handle)
+		}
+	}
+
+	return orphans, nil
+}
+
+// knownHandles returns, sorted, the handles with a bind mount or recorded
+// state.  Hidden entries of the bind mount root, such as the lock directory,
+// are not bind mounts.
+func (m *Manager) knownHandles() ([]string, error) {
+	known := make(map[string]bool)
+
+	entries, err := ioutil.ReadDir(m.BindMountRoot)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading bind mount root: %s", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		known[entry.Name()] = true
+	}
+
+	stateHandles, err := m.StateStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing state: %s", err)
+	}
+	for _, handle := range stateHandles {
+		known[handle] = true
+	}
+
+	handles := []string{}
+	for handle := range known {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+
+	return handles, nil
+}
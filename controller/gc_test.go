@@ -0,0 +1,168 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseHandleList", func() {
+	It("trims each handle and drops empty ones", func() {
+		Expect(controller.ParseHandleList(" h1, h2 ,,h3,")).To(Equal([]string{"h1", "h2", "h3"}))
+	})
+
+	It("returns an empty list for a blank one", func() {
+		Expect(controller.ParseHandleList(" ")).To(BeEmpty())
+	})
+})
+
+var _ = Describe("GC", func() {
+	var (
+		manager       *controller.Manager
+		cniController *fakes.CNIController
+		mounter       *fakes.Mounter
+		stateStore    *fakes.StateStore
+		locker        *fakes.Locker
+		bindMountRoot string
+		states        map[string]*controller.ContainerState
+	)
+
+	BeforeEach(func() {
+		var err error
+		bindMountRoot, err = ioutil.TempDir("", "bind-mount-root-")
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, handle := range []string{"live-handle", "orphan-handle"} {
+			Expect(ioutil.WriteFile(filepath.Join(bindMountRoot, handle), nil, 0600)).To(Succeed())
+		}
+		Expect(os.Mkdir(filepath.Join(bindMountRoot, ".locks"), 0700)).To(Succeed())
+
+		states = map[string]*controller.ContainerState{
+			"live-handle":   {Handle: "live-handle"},
+			"orphan-handle": {Handle: "orphan-handle", Attachments: []controller.NetworkAttachment{{Network: "some-net"}}},
+			"stale-handle":  {Handle: "stale-handle"},
+		}
+
+		mounter = &fakes.Mounter{}
+		cniController = &fakes.CNIController{}
+		stateStore = &fakes.StateStore{}
+		stateStore.ListReturns([]string{"live-handle", "orphan-handle", "stale-handle"}, nil)
+		stateStore.LoadStub = func(handle string) (*controller.ContainerState, error) {
+			return states[handle], nil
+		}
+		locker = &fakes.Locker{}
+		manager = &controller.Manager{
+			CNIController: cniController,
+			Mounter:       mounter,
+			StateStore:    stateStore,
+			Locker:        locker,
+			BindMountRoot: bindMountRoot,
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(bindMountRoot)).To(Succeed())
+	})
+
+	It("tears down every container with a bind mount or state that is not live", func() {
+		report, err := manager.GC(context.Background(), []string{"live-handle"}, 0, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report).To(Equal(&controller.GCReport{
+			Orphans:   []string{"orphan-handle", "stale-handle"},
+			Collected: []string{"orphan-handle", "stale-handle"},
+		}))
+
+		Expect(cniController.DownCallCount()).To(Equal(2))
+		_, namespacePath, handle, _, _, attachments := cniController.DownArgsForCall(0)
+		Expect(namespacePath).To(Equal(filepath.Join(bindMountRoot, "orphan-handle")))
+		Expect(handle).To(Equal("orphan-handle"))
+		Expect(attachments).To(Equal(states["orphan-handle"].Attachments))
+
+		By("only removing the bind mounts that exist")
+		Expect(mounter.RemoveMountCallCount()).To(Equal(1))
+		Expect(mounter.RemoveMountArgsForCall(0)).To(Equal(filepath.Join(bindMountRoot, "orphan-handle")))
+
+		Expect(stateStore.DeleteCallCount()).To(Equal(2))
+		Expect(stateStore.DeleteArgsForCall(0)).To(Equal("orphan-handle"))
+		Expect(stateStore.DeleteArgsForCall(1)).To(Equal("stale-handle"))
+
+		Expect(locker.LockCallCount()).To(Equal(2))
+		Expect(locker.UnlockCallCount()).To(Equal(2))
+	})
+
+	Context("when dry running", func() {
+		It("reports the orphans without tearing anything down", func() {
+			report, err := manager.GC(context.Background(), []string{"live-handle"}, 0, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report).To(Equal(&controller.GCReport{
+				DryRun:    true,
+				Orphans:   []string{"orphan-handle", "stale-handle"},
+				Collected: []string{},
+			}))
+
+			Expect(cniController.DownCallCount()).To(Equal(0))
+			Expect(mounter.RemoveMountCallCount()).To(Equal(0))
+			Expect(stateStore.DeleteCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when an orphan's state changed more recently than the minimum age", func() {
+		It("skips it, as it may still be coming up", func() {
+			states["orphan-handle"].UpdatedAt = time.Now()
+			states["stale-handle"].UpdatedAt = time.Now().Add(-time.Hour)
+
+			report, err := manager.GC(context.Background(), []string{"live-handle"}, time.Minute, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Skipped).To(Equal([]string{"orphan-handle"}))
+			Expect(report.Collected).To(Equal([]string{"stale-handle"}))
+		})
+	})
+
+	Context("when the bind mount root does not exist", func() {
+		It("only considers the recorded state", func() {
+			manager.BindMountRoot = filepath.Join(bindMountRoot, "missing")
+			stateStore.ListReturns([]string{"stale-handle"}, nil)
+
+			report, err := manager.GC(context.Background(), nil, 0, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Collected).To(Equal([]string{"stale-handle"}))
+		})
+	})
+
+	Context("when listing the state fails", func() {
+		It("returns the error", func() {
+			stateStore.ListReturns(nil, errors.New("banana"))
+
+			_, err := manager.GC(context.Background(), nil, 0, false)
+			Expect(err).To(MatchError("listing state: banana"))
+		})
+	})
+
+	Context("when an orphan cannot be torn down", func() {
+		It("carries on with the rest and reports the failure", func() {
+			cniController.DownStub = func(_ context.Context, _, handle, _, _ string, _ []controller.NetworkAttachment) error {
+				if handle == "orphan-handle" {
+					return errors.New("banana")
+				}
+				return nil
+			}
+
+			report, err := manager.GC(context.Background(), []string{"live-handle"}, 0, false)
+			Expect(err).To(MatchError("failed collecting: orphan-handle"))
+			Expect(report.Collected).To(Equal([]string{"stale-handle"}))
+			Expect(report.Failed).To(HaveKeyWithValue("orphan-handle", ContainSubstring("cni down failed: banana")))
+
+			By("keeping its state so that it can be retried")
+			Expect(stateStore.DeleteCallCount()).To(Equal(1))
+			Expect(stateStore.DeleteArgsForCall(0)).To(Equal("stale-handle"))
+		})
+	})
+})
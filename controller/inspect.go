@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ContainerInfo describes what the adapter knows of a container: its netns
+// bind mount and the networks recorded in its state.  A container with a
+// bind mount but no state, such as one left behind by an older version, has
+// no status or networks.  A container that could not be inspected has only
+// an error.
+type ContainerInfo struct {
+	Handle    string        `json:"handle"`
+	Pid       int           `json:"pid,omitempty"`
+	NetNSPath string        `json:"netns_path"`
+	Mounted   bool          `json:"mounted"`
+	Status    string        `json:"status,omitempty"`
+	Networks  []NetworkInfo `json:"networks"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type NetworkInfo struct {
+	Network   string     `json:"network"`
+	Interface string     `json:"interface"`
+	IPs       []IPResult `json:"ips"`
+}
+
+// unknownContainerError is returned for a handle with neither a bind mount
+// nor recorded state.
+type unknownContainerError struct {
+	handle string
+}
+
+func (e *unknownContainerError) Error() string {
+	return fmt.Sprintf("no state recorded for %s", e.handle)
+}
+
+// List describes every container with a bind mount or recorded state, sorted
+// by handle.  It does not take any locks, so may see an up or down in
+// progress: a container torn down since it was found is left out.  A
+// container that cannot be inspected is listed with the error, rather than
+// failing the list.
+func (m *Manager) List() ([]ContainerInfo, error) {
+	handles, err := m.knownHandles()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []ContainerInfo{}
+	for _, handle := range handles {
+		info, err := m.Inspect(handle)
+		var unknown *unknownContainerError
+		if errors.As(err, &unknown) {
+			continue
+		}
+		if err != nil {
+			info = &ContainerInfo{
+				Handle:    handle,
+				NetNSPath: filepath.Join(m.BindMountRoot, handle),
+				Networks:  []NetworkInfo{},
+				Error:     err.Error(),
+			}
+		}
+		infos = append(infos, *info)
+	}
+
+	return infos, nil
+}
+
+func (m *Manager) Inspect(containerHandle string) (*ContainerInfo, error) {
+	bindMountPath := filepath.Join(m.BindMountRoot, containerHandle)
+
+	state, err := m.StateStore.Load(containerHandle)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading state: %s", err)
+	}
+
+	mounted, err := m.Mounter.IsMounted(bindMountPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed inspecting mount %s: %s", bindMountPath, err)
+	}
+
+	info := &ContainerInfo{
+		Handle:    containerHandle,
+		NetNSPath: bindMountPath,
+		Mounted:   mounted,
+		Networks:  []NetworkInfo{},
+	}
+
+	if state == nil {
+		if _, err := os.Lstat(bindMountPath); os.IsNotExist(err) {
+			return nil, &unknownContainerError{handle: containerHandle}
+		}
+		return info, nil
+	}
+
+	info.Pid = state.Pid
+	info.Status = state.Status
+	for _, attachment := range state.Attachments {
+		info.Networks = append(info.Networks, NetworkInfo{
+			Network:   attachment.Network,
+			Interface: attachment.Interface,
+			IPs:       attachment.Result.IPs,
+		})
+	}
+
+	return info, nil
+}
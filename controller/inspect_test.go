@@ -0,0 +1,202 @@
+package controller_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Inspect and List", func() {
+	var (
+		manager       *controller.Manager
+		mounter       *fakes.Mounter
+		stateStore    *fakes.StateStore
+		bindMountRoot string
+		states        map[string]*controller.ContainerState
+	)
+
+	BeforeEach(func() {
+		var err error
+		bindMountRoot, err = ioutil.TempDir("", "bind-mount-root-")
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, handle := range []string{"attached-handle", "unrecorded-handle"} {
+			Expect(ioutil.WriteFile(filepath.Join(bindMountRoot, handle), nil, 0600)).To(Succeed())
+		}
+
+		states = map[string]*controller.ContainerState{
+			"attached-handle": {
+				Handle:    "attached-handle",
+				Pid:       42,
+				NetNSPath: filepath.Join(bindMountRoot, "attached-handle"),
+				Status:    controller.StatusAttached,
+				Attachments: []controller.NetworkAttachment{
+					{
+						Network:   "some-net",
+						Interface: "eth0",
+						Result: controller.NetworkResult{
+							Interface: "eth0",
+							IPs:       []controller.IPResult{{Version: "4", Address: "169.254.1.2/24"}},
+						},
+					},
+				},
+			},
+			"unmounted-handle": {
+				Handle: "unmounted-handle",
+				Pid:    43,
+				Status: controller.StatusAttaching,
+			},
+		}
+
+		mounter = &fakes.Mounter{}
+		mounter.IsMountedStub = func(target string) (bool, error) {
+			return target == filepath.Join(bindMountRoot, "attached-handle"), nil
+		}
+		stateStore = &fakes.StateStore{}
+		stateStore.ListReturns([]string{"attached-handle", "unmounted-handle"}, nil)
+		stateStore.LoadStub = func(handle string) (*controller.ContainerState, error) {
+			return states[handle], nil
+		}
+		manager = &controller.Manager{
+			CNIController: &fakes.CNIController{},
+			Mounter:       mounter,
+			StateStore:    stateStore,
+			Locker:        &fakes.Locker{},
+			BindMountRoot: bindMountRoot,
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(bindMountRoot)).To(Succeed())
+	})
+
+	Describe("Inspect", func() {
+		It("describes the container's mount and networks", func() {
+			info, err := manager.Inspect("attached-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(Equal(&controller.ContainerInfo{
+				Handle:    "attached-handle",
+				Pid:       42,
+				NetNSPath: filepath.Join(bindMountRoot, "attached-handle"),
+				Mounted:   true,
+				Status:    controller.StatusAttached,
+				Networks: []controller.NetworkInfo{
+					{
+						Network:   "some-net",
+						Interface: "eth0",
+						IPs:       []controller.IPResult{{Version: "4", Address: "169.254.1.2/24"}},
+					},
+				},
+			}))
+		})
+
+		Context("when the container has a bind mount but no state", func() {
+			It("describes only the mount", func() {
+				info, err := manager.Inspect("unrecorded-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info).To(Equal(&controller.ContainerInfo{
+					Handle:    "unrecorded-handle",
+					NetNSPath: filepath.Join(bindMountRoot, "unrecorded-handle"),
+					Networks:  []controller.NetworkInfo{},
+				}))
+			})
+		})
+
+		Context("when the container is unknown", func() {
+			It("returns an error", func() {
+				_, err := manager.Inspect("missing-handle")
+				Expect(err).To(MatchError("no state recorded for missing-handle"))
+			})
+		})
+
+		Context("when loading the state fails", func() {
+			It("returns the error", func() {
+				stateStore.LoadStub = nil
+				stateStore.LoadReturns(nil, errors.New("banana"))
+
+				_, err := manager.Inspect("attached-handle")
+				Expect(err).To(MatchError("failed loading state: banana"))
+			})
+		})
+
+		Context("when the mount cannot be inspected", func() {
+			It("returns the error", func() {
+				mounter.IsMountedStub = nil
+				mounter.IsMountedReturns(false, errors.New("banana"))
+
+				_, err := manager.Inspect("attached-handle")
+				Expect(err).To(MatchError(HaveSuffix(": banana")))
+			})
+		})
+	})
+
+	Describe("List", func() {
+		It("describes every container with a bind mount or state, by handle", func() {
+			infos, err := manager.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(infos).To(HaveLen(3))
+
+			Expect(infos[0].Handle).To(Equal("attached-handle"))
+			Expect(infos[0].Mounted).To(BeTrue())
+			Expect(infos[0].Networks).To(HaveLen(1))
+
+			Expect(infos[1].Handle).To(Equal("unmounted-handle"))
+			Expect(infos[1].Mounted).To(BeFalse())
+			Expect(infos[1].Status).To(Equal(controller.StatusAttaching))
+
+			Expect(infos[2].Handle).To(Equal("unrecorded-handle"))
+			Expect(infos[2].Status).To(BeEmpty())
+		})
+
+		Context("when a container is torn down while listing", func() {
+			It("leaves it out", func() {
+				stateStore.ListReturns([]string{"attached-handle", "unmounted-handle", "torn-down-handle"}, nil)
+
+				infos, err := manager.List()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(infos).To(HaveLen(3))
+				Expect(infos[0].Handle).To(Equal("attached-handle"))
+				Expect(infos[1].Handle).To(Equal("unmounted-handle"))
+				Expect(infos[2].Handle).To(Equal("unrecorded-handle"))
+			})
+		})
+
+		Context("when a container cannot be inspected", func() {
+			It("lists it with the error, and the rest as usual", func() {
+				stateStore.LoadStub = func(handle string) (*controller.ContainerState, error) {
+					if handle == "unmounted-handle" {
+						return nil, errors.New("banana")
+					}
+					return states[handle], nil
+				}
+
+				infos, err := manager.List()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(infos).To(HaveLen(3))
+				Expect(infos[0].Networks).To(HaveLen(1))
+				Expect(infos[1]).To(Equal(controller.ContainerInfo{
+					Handle:    "unmounted-handle",
+					NetNSPath: filepath.Join(bindMountRoot, "unmounted-handle"),
+					Networks:  []controller.NetworkInfo{},
+					Error:     "failed loading state: banana",
+				}))
+				Expect(infos[2].Error).To(BeEmpty())
+			})
+		})
+
+		Context("when there are no containers", func() {
+			It("returns an empty list", func() {
+				manager.BindMountRoot = filepath.Join(bindMountRoot, "missing")
+				stateStore.ListReturns(nil, nil)
+
+				Expect(manager.List()).To(Equal([]controller.ContainerInfo{}))
+			})
+		})
+	})
+})
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultInterfaceTemplate names interfaces after the position of their
+// network among the loaded networks, which was the only behaviour before
+// networks could declare an interface.
+const DefaultInterfaceTemplate = "eth{{.Index}}"
+
+// maxInterfaceNameLength is IFNAMSIZ less the trailing NUL.
+const maxInterfaceNameLength = 15
+
+type interfaceNameData struct {
+	Index int
+	Name  string
+}
+
+// InterfaceName resolves the interface a network attaches as, from the
+// network's "interface" field, which may be a literal name or a text/template
+// over the network's Name and Index.  The Index is the network's position
+// among the loaded networks, in the order they are attached, whether or not a
+// container joins the networks before it, so that a network's interface does
+// not depend on which others a container selects.
+func InterfaceName(networkConfigList *NetworkConfigList, index int) (string, error) {
+	var declared struct {
+		Interface string `json:"interface"`
+	}
+	err := json.Unmarshal(networkConfigList.Bytes, &declared)
+	if err != nil {
+		return "", fmt.Errorf("parsing interface for %s: %s", networkConfigList.Name, err)
+	}
+
+	nameTemplate := declared.Interface
+	if nameTemplate == "" {
+		nameTemplate = DefaultInterfaceTemplate
+	}
+
+	tmpl, err := template.New("interface").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("parsing interface template for %s: %s", networkConfigList.Name, err)
+	}
+
+	buffer := &bytes.Buffer{}
+	err = tmpl.Execute(buffer, interfaceNameData{Index: index, Name: networkConfigList.Name})
+	if err != nil {
+		return "", fmt.Errorf("rendering interface template for %s: %s", networkConfigList.Name, err)
+	}
+
+	ifName := buffer.String()
+	err = validateInterfaceName(ifName)
+	if err != nil {
+		return "", fmt.Errorf("invalid interface for %s: %s", networkConfigList.Name, err)
+	}
+
+	return ifName, nil
+}
+
+func validateInterfaceName(ifName string) error {
+	switch {
+	case ifName == "":
+		return fmt.Errorf("name is empty")
+	case len(ifName) > maxInterfaceNameLength:
+		return fmt.Errorf("%q is longer than %d characters", ifName, maxInterfaceNameLength)
+	case ifName == "." || ifName == "..":
+		return fmt.Errorf("%q is not a valid name", ifName)
+	case strings.ContainsAny(ifName, "/: \t\n"):
+		return fmt.Errorf("%q contains '/', ':' or whitespace", ifName)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package controller_test
+
+import (
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InterfaceName", func() {
+	var networkConfigList *controller.NetworkConfigList
+
+	BeforeEach(func() {
+		networkConfigList = &controller.NetworkConfigList{
+			Name:  "overlay",
+			Bytes: []byte(`{"name": "overlay", "type": "vxlan"}`),
+		}
+	})
+
+	It("defaults to the network's position in the config directory", func() {
+		Expect(controller.InterfaceName(networkConfigList, 3)).To(Equal("eth3"))
+	})
+
+	It("uses a literal interface declared by the network", func() {
+		networkConfigList.Bytes = []byte(`{"name": "overlay", "interface": "vx0"}`)
+		Expect(controller.InterfaceName(networkConfigList, 3)).To(Equal("vx0"))
+	})
+
+	It("renders a naming template declared by the network", func() {
+		networkConfigList.Bytes = []byte(`{"name": "overlay", "interface": "{{.Name}}{{.Index}}"}`)
+		Expect(controller.InterfaceName(networkConfigList, 3)).To(Equal("overlay3"))
+	})
+
+	Context("when the template is malformed", func() {
+		It("returns an error", func() {
+			networkConfigList.Bytes = []byte(`{"name": "overlay", "interface": "{{.Name"}`)
+			_, err := controller.InterfaceName(networkConfigList, 3)
+			Expect(err).To(MatchError(HavePrefix("parsing interface template for overlay")))
+		})
+	})
+
+	Context("when the template refers to an unknown field", func() {
+		It("returns an error", func() {
+			networkConfigList.Bytes = []byte(`{"name": "overlay", "interface": "{{.Banana}}"}`)
+			_, err := controller.InterfaceName(networkConfigList, 3)
+			Expect(err).To(MatchError(HavePrefix("rendering interface template for overlay")))
+		})
+	})
+
+	DescribeTable("invalid interface names",
+		func(ifName, expectedError string) {
+			networkConfigList.Bytes = []byte(`{"name": "overlay", "interface": "` + ifName + `"}`)
+			_, err := controller.InterfaceName(networkConfigList, 3)
+			Expect(err).To(MatchError("invalid interface for overlay: " + expectedError))
+		},
+		Entry("too long", "a-very-long-interface", `"a-very-long-interface" is longer than 15 characters`),
+		Entry("dot", ".", `"." is not a valid name`),
+		Entry("slash", "eth/0", `"eth/0" contains '/', ':' or whitespace`),
+		Entry("colon", "eth0:1", `"eth0:1" contains '/', ':' or whitespace`),
+		Entry("space", "eth 0", `"eth 0" contains '/', ':' or whitespace`),
+	)
+})
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// DefaultLockTimeout is how long up, down and check wait for another
+// operation on the same container when no timeout is configured.
+const DefaultLockTimeout = 30 * time.Second
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// Locker serializes operations on a container across processes, using an
+// flock on a file per handle in Dir.  The lock file is removed on unlock, so
+// a waiter that locks a file which has since been removed tries again.
+type Locker struct {
+	Dir     string
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+func (l *Locker) path(handle string) string {
+	return filepath.Join(l.Dir, handle)
+}
+
+func (l *Locker) Lock(handle string) error {
+	err := os.MkdirAll(l.Dir, 0700)
+	if err != nil {
+		return fmt.Errorf("os.MkdirAll failed: %s", err)
+	}
+
+	timeout := l.Timeout
+	if timeout == 0 {
+		timeout = DefaultLockTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		file, err := tryLock(l.path(handle))
+		if err != nil {
+			return err
+		}
+
+		if file != nil {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.files == nil {
+				l.files = make(map[string]*os.File)
+			}
+			l.files[handle] = file
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			err := fmt.Errorf("timed out after %s waiting for another up, down or check of %s to finish", timeout, handle)
+			return &KindError{Kind: ErrorKindTimeout, Err: err}
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// tryLock returns the locked file, or nil if the lock is held elsewhere or
+// the file was removed by its previous holder.
+func tryLock(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %s", err)
+	}
+
+	err = unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		file.Close()
+		return nil, nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("flock failed: %s", err) // not tested
+	}
+
+	lockedInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("stat lock file: %s", err) // not tested
+	}
+
+	pathInfo, err := os.Stat(path)
+	if err != nil || !os.SameFile(lockedInfo, pathInfo) {
+		file.Close()
+		return nil, nil
+	}
+
+	return file, nil
+}
+
+func (l *Locker) Unlock(handle string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, ok := l.files[handle]
+	if !ok {
+		return fmt.Errorf("no lock held for %s", handle)
+	}
+	delete(l.files, handle)
+
+	removeErr := os.Remove(l.path(handle))
+	closeErr := file.Close()
+	if removeErr != nil {
+		return fmt.Errorf("removing lock file: %s", removeErr) // not tested
+	}
+	if closeErr != nil {
+		return fmt.Errorf("closing lock file: %s", closeErr) // not tested
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package controller_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locker", func() {
+	var (
+		lockDir       string
+		locker        *controller.Locker
+		anotherLocker *controller.Locker
+	)
+
+	BeforeEach(func() {
+		var err error
+		lockDir, err = ioutil.TempDir("", "locks-")
+		Expect(err).NotTo(HaveOccurred())
+		lockDir = filepath.Join(lockDir, "not-yet-created")
+
+		locker = &controller.Locker{Dir: lockDir, Timeout: 200 * time.Millisecond}
+		anotherLocker = &controller.Locker{Dir: lockDir, Timeout: 200 * time.Millisecond}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(filepath.Dir(lockDir))).To(Succeed())
+	})
+
+	It("holds a lock file for the handle until unlocked", func() {
+		Expect(locker.Lock("some-handle")).To(Succeed())
+		Expect(filepath.Join(lockDir, "some-handle")).To(BeAnExistingFile())
+
+		Expect(locker.Unlock("some-handle")).To(Succeed())
+		Expect(filepath.Join(lockDir, "some-handle")).NotTo(BeAnExistingFile())
+	})
+
+	It("does not block other handles", func() {
+		Expect(locker.Lock("some-handle")).To(Succeed())
+		Expect(anotherLocker.Lock("some-other-handle")).To(Succeed())
+
+		Expect(locker.Unlock("some-handle")).To(Succeed())
+		Expect(anotherLocker.Unlock("some-other-handle")).To(Succeed())
+	})
+
+	Context("when the handle is already locked", func() {
+		BeforeEach(func() {
+			Expect(anotherLocker.Lock("some-handle")).To(Succeed())
+		})
+
+		It("times out with an error", func() {
+			err := locker.Lock("some-handle")
+			Expect(err).To(MatchError("timed out after 200ms waiting for another up, down or check of some-handle to finish"))
+
+			Expect(anotherLocker.Unlock("some-handle")).To(Succeed())
+		})
+
+		It("acquires the lock once it is released", func() {
+			locker.Timeout = 5 * time.Second
+			holder := anotherLocker
+			released := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(released)
+				time.Sleep(100 * time.Millisecond)
+				Expect(holder.Unlock("some-handle")).To(Succeed())
+			}()
+
+			Expect(locker.Lock("some-handle")).To(Succeed())
+			Expect(filepath.Join(lockDir, "some-handle")).To(BeAnExistingFile())
+
+			Eventually(released).Should(BeClosed())
+			Expect(locker.Unlock("some-handle")).To(Succeed())
+		})
+	})
+
+	Context("when unlocking a handle that is not locked", func() {
+		It("returns an error", func() {
+			Expect(locker.Unlock("some-handle")).To(MatchError("no lock held for some-handle"))
+		})
+	})
+})
@@ -1,69 +1,287 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
 )
 
 //go:generate counterfeiter -o ../fakes/cniController.go --fake-name CNIController . cniController
 type cniController interface {
-	Up(namespacePath, handle, spec string) error
-	Down(namespacePath, handle, spec string) error
+	Plan(ctx context.Context, gardenNetworkSpec, spec string) ([]NetworkAttachment, error)
+	Add(ctx context.Context, namespacePath, handle string, attachment NetworkAttachment) (NetworkResult, json.RawMessage, error)
+	Down(ctx context.Context, namespacePath, handle, gardenNetworkSpec, spec string, attachments []NetworkAttachment) error
+	Check(ctx context.Context, namespacePath, handle string, attachments []NetworkAttachment) (map[string]CheckResult, error)
 }
 
 //go:generate counterfeiter -o ../fakes/mounter.go --fake-name Mounter . mounter
 type mounter interface {
 	IdempotentlyMount(source, target string) error
 	RemoveMount(target string) error
+	IsMounted(target string) (bool, error)
+}
+
+//go:generate counterfeiter -o ../fakes/stateStore.go --fake-name StateStore . stateStore
+type stateStore interface {
+	Save(state *ContainerState) error
+	Load(handle string) (*ContainerState, error)
+	List() ([]string, error)
+	Delete(handle string) error
+	MoveAside(handle string) error
+}
+
+//go:generate counterfeiter -o ../fakes/locker.go --fake-name Locker . locker
+type locker interface {
+	Lock(handle string) error
+	Unlock(handle string) error
 }
 
 type Manager struct {
 	CNIController cniController
 	Mounter       mounter
+	StateStore    stateStore
+	Locker        locker
 	BindMountRoot string
 }
 
-func (m *Manager) Up(pid int, containerHandle, networkSpec string) error {
+func (m *Manager) unlock(ctx context.Context, containerHandle string) {
+	err := m.Locker.Unlock(containerHandle)
+	if err != nil {
+		logging.FromContext(ctx).Warn(fmt.Sprintf("failed releasing lock for %s", containerHandle), logging.Fields{"handle": containerHandle, "error": err})
+	}
+}
+
+// Up records the container's state before mounting its netns and before and
+// after adding each network, so that an interrupted up can still be torn
+// down.  It refuses to overwrite state left by an earlier up, which down must
+// clear first.  The context carries the request's span, if traced.
+func (m *Manager) Up(ctx context.Context, pid int, containerHandle, gardenNetworkSpec, networkSpec string) (*UpResult, error) {
 	if pid == 0 {
-		return errors.New("up missing pid")
+		return nil, &KindError{Kind: ErrorKindConfig, Err: errors.New("up missing pid")}
 	}
 	if containerHandle == "" {
-		return errors.New("up missing container handle")
+		return nil, &KindError{Kind: ErrorKindConfig, Err: errors.New("up missing container handle")}
+	}
+	if _, err := ParseGardenNetwork(gardenNetworkSpec); err != nil {
+		return nil, &KindError{Kind: ErrorKindConfig, Err: err}
+	}
+
+	err := m.Locker.Lock(containerHandle)
+	if err != nil {
+		return nil, err
+	}
+	defer m.unlock(ctx, containerHandle)
+
+	existingState, err := m.StateStore.Load(containerHandle)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading state: %s", err)
+	}
+	if existingState != nil {
+		return nil, &KindError{Kind: ErrorKindConfig, Err: fmt.Errorf("state already recorded for %s (%s): run down first", containerHandle, existingState.Status)}
 	}
 
 	procNsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
 	bindMountPath := filepath.Join(m.BindMountRoot, containerHandle)
 
-	err := m.Mounter.IdempotentlyMount(procNsPath, bindMountPath)
+	state := &ContainerState{
+		Handle:    containerHandle,
+		Pid:       pid,
+		NetNSPath: bindMountPath,
+		Status:    StatusAttaching,
+	}
+
+	err = m.StateStore.Save(state)
 	if err != nil {
-		return fmt.Errorf("failed mounting %s to %s: %s", procNsPath, bindMountPath, err)
+		return nil, fmt.Errorf("failed saving state: %s", err)
 	}
 
-	err = m.CNIController.Up(bindMountPath, containerHandle, networkSpec)
+	_, span := tracing.Start(ctx, "IdempotentlyMount", tracing.Attributes{"handle": containerHandle, "target": bindMountPath})
+	err = m.Mounter.IdempotentlyMount(procNsPath, bindMountPath)
+	span.Finish(err)
 	if err != nil {
-		return fmt.Errorf("cni up failed: %s", err)
+		mountErr := &KindError{Kind: ErrorKindMount, Err: fmt.Errorf("failed mounting %s to %s: %s", procNsPath, bindMountPath, err)}
+		err = m.StateStore.Delete(containerHandle)
+		if err != nil {
+			return nil, MultiError{mountErr, fmt.Errorf("rollback: failed deleting state: %s", err)}
+		}
+		return nil, mountErr
 	}
 
-	return nil
+	attachments, err := m.CNIController.Plan(ctx, gardenNetworkSpec, networkSpec)
+	if err != nil {
+		return nil, m.rollback(ctx, fmt.Errorf("cni up failed: %w", err), bindMountPath, containerHandle, nil)
+	}
+
+	for i := range attachments {
+		state.Attachments = attachments[:i+1]
+		err = m.StateStore.Save(state)
+		if err != nil {
+			return nil, m.rollback(ctx, fmt.Errorf("failed saving state: %s", err), bindMountPath, containerHandle, attachments[:i])
+		}
+
+		attachments[i].Result, attachments[i].RawResult, err = m.CNIController.Add(ctx, bindMountPath, containerHandle, attachments[i])
+		if err != nil {
+			return nil, m.rollback(ctx, fmt.Errorf("cni up failed: %w", err), bindMountPath, containerHandle, attachments[:i+1])
+		}
+
+		err = m.StateStore.Save(state)
+		if err != nil {
+			return nil, m.rollback(ctx, fmt.Errorf("failed saving state: %s", err), bindMountPath, containerHandle, attachments[:i+1])
+		}
+	}
+
+	state.Status = StatusAttached
+	err = m.StateStore.Save(state)
+	if err != nil {
+		return nil, m.rollback(ctx, fmt.Errorf("failed saving state: %s", err), bindMountPath, containerHandle, attachments)
+	}
+
+	return state.UpResult(), nil
 }
 
-func (m *Manager) Down(containerHandle string, networkSpec string) error {
+// rollback undoes a partially completed Up, deleting the given attachments
+// in reverse order and removing the bind mount.  It returns the original
+// error together with any errors encountered while rolling back.  The
+// container's state is only deleted if the rollback succeeds, so that a
+// failed rollback can be retried with down.
+func (m *Manager) rollback(ctx context.Context, upErr error, bindMountPath, containerHandle string, attachments []NetworkAttachment) error {
+	errs := MultiError{upErr}
+
+	if len(attachments) > 0 {
+		err := m.CNIController.Down(ctx, bindMountPath, containerHandle, "", "", attachments)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rollback: cni down failed: %s", err))
+		}
+	}
+
+	err := m.Mounter.RemoveMount(bindMountPath)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("rollback: failed removing mount %s: %s", bindMountPath, err))
+	}
+
+	if len(errs) > 1 {
+		return errs
+	}
+
+	err = m.StateStore.Delete(containerHandle)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("rollback: failed deleting state: %s", err))
+	}
+
+	return errs
+}
+
+// Down tears down the networks recorded in the container's state, or, if
+// there is none, those the current configs and specs select.  The state is
+// only deleted once every network and the mount have been removed.
+func (m *Manager) Down(ctx context.Context, containerHandle, gardenNetworkSpec, networkSpec string) error {
 	if containerHandle == "" {
-		return errors.New("down missing container handle")
+		return &KindError{Kind: ErrorKindConfig, Err: errors.New("down missing container handle")}
+	}
+
+	err := m.Locker.Lock(containerHandle)
+	if err != nil {
+		return err
 	}
+	defer m.unlock(ctx, containerHandle)
+
+	return m.teardown(ctx, containerHandle, gardenNetworkSpec, networkSpec, false)
+}
 
+// teardown must be called with the container's lock held.  If mountMayBeGone
+// is set, a missing bind mount is not an error.  State that cannot be loaded
+// is moved aside, so that it blocks neither this down nor the next up, and
+// the networks are derived as if none were recorded.
+func (m *Manager) teardown(ctx context.Context, containerHandle, gardenNetworkSpec, networkSpec string, mountMayBeGone bool) error {
 	bindMountPath := filepath.Join(m.BindMountRoot, containerHandle)
 
-	err := m.CNIController.Down(bindMountPath, containerHandle, networkSpec)
+	var errs MultiError
+
+	state, err := m.StateStore.Load(containerHandle)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed loading state, tearing down the networks the current configs select", logging.Fields{"handle": containerHandle, "error": err})
+		err = m.StateStore.MoveAside(containerHandle)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed moving state aside: %s", err))
+		}
+	}
+
+	var attachments []NetworkAttachment
+	if state != nil {
+		attachments = state.Attachments
+	}
+
+	err = m.CNIController.Down(ctx, bindMountPath, containerHandle, gardenNetworkSpec, networkSpec, attachments)
 	if err != nil {
-		return fmt.Errorf("cni down failed: %s", err)
+		errs = append(errs, fmt.Errorf("cni down failed: %w", err))
+	}
+
+	if _, err := os.Lstat(bindMountPath); !(mountMayBeGone && os.IsNotExist(err)) {
+		_, span := tracing.Start(ctx, "RemoveMount", tracing.Attributes{"handle": containerHandle, "target": bindMountPath})
+		err = m.Mounter.RemoveMount(bindMountPath)
+		span.Finish(err)
+		if err != nil {
+			errs = append(errs, &KindError{Kind: ErrorKindMount, Err: fmt.Errorf("failed removing mount %s: %s", bindMountPath, err)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
 	}
 
-	err = m.Mounter.RemoveMount(bindMountPath)
+	err = m.StateStore.Delete(containerHandle)
 	if err != nil {
-		return fmt.Errorf("failed removing mount %s: %s", bindMountPath, err)
+		return fmt.Errorf("failed deleting state: %s", err)
 	}
 
 	return nil
 }
+
+func (m *Manager) Check(ctx context.Context, containerHandle string) (map[string]CheckResult, error) {
+	if containerHandle == "" {
+		return nil, &KindError{Kind: ErrorKindConfig, Err: errors.New("check missing container handle")}
+	}
+
+	err := m.Locker.Lock(containerHandle)
+	if err != nil {
+		return nil, err
+	}
+	defer m.unlock(ctx, containerHandle)
+
+	bindMountPath := filepath.Join(m.BindMountRoot, containerHandle)
+
+	state, err := m.StateStore.Load(containerHandle)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading state: %s", err)
+	}
+	if state == nil {
+		return nil, fmt.Errorf("no state recorded for %s", containerHandle)
+	}
+
+	report, err := m.CNIController.Check(ctx, bindMountPath, containerHandle, state.Attachments)
+	if err != nil {
+		return nil, fmt.Errorf("cni check failed: %s", err)
+	}
+
+	failedNetworks := []string{}
+	for networkName, checkResult := range report {
+		if checkResult.Status == CheckStatusFailed {
+			failedNetworks = append(failedNetworks, networkName)
+		}
+	}
+	sort.Strings(failedNetworks)
+
+	if len(failedNetworks) > 0 {
+		return report, fmt.Errorf("networks failed check: %s", strings.Join(failedNetworks, ", "))
+	}
+
+	return report, nil
+}
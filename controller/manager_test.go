@@ -1,10 +1,13 @@
 package controller_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 
 	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
 	"github.com/cloudfoundry-incubator/guardian-cni-adapter/fakes"
+	"github.com/containernetworking/cni/pkg/types"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -14,21 +17,60 @@ var _ = Describe("Manager", func() {
 		manager       *controller.Manager
 		cniController *fakes.CNIController
 		mounter       *fakes.Mounter
+		stateStore    *fakes.StateStore
+		locker        *fakes.Locker
 	)
 
 	BeforeEach(func() {
 		mounter = &fakes.Mounter{}
 		cniController = &fakes.CNIController{}
+		stateStore = &fakes.StateStore{}
+		locker = &fakes.Locker{}
 		manager = &controller.Manager{
 			CNIController: cniController,
 			Mounter:       mounter,
+			StateStore:    stateStore,
+			Locker:        locker,
 			BindMountRoot: "/some/fake/path",
 		}
 	})
 
 	Describe("Up", func() {
+		var (
+			planned     []controller.NetworkAttachment
+			savedStates []controller.ContainerState
+		)
+
+		BeforeEach(func() {
+			planned = []controller.NetworkAttachment{
+				{
+					Network:   "some-net",
+					Interface: "eth0",
+					Configs:   []json.RawMessage{json.RawMessage(`{"name":"some-net","type":"some-plugin"}`)},
+				},
+				{
+					Network:   "some-other-net",
+					Interface: "eth1",
+					Configs:   []json.RawMessage{json.RawMessage(`{"name":"some-other-net","type":"some-plugin"}`)},
+				},
+			}
+			cniController.PlanReturns(planned, nil)
+			cniController.AddStub = func(_ context.Context, _, _ string, attachment controller.NetworkAttachment) (controller.NetworkResult, json.RawMessage, error) {
+				return controller.NetworkResult{Interface: attachment.Interface}, json.RawMessage(`{"interfaces":[{"name":"` + attachment.Interface + `"}]}`), nil
+			}
+
+			savedStates = nil
+			stateStore.SaveStub = func(state *controller.ContainerState) error {
+				snapshot := *state
+				snapshot.Attachments = append([]controller.NetworkAttachment{}, state.Attachments...)
+				savedStates = append(savedStates, snapshot)
+				return nil
+			}
+		})
+
 		It("should ensure that the netNS is mounted to the provided path", func() {
-			Expect(manager.Up(42, "some-container-handle", "some-network-spec")).To(Succeed())
+			_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+			Expect(err).NotTo(HaveOccurred())
 			Expect(mounter.IdempotentlyMountCallCount()).To(Equal(1))
 
 			source, target := mounter.IdempotentlyMountArgsForCall(0)
@@ -36,77 +78,380 @@ var _ = Describe("Manager", func() {
 			Expect(target).To(Equal("/some/fake/path/some-container-handle"))
 		})
 
-		It("should call CNI Up, passing in the bind-mounted path to the net ns", func() {
-			Expect(manager.Up(42, "some-container-handle", "some-network-spec")).To(Succeed())
-			Expect(cniController.UpCallCount()).To(Equal(1))
-			namespacePath, handle, spec := cniController.UpArgsForCall(0)
-			Expect(namespacePath).To(Equal("/some/fake/path/some-container-handle"))
-			Expect(handle).To(Equal("some-container-handle"))
+		It("should plan the networks from the specs", func() {
+			_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cniController.PlanCallCount()).To(Equal(1))
+			_, gardenNetworkSpec, spec := cniController.PlanArgsForCall(0)
+			Expect(gardenNetworkSpec).To(Equal("10.255.0.5/24"))
 			Expect(spec).To(Equal("some-network-spec"))
 		})
 
+		It("should add each planned network, passing in the bind-mounted path to the net ns", func() {
+			_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cniController.AddCallCount()).To(Equal(2))
+			for i := range planned {
+				_, namespacePath, handle, attachment := cniController.AddArgsForCall(i)
+				Expect(namespacePath).To(Equal("/some/fake/path/some-container-handle"))
+				Expect(handle).To(Equal("some-container-handle"))
+				Expect(attachment.Network).To(Equal(planned[i].Network))
+			}
+		})
+
+		It("should return the results keyed by network name", func() {
+			result, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(&controller.UpResult{
+				Handle: "some-container-handle",
+				Networks: map[string]controller.NetworkResult{
+					"some-net":       {Interface: "eth0"},
+					"some-other-net": {Interface: "eth1"},
+				},
+			}))
+		})
+
+		It("should record the state before mounting and before and after adding each network", func() {
+			mounter.IdempotentlyMountStub = func(string, string) error {
+				Expect(savedStates).To(HaveLen(1))
+				return nil
+			}
+
+			_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(savedStates).To(HaveLen(6))
+
+			for _, state := range savedStates {
+				Expect(state.Handle).To(Equal("some-container-handle"))
+				Expect(state.Pid).To(Equal(42))
+				Expect(state.NetNSPath).To(Equal("/some/fake/path/some-container-handle"))
+			}
+
+			Expect(savedStates[0].Status).To(Equal(controller.StatusAttaching))
+			Expect(savedStates[0].Attachments).To(BeEmpty())
+
+			Expect(savedStates[1].Attachments).To(HaveLen(1))
+			Expect(savedStates[1].Attachments[0].Result).To(BeZero())
+			Expect(savedStates[2].Attachments[0].Result).To(Equal(controller.NetworkResult{Interface: "eth0"}))
+
+			Expect(savedStates[3].Attachments).To(HaveLen(2))
+			Expect(savedStates[3].Attachments[1].Result).To(BeZero())
+			Expect(savedStates[4].Attachments[1].Result).To(Equal(controller.NetworkResult{Interface: "eth1"}))
+			Expect(savedStates[4].Status).To(Equal(controller.StatusAttaching))
+
+			Expect(savedStates[5].Status).To(Equal(controller.StatusAttached))
+			Expect(savedStates[5].Attachments).To(HaveLen(2))
+			Expect(savedStates[5].Attachments[1].RawResult).To(MatchJSON(`{"interfaces":[{"name":"eth1"}]}`))
+		})
+
+		It("should hold the container's lock while mounting and adding networks", func() {
+			cniController.AddStub = func(context.Context, string, string, controller.NetworkAttachment) (controller.NetworkResult, json.RawMessage, error) {
+				Expect(locker.LockCallCount()).To(Equal(1))
+				Expect(locker.UnlockCallCount()).To(Equal(0))
+				return controller.NetworkResult{}, nil, nil
+			}
+
+			_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locker.LockArgsForCall(0)).To(Equal("some-container-handle"))
+			Expect(locker.UnlockCallCount()).To(Equal(1))
+			Expect(locker.UnlockArgsForCall(0)).To(Equal("some-container-handle"))
+		})
+
+		Context("when the lock cannot be acquired", func() {
+			It("should return the error without mounting", func() {
+				locker.LockReturns(errors.New("timed out"))
+				_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+				Expect(err).To(MatchError("timed out"))
+				Expect(mounter.IdempotentlyMountCallCount()).To(Equal(0))
+				Expect(locker.UnlockCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when missing args", func() {
 			It("should return a friendly error", func() {
-				err := manager.Up(0, "some-container-handle", "some-network-spec")
+				_, err := manager.Up(context.Background(), 0, "some-container-handle", "10.255.0.5/24", "some-network-spec")
 				Expect(err).To(MatchError("up missing pid"))
+				Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindConfig))
 
-				err = manager.Up(42, "", "some-network-spec")
+				_, err = manager.Up(context.Background(), 42, "", "10.255.0.5/24", "some-network-spec")
 				Expect(err).To(MatchError("up missing container handle"))
+				Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindConfig))
+			})
+		})
+
+		Context("when the garden network spec is invalid", func() {
+			It("should return an error without mounting", func() {
+				_, err := manager.Up(context.Background(), 42, "some-container-handle", "banana", "some-network-spec")
+				Expect(err).To(MatchError(`invalid network spec "banana": must be an IP or CIDR`))
+				Expect(mounter.IdempotentlyMountCallCount()).To(Equal(0))
 			})
 		})
 
 		Context("when missing the network spec", func() {
 			It("should succeed", func() {
-				err := manager.Up(42, "some-container-handle", "")
+				_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "")
 				Expect(err).NotTo(HaveOccurred())
-				Expect(cniController.UpCallCount()).To(Equal(1))
-				namespacePath, handle, spec := cniController.UpArgsForCall(0)
-				Expect(namespacePath).To(Equal("/some/fake/path/some-container-handle"))
-				Expect(handle).To(Equal("some-container-handle"))
+				Expect(cniController.PlanCallCount()).To(Equal(1))
+				_, _, spec := cniController.PlanArgsForCall(0)
 				Expect(spec).To(BeEmpty())
 			})
 		})
 
+		Context("when state is already recorded for the container", func() {
+			BeforeEach(func() {
+				stateStore.LoadReturns(&controller.ContainerState{Handle: "some-container-handle", Status: controller.StatusAttaching}, nil)
+			})
+
+			It("should return an error without touching the state or mounting", func() {
+				_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+				Expect(err).To(MatchError("state already recorded for some-container-handle (attaching): run down first"))
+				Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindConfig))
+				Expect(stateStore.SaveCallCount()).To(Equal(0))
+				Expect(mounter.IdempotentlyMountCallCount()).To(Equal(0))
+				Expect(locker.UnlockCallCount()).To(Equal(1))
+			})
+		})
+
 		Context("when things fail", func() {
+			Context("when the state cannot be loaded", func() {
+				It("should return the error without mounting", func() {
+					stateStore.LoadReturns(nil, errors.New("pow"))
+					_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(err).To(MatchError("failed loading state: pow"))
+					Expect(mounter.IdempotentlyMountCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the initial state cannot be saved", func() {
+				It("should return the error without mounting", func() {
+					stateStore.SaveReturns(errors.New("pow"))
+					stateStore.SaveStub = nil
+
+					_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(err).To(MatchError("failed saving state: pow"))
+					Expect(mounter.IdempotentlyMountCallCount()).To(Equal(0))
+				})
+			})
+
 			Context("when the mounter fails", func() {
-				It("should return the error", func() {
+				BeforeEach(func() {
 					mounter.IdempotentlyMountReturns(errors.New("boom"))
-					err := manager.Up(42, "some-container-handle", "some-network-spec")
+				})
+
+				It("should return the error", func() {
+					_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
 					Expect(err).To(MatchError("failed mounting /proc/42/ns/net to /some/fake/path/some-container-handle: boom"))
+					Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindMount))
+				})
+
+				It("should delete the state without trying to unmount", func() {
+					manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(stateStore.DeleteCallCount()).To(Equal(1))
+					Expect(mounter.RemoveMountCallCount()).To(Equal(0))
+				})
+
+				Context("when deleting the state also fails", func() {
+					It("should return both errors", func() {
+						stateStore.DeleteReturns(errors.New("pow"))
+						_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+						Expect(err).To(MatchError("failed mounting /proc/42/ns/net to /some/fake/path/some-container-handle: boom; " +
+							"rollback: failed deleting state: pow"))
+						Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindMount))
+					})
+				})
+			})
+
+			Context("when planning fails", func() {
+				BeforeEach(func() {
+					cniController.PlanReturns(nil, errors.New("bang"))
+				})
+
+				It("should return the error", func() {
+					_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(err).To(MatchError("cni up failed: bang"))
+				})
+
+				It("should remove the mount and the state without calling CNI Down", func() {
+					manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(mounter.RemoveMountCallCount()).To(Equal(1))
+					Expect(mounter.RemoveMountArgsForCall(0)).To(Equal("/some/fake/path/some-container-handle"))
+					Expect(cniController.DownCallCount()).To(Equal(0))
+					Expect(stateStore.DeleteCallCount()).To(Equal(1))
+					Expect(stateStore.DeleteArgsForCall(0)).To(Equal("some-container-handle"))
 				})
 			})
 
-			Context("when the cni Up fails", func() {
+			Context("when adding a network fails", func() {
+				BeforeEach(func() {
+					cniController.AddStub = func(_ context.Context, _, _ string, attachment controller.NetworkAttachment) (controller.NetworkResult, json.RawMessage, error) {
+						if attachment.Network == "some-other-net" {
+							return controller.NetworkResult{}, nil, errors.New("bang")
+						}
+						return controller.NetworkResult{Interface: attachment.Interface}, nil, nil
+					}
+				})
+
 				It("should return the error", func() {
-					cniController.UpReturns(errors.New("bang"))
-					err := manager.Up(42, "some-container-handle", "some-network-spec")
+					_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
 					Expect(err).To(MatchError("cni up failed: bang"))
 				})
+
+				It("should preserve the plugin's error through the rollback", func() {
+					cniController.AddReturns(controller.NetworkResult{}, nil, &controller.PluginError{Code: 11, Msg: "busy"})
+					cniController.DownReturns(errors.New("pow"))
+
+					_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(err).To(MatchError(HavePrefix("cni up failed: busy; rollback: cni down failed: pow")))
+					Expect(controller.NewErrorReport(err).CNIError).To(Equal(&types.Error{Code: 11, Msg: "busy"}))
+				})
+
+				It("should roll back the attempted networks, the mount and the state", func() {
+					manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+
+					Expect(cniController.DownCallCount()).To(Equal(1))
+					_, namespacePath, handle, _, _, attachments := cniController.DownArgsForCall(0)
+					Expect(namespacePath).To(Equal("/some/fake/path/some-container-handle"))
+					Expect(handle).To(Equal("some-container-handle"))
+					Expect(attachments).To(HaveLen(2))
+					Expect(attachments[1].Network).To(Equal("some-other-net"))
+
+					Expect(mounter.RemoveMountCallCount()).To(Equal(1))
+					Expect(stateStore.DeleteCallCount()).To(Equal(1))
+				})
+
+				Context("when the rollback fails", func() {
+					BeforeEach(func() {
+						cniController.DownReturns(errors.New("whoops"))
+						mounter.RemoveMountReturns(errors.New("oops"))
+					})
+
+					It("should report the original error and every rollback error", func() {
+						_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+						Expect(err).To(MatchError("cni up failed: bang; " +
+							"rollback: cni down failed: whoops; " +
+							"rollback: failed removing mount /some/fake/path/some-container-handle: oops"))
+					})
+
+					It("should keep the state so that down can be retried", func() {
+						manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+						Expect(stateStore.DeleteCallCount()).To(Equal(0))
+					})
+				})
+			})
+
+			Context("when saving the state before adding a network fails", func() {
+				It("should roll back only the networks already attempted", func() {
+					stateStore.SaveStub = func(state *controller.ContainerState) error {
+						if len(state.Attachments) == 2 {
+							return errors.New("pow")
+						}
+						return nil
+					}
+
+					_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(err).To(MatchError("failed saving state: pow"))
+					Expect(cniController.AddCallCount()).To(Equal(1))
+
+					_, _, _, _, _, attachments := cniController.DownArgsForCall(0)
+					Expect(attachments).To(HaveLen(1))
+					Expect(attachments[0].Network).To(Equal("some-net"))
+				})
+			})
+
+			Context("when saving the final state fails", func() {
+				It("should roll back every network", func() {
+					stateStore.SaveStub = func(state *controller.ContainerState) error {
+						if state.Status == controller.StatusAttached {
+							return errors.New("pow")
+						}
+						return nil
+					}
+
+					_, err := manager.Up(context.Background(), 42, "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(err).To(MatchError("failed saving state: pow"))
+
+					_, _, _, _, _, attachments := cniController.DownArgsForCall(0)
+					Expect(attachments).To(HaveLen(2))
+					Expect(mounter.RemoveMountCallCount()).To(Equal(1))
+					Expect(stateStore.DeleteCallCount()).To(Equal(1))
+				})
 			})
 		})
 	})
 
 	Describe("Down", func() {
 		It("should ensure that the netNS is unmounted", func() {
-			Expect(manager.Down("some-container-handle", "some-network-spec")).To(Succeed())
+			Expect(manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")).To(Succeed())
 			Expect(mounter.RemoveMountCallCount()).To(Equal(1))
 
 			Expect(mounter.RemoveMountArgsForCall(0)).To(Equal("/some/fake/path/some-container-handle"))
 		})
 
 		It("should call CNI Down, passing in the bind-mounted path to the net ns", func() {
-			Expect(manager.Down("some-container-handle", "some-network-spec")).To(Succeed())
+			Expect(manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")).To(Succeed())
 			Expect(cniController.DownCallCount()).To(Equal(1))
-			namespacePath, handle, spec := cniController.DownArgsForCall(0)
+			_, namespacePath, handle, gardenNetworkSpec, spec, _ := cniController.DownArgsForCall(0)
 			Expect(namespacePath).To(Equal("/some/fake/path/some-container-handle"))
 			Expect(handle).To(Equal("some-container-handle"))
+			Expect(gardenNetworkSpec).To(Equal("10.255.0.5/24"))
 			Expect(spec).To(Equal("some-network-spec"))
 		})
 
+		It("should replay the attachments recorded in the container's state", func() {
+			attachments := []controller.NetworkAttachment{{Network: "some-net", Interface: "eth0"}}
+			stateStore.LoadReturns(&controller.ContainerState{Handle: "some-container-handle", Attachments: attachments}, nil)
+
+			Expect(manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")).To(Succeed())
+			Expect(stateStore.LoadCallCount()).To(Equal(1))
+			Expect(stateStore.LoadArgsForCall(0)).To(Equal("some-container-handle"))
+
+			_, _, _, _, _, downAttachments := cniController.DownArgsForCall(0)
+			Expect(downAttachments).To(Equal(attachments))
+		})
+
+		Context("when no state was recorded", func() {
+			It("should leave CNI Down to derive the networks from the current configs", func() {
+				stateStore.LoadReturns(nil, nil)
+
+				Expect(manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")).To(Succeed())
+				_, _, _, _, _, downAttachments := cniController.DownArgsForCall(0)
+				Expect(downAttachments).To(BeNil())
+			})
+		})
+
+		It("should delete the container's state", func() {
+			Expect(manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")).To(Succeed())
+			Expect(stateStore.DeleteCallCount()).To(Equal(1))
+			Expect(stateStore.DeleteArgsForCall(0)).To(Equal("some-container-handle"))
+		})
+
+		It("should hold the container's lock while tearing down", func() {
+			mounter.RemoveMountStub = func(string) error {
+				Expect(locker.LockCallCount()).To(Equal(1))
+				Expect(locker.UnlockCallCount()).To(Equal(0))
+				return nil
+			}
+
+			Expect(manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")).To(Succeed())
+			Expect(locker.LockArgsForCall(0)).To(Equal("some-container-handle"))
+			Expect(locker.UnlockArgsForCall(0)).To(Equal("some-container-handle"))
+		})
+
+		Context("when the lock cannot be acquired", func() {
+			It("should return the error without tearing down", func() {
+				locker.LockReturns(errors.New("timed out"))
+				Expect(manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")).To(MatchError("timed out"))
+				Expect(cniController.DownCallCount()).To(Equal(0))
+				Expect(mounter.RemoveMountCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when missing args", func() {
 			It("should return a friendly error", func() {
-				err := manager.Down("", "")
+				err := manager.Down(context.Background(), "", "", "")
 				Expect(err).To(MatchError("down missing container handle"))
+				Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindConfig))
 			})
 		})
 
@@ -114,7 +459,7 @@ var _ = Describe("Manager", func() {
 			Context("when the mounter fails", func() {
 				It("should return the error", func() {
 					mounter.RemoveMountReturns(errors.New("boom"))
-					err := manager.Down("some-container-handle", "some-network-spec")
+					err := manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")
 					Expect(err).To(MatchError("failed removing mount /some/fake/path/some-container-handle: boom"))
 				})
 			})
@@ -122,9 +467,163 @@ var _ = Describe("Manager", func() {
 			Context("when the cni Down fails", func() {
 				It("should return the error", func() {
 					cniController.DownReturns(errors.New("bang"))
-					err := manager.Down("some-container-handle", "some-network-spec")
+					err := manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")
 					Expect(err).To(MatchError("cni down failed: bang"))
 				})
+
+				It("should still remove the mount", func() {
+					cniController.DownReturns(errors.New("bang"))
+					manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(mounter.RemoveMountCallCount()).To(Equal(1))
+				})
+
+				It("should keep the state so that down can be retried", func() {
+					cniController.DownReturns(errors.New("bang"))
+					manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(stateStore.DeleteCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when both the cni Down and the mounter fail", func() {
+				It("should return every error", func() {
+					cniController.DownReturns(errors.New("bang"))
+					mounter.RemoveMountReturns(errors.New("boom"))
+					err := manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(err).To(MatchError("cni down failed: bang; failed removing mount /some/fake/path/some-container-handle: boom"))
+				})
+			})
+
+			Context("when loading the attachments fails", func() {
+				BeforeEach(func() {
+					stateStore.LoadReturns(nil, errors.New("pow"))
+				})
+
+				It("should move the state aside and tear down the networks the current configs select", func() {
+					Expect(manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")).To(Succeed())
+					Expect(stateStore.MoveAsideCallCount()).To(Equal(1))
+					Expect(stateStore.MoveAsideArgsForCall(0)).To(Equal("some-container-handle"))
+
+					_, _, _, gardenNetworkSpec, spec, downAttachments := cniController.DownArgsForCall(0)
+					Expect(downAttachments).To(BeNil())
+					Expect(gardenNetworkSpec).To(Equal("10.255.0.5/24"))
+					Expect(spec).To(Equal("some-network-spec"))
+					Expect(mounter.RemoveMountCallCount()).To(Equal(1))
+					Expect(stateStore.DeleteCallCount()).To(Equal(1))
+				})
+
+				Context("when the state cannot be moved aside", func() {
+					It("should still tear down, but keep the state and return the error", func() {
+						stateStore.MoveAsideReturns(errors.New("bang"))
+						err := manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")
+						Expect(err).To(MatchError("failed moving state aside: bang"))
+						Expect(cniController.DownCallCount()).To(Equal(1))
+						Expect(mounter.RemoveMountCallCount()).To(Equal(1))
+						Expect(stateStore.DeleteCallCount()).To(Equal(0))
+					})
+				})
+			})
+
+			Context("when deleting the state fails", func() {
+				It("should return the error", func() {
+					stateStore.DeleteReturns(errors.New("pow"))
+					err := manager.Down(context.Background(), "some-container-handle", "10.255.0.5/24", "some-network-spec")
+					Expect(err).To(MatchError("failed deleting state: pow"))
+				})
+			})
+		})
+	})
+
+	Describe("Check", func() {
+		var attachments []controller.NetworkAttachment
+
+		BeforeEach(func() {
+			attachments = []controller.NetworkAttachment{
+				{Network: "some-net", Interface: "eth0"},
+			}
+			stateStore.LoadReturns(&controller.ContainerState{Handle: "some-container-handle", Attachments: attachments}, nil)
+			cniController.CheckReturns(map[string]controller.CheckResult{
+				"some-net": {Interface: "eth0", Status: controller.CheckStatusOK},
+			}, nil)
+		})
+
+		It("should call CNI Check with the recorded attachments", func() {
+			report, err := manager.Check(context.Background(), "some-container-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report).To(HaveKeyWithValue("some-net", controller.CheckResult{Interface: "eth0", Status: controller.CheckStatusOK}))
+
+			Expect(stateStore.LoadCallCount()).To(Equal(1))
+			Expect(stateStore.LoadArgsForCall(0)).To(Equal("some-container-handle"))
+
+			Expect(cniController.CheckCallCount()).To(Equal(1))
+			_, namespacePath, handle, checkAttachments := cniController.CheckArgsForCall(0)
+			Expect(namespacePath).To(Equal("/some/fake/path/some-container-handle"))
+			Expect(handle).To(Equal("some-container-handle"))
+			Expect(checkAttachments).To(Equal(attachments))
+		})
+
+		It("should hold the container's lock while checking", func() {
+			_, err := manager.Check(context.Background(), "some-container-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locker.LockArgsForCall(0)).To(Equal("some-container-handle"))
+			Expect(locker.UnlockArgsForCall(0)).To(Equal("some-container-handle"))
+		})
+
+		Context("when the lock cannot be acquired", func() {
+			It("should return the error without checking", func() {
+				locker.LockReturns(errors.New("timed out"))
+				_, err := manager.Check(context.Background(), "some-container-handle")
+				Expect(err).To(MatchError("timed out"))
+				Expect(cniController.CheckCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when missing args", func() {
+			It("should return a friendly error", func() {
+				_, err := manager.Check(context.Background(), "")
+				Expect(err).To(MatchError("check missing container handle"))
+				Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindConfig))
+			})
+		})
+
+		Context("when a network fails its check", func() {
+			BeforeEach(func() {
+				cniController.CheckReturns(map[string]controller.CheckResult{
+					"some-net":       {Interface: "eth0", Status: controller.CheckStatusOK},
+					"some-other-net": {Interface: "eth1", Status: controller.CheckStatusFailed, Error: "boom"},
+					"some-old-net":   {Interface: "eth2", Status: controller.CheckStatusUnsupported},
+				}, nil)
+			})
+
+			It("should return the report along with an error naming the failed networks", func() {
+				report, err := manager.Check(context.Background(), "some-container-handle")
+				Expect(err).To(MatchError("networks failed check: some-other-net"))
+				Expect(report).To(HaveLen(3))
+			})
+		})
+
+		Context("when things fail", func() {
+			Context("when no state was recorded", func() {
+				It("should return an error", func() {
+					stateStore.LoadReturns(nil, nil)
+					_, err := manager.Check(context.Background(), "some-container-handle")
+					Expect(err).To(MatchError("no state recorded for some-container-handle"))
+				})
+			})
+
+			Context("when loading the attachments fails", func() {
+				It("should return the error", func() {
+					stateStore.LoadReturns(nil, errors.New("pow"))
+					_, err := manager.Check(context.Background(), "some-container-handle")
+					Expect(err).To(MatchError("failed loading state: pow"))
+				})
+			})
+
+			Context("when the cni Check fails", func() {
+				It("should return the error", func() {
+					cniController.CheckReturns(nil, errors.New("bang"))
+					_, err := manager.Check(context.Background(), "some-container-handle")
+					Expect(err).To(MatchError("cni check failed: bang"))
+				})
 			})
 		})
 	})
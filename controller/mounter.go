@@ -30,9 +30,11 @@ func (m *Mounter) IdempotentlyMount(source, target string) error {
 	return nil
 }
 
+// RemoveMount unmounts and removes the target.  A target that is no longer
+// mounted, as after a reboot, is just removed.
 func (m *Mounter) RemoveMount(target string) error {
 	err := unix.Unmount(target, unix.MNT_DETACH)
-	if err != nil {
+	if err != nil && err != unix.EINVAL {
 		return fmt.Errorf("unmount failed: %s", err)
 	}
 
@@ -43,3 +45,18 @@ func (m *Mounter) RemoveMount(target string) error {
 
 	return nil
 }
+
+// IsMounted reports whether the target is still a bind mount of a network
+// namespace.  Older kernels expose namespaces through procfs rather than nsfs.
+func (m *Mounter) IsMounted(target string) (bool, error) {
+	var stat unix.Statfs_t
+	err := unix.Statfs(target, &stat)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("statfs failed: %s", err) // not tested
+	}
+
+	return stat.Type == unix.NSFS_MAGIC || stat.Type == unix.PROC_SUPER_MAGIC, nil
+}
@@ -120,6 +120,30 @@ var _ = Describe("Mounter", func() {
 		})
 	})
 
+	Describe("IsMounted", func() {
+		It("should report a bind mount of a network namespace", func() {
+			Expect(mounter.IdempotentlyMount("/proc/self/ns/net", targetFile)).To(Succeed())
+			defer mounter.RemoveMount(targetFile)
+
+			Expect(mounter.IsMounted(targetFile)).To(BeTrue())
+		})
+
+		Context("when the target is a plain file", func() {
+			It("should report it as not mounted", func() {
+				Expect(os.MkdirAll(filepath.Dir(targetFile), 0700)).To(Succeed())
+				Expect(ioutil.WriteFile(targetFile, nil, 0600)).To(Succeed())
+
+				Expect(mounter.IsMounted(targetFile)).To(BeFalse())
+			})
+		})
+
+		Context("when the target does not exist", func() {
+			It("should report it as not mounted", func() {
+				Expect(mounter.IsMounted(targetFile)).To(BeFalse())
+			})
+		})
+	})
+
 	Describe("RemoveMount", func() {
 		It("should unmount the thing", func() {
 			Expect(mounter.IdempotentlyMount(sourceFile, targetFile)).To(Succeed())
@@ -132,6 +156,16 @@ var _ = Describe("Mounter", func() {
 			Expect(targetDir).To(BeADirectory())
 		})
 
+		Context("when the target is no longer mounted", func() {
+			It("should remove it", func() {
+				Expect(os.MkdirAll(filepath.Dir(targetFile), 0700)).To(Succeed())
+				Expect(ioutil.WriteFile(targetFile, nil, 0600)).To(Succeed())
+
+				Expect(mounter.RemoveMount(targetFile)).To(Succeed())
+				Expect(targetFile).NotTo(BeAnExistingFile())
+			})
+		})
+
 		Context("when things don't work the way you expect", func() {
 			Context("when unix.Unmount fails", func() {
 				It("should return the error", func() {
@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/types"
+	"golang.org/x/sys/unix"
+)
+
+// TimeoutError is returned when a plugin outlives its timeout and its
+// process group is killed.
+type TimeoutError struct {
+	Command string
+	Network string
+	Plugin  string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s for name=%s, type=%s", e.Command, e.Timeout, e.Network, e.Plugin)
+}
+
+// PluginTimeout resolves how long a network's plugins may each run, from the
+// network's "timeout" field, such as "10s", or else the given default.  A
+// zero timeout never expires.
+func PluginTimeout(networkConfigList *NetworkConfigList, defaultTimeout time.Duration) (time.Duration, error) {
+	var declared struct {
+		Timeout string `json:"timeout"`
+	}
+	err := json.Unmarshal(networkConfigList.Bytes, &declared)
+	if err != nil {
+		return 0, fmt.Errorf("parsing timeout for %s: %s", networkConfigList.Name, err)
+	}
+
+	if declared.Timeout == "" {
+		return defaultTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(declared.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout for %s: must be a positive duration such as \"10s\"", networkConfigList.Name)
+	}
+
+	return timeout, nil
+}
+
+// timeoutFor falls back to the global timeout for attachments recorded
+// before timeouts were.
+func (c *CNIController) timeoutFor(attachment NetworkAttachment) time.Duration {
+	if attachment.Timeout == 0 {
+		return c.PluginTimeout
+	}
+	return attachment.Timeout
+}
+
+// pluginWaitDelay bounds how long a plugin's stdout is read after the plugin
+// exits or is killed, in case a process that left its group still holds it.
+const pluginWaitDelay = time.Second
+
+// execPlugin runs a network's plugin as libcni would, but in its own process
+// group, so that the plugin and anything it started, such as an IPAM plugin,
+// can be killed together once the timeout expires or the context is done.
+func (c *CNIController) execPlugin(ctx context.Context, command string, networkConfig *libcni.NetworkConfig, runtimeConfig *libcni.RuntimeConf, timeout time.Duration) ([]byte, error) {
+	pluginPath, err := c.verifyPlugins(networkConfig.Network.Type, networkConfig.Network.IPAM.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.Err() != nil {
+		return nil, cancelledError(command, networkConfig, ctx.Err())
+	}
+
+	args := &invoke.Args{
+		Command:     command,
+		ContainerID: runtimeConfig.ContainerID,
+		NetNS:       runtimeConfig.NetNS,
+		PluginArgs:  runtimeConfig.Args,
+		IfName:      runtimeConfig.IfName,
+		Path:        strings.Join(c.PluginDirs, ":"),
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd := &exec.Cmd{
+		Env:         args.AsEnv(),
+		Path:        pluginPath,
+		Args:        []string{pluginPath},
+		Stdin:       bytes.NewReader(networkConfig.Bytes),
+		Stdout:      stdout,
+		Stderr:      os.Stderr,
+		SysProcAttr: &syscall.SysProcAttr{Setpgid: true},
+		WaitDelay:   pluginWaitDelay,
+	}
+
+	fields := pluginFields(networkConfig, runtimeConfig)
+	fields["command"] = command
+
+	started := time.Now()
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	// the group is only killed until the plugin is known to have exited,
+	// while its unreaped pid still reserves the group's id
+	var (
+		mu       sync.Mutex
+		exited   bool
+		killedBy error
+	)
+	kill := func(reason error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !exited && killedBy == nil {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			killedBy = reason
+		}
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-expired:
+			kill(&TimeoutError{
+				Command: command,
+				Network: networkConfig.Network.Name,
+				Plugin:  networkConfig.Network.Type,
+				Timeout: timeout,
+			})
+		case <-ctx.Done():
+			kill(cancelledError(command, networkConfig, ctx.Err()))
+		case <-done:
+		}
+	}()
+
+	waitForExit(cmd.Process.Pid)
+	mu.Lock()
+	exited = true
+	mu.Unlock()
+
+	err = cmd.Wait()
+	fields["duration"] = time.Since(started)
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// the plugin succeeded, but left a process behind holding its stdout
+		logging.FromContext(ctx).Warn("plugin left its stdout open", fields)
+		err = nil
+	}
+	if err != nil && killedBy != nil {
+		fields["error"] = killedBy
+		var timeoutErr *TimeoutError
+		if errors.As(killedBy, &timeoutErr) {
+			logging.FromContext(ctx).Error("plugin timed out", fields)
+		} else {
+			logging.FromContext(ctx).Warn("plugin cancelled", fields)
+		}
+		return nil, killedBy
+	}
+
+	if err != nil {
+		err = pluginError(err, stdout.Bytes(), networkConfig)
+		fields["error"] = err
+		logging.FromContext(ctx).Warn("plugin failed", fields)
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("plugin succeeded", fields)
+	return stdout.Bytes(), nil
+}
+
+// waitForExit returns once the process has exited, without reaping it.
+func waitForExit(pid int) {
+	for {
+		err := unix.Waitid(unix.P_PID, pid, &unix.Siginfo{}, unix.WEXITED|unix.WNOWAIT, nil)
+		if err != unix.EINTR {
+			return
+		}
+	}
+}
+
+func cancelledError(command string, networkConfig *libcni.NetworkConfig, err error) error {
+	return fmt.Errorf("%s cancelled for name=%s, type=%s: %w", command, networkConfig.Network.Name, networkConfig.Network.Type, err)
+}
+
+// pluginFields correlate the logs of a plugin invocation, whose container ID
+// is the container's handle.
+func pluginFields(networkConfig *libcni.NetworkConfig, runtimeConfig *libcni.RuntimeConf) logging.Fields {
+	return logging.Fields{
+		"handle":  runtimeConfig.ContainerID,
+		"network": networkConfig.Network.Name,
+		"plugin":  networkConfig.Network.Type,
+	}
+}
+
+// PluginError is the error a failed plugin printed to stdout, as described
+// by the CNI spec, whose code tells transient failures from others.
+type PluginError struct {
+	Code    uint
+	Msg     string
+	Details string
+	Network string
+	Plugin  string
+}
+
+func (e *PluginError) Error() string {
+	if e.Details != "" {
+		return fmt.Sprintf("%s; %s", e.Msg, e.Details)
+	}
+	return e.Msg
+}
+
+func pluginError(err error, output []byte, networkConfig *libcni.NetworkConfig) error {
+	if _, ok := err.(*exec.ExitError); !ok {
+		return err // not tested
+	}
+
+	pluginErr := types.Error{}
+	if parseErr := json.Unmarshal(output, &pluginErr); parseErr != nil {
+		return fmt.Errorf("netplugin failed but error parsing its diagnostic message %q: %s", string(output), parseErr)
+	}
+
+	return &PluginError{
+		Code:    pluginErr.Code,
+		Msg:     pluginErr.Msg,
+		Details: pluginErr.Details,
+		Network: networkConfig.Network.Name,
+		Plugin:  networkConfig.Network.Type,
+	}
+}
+
+// addNetwork returns the plugin's result as printed, to be parsed according
+// to the network's spec version.
+func (c *CNIController) addNetwork(ctx context.Context, networkConfig *libcni.NetworkConfig, runtimeConfig *libcni.RuntimeConf, timeout time.Duration) ([]byte, error) {
+	_, span := tracing.Start(ctx, "AddNetwork", pluginAttributes(networkConfig, runtimeConfig))
+	output, err := c.execPlugin(ctx, "ADD", networkConfig, runtimeConfig, timeout)
+	span.Finish(err)
+	return output, err
+}
+
+func (c *CNIController) delNetwork(ctx context.Context, networkConfig *libcni.NetworkConfig, runtimeConfig *libcni.RuntimeConf, timeout time.Duration) error {
+	_, span := tracing.Start(ctx, "DelNetwork", pluginAttributes(networkConfig, runtimeConfig))
+	_, err := c.execPlugin(ctx, "DEL", networkConfig, runtimeConfig, timeout)
+	span.Finish(err)
+	return err
+}
+
+func (c *CNIController) checkNetwork(ctx context.Context, networkConfig *libcni.NetworkConfig, runtimeConfig *libcni.RuntimeConf, timeout time.Duration) error {
+	_, span := tracing.Start(ctx, "CheckNetwork", pluginAttributes(networkConfig, runtimeConfig))
+	_, err := c.execPlugin(ctx, "CHECK", networkConfig, runtimeConfig, timeout)
+	span.Finish(err)
+	return err
+}
+
+func pluginAttributes(networkConfig *libcni.NetworkConfig, runtimeConfig *libcni.RuntimeConf) tracing.Attributes {
+	return tracing.Attributes{
+		"handle":    runtimeConfig.ContainerID,
+		"network":   networkConfig.Network.Name,
+		"plugin":    networkConfig.Network.Type,
+		"interface": runtimeConfig.IfName,
+	}
+}
@@ -0,0 +1,170 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plugin timeouts", func() {
+	Describe("PluginTimeout", func() {
+		It("uses the network's declared timeout", func() {
+			networkConfigList := &controller.NetworkConfigList{Name: "some-net", Bytes: []byte(`{"timeout": "10s"}`)}
+			Expect(controller.PluginTimeout(networkConfigList, time.Minute)).To(Equal(10 * time.Second))
+		})
+
+		Context("when the network declares no timeout", func() {
+			It("uses the default", func() {
+				networkConfigList := &controller.NetworkConfigList{Name: "some-net", Bytes: []byte(`{}`)}
+				Expect(controller.PluginTimeout(networkConfigList, time.Minute)).To(Equal(time.Minute))
+			})
+		})
+
+		Context("when the declared timeout is invalid", func() {
+			It("returns an error", func() {
+				for _, timeout := range []string{`"banana"`, `"-1s"`, `"0s"`, `10`} {
+					networkConfigList := &controller.NetworkConfigList{Name: "some-net", Bytes: []byte(`{"timeout": ` + timeout + `}`)}
+					_, err := controller.PluginTimeout(networkConfigList, time.Minute)
+					Expect(err).To(MatchError(ContainSubstring("timeout for some-net: ")), timeout)
+				}
+			})
+		})
+	})
+
+	Describe("running a plugin that hangs", func() {
+		var (
+			pluginDir     string
+			childPidFile  string
+			cniController *controller.CNIController
+			attachment    controller.NetworkAttachment
+		)
+
+		BeforeEach(func() {
+			var err error
+			pluginDir, err = ioutil.TempDir("", "cni-plugin-")
+			Expect(err).NotTo(HaveOccurred())
+
+			childPidFile = filepath.Join(pluginDir, "child.pid")
+			script := fmt.Sprintf("#!/bin/sh\nsleep 1000 &\necho $! > %s\nwait\n", childPidFile)
+			Expect(ioutil.WriteFile(filepath.Join(pluginDir, "hanging-plugin"), []byte(script), 0700)).To(Succeed())
+
+			cniController = &controller.CNIController{
+				PluginDirs:    []string{pluginDir},
+				ConfigDir:     pluginDir,
+				PluginTimeout: time.Hour,
+			}
+
+			attachment = controller.NetworkAttachment{
+				Network:   "some-net",
+				Interface: "eth0",
+				Timeout:   100 * time.Millisecond,
+				Configs:   []json.RawMessage{json.RawMessage(`{"name":"some-net","type":"hanging-plugin"}`)},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(pluginDir)).To(Succeed())
+		})
+
+		childIsRunning := func() bool {
+			pidBytes, err := ioutil.ReadFile(childPidFile)
+			Expect(err).NotTo(HaveOccurred())
+			pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+			Expect(err).NotTo(HaveOccurred())
+
+			statBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+			if err != nil {
+				return false
+			}
+			// a killed child that has not been reaped yet is a zombie
+			return !strings.Contains(string(statBytes), ") Z ")
+		}
+
+		It("kills the plugin's process group and identifies the network and plugin", func() {
+			_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+			Expect(err).To(MatchError("add network failed: ADD timed out after 100ms for name=some-net, type=hanging-plugin"))
+
+			Eventually(childIsRunning).Should(BeFalse())
+		})
+
+		It("times out DEL too", func() {
+			err := cniController.Down(context.Background(), "/some/netns", "some-handle", "", "", []controller.NetworkAttachment{attachment})
+			Expect(err).To(MatchError(ContainSubstring("DEL timed out after 100ms for name=some-net, type=hanging-plugin")))
+
+			Eventually(childIsRunning).Should(BeFalse())
+		})
+
+		Context("when the context is cancelled", func() {
+			BeforeEach(func() {
+				attachment.Timeout = time.Hour
+			})
+
+			It("kills the plugin's process group", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(200*time.Millisecond, cancel)
+
+				_, _, err := cniController.Add(ctx, "/some/netns", "some-handle", attachment)
+				Expect(err).To(MatchError("add network failed: ADD cancelled for name=some-net, type=hanging-plugin: context canceled"))
+				Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+
+				Eventually(childIsRunning).Should(BeFalse())
+			})
+
+			It("does not start the plugin if the context is already done", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				_, _, err := cniController.Add(ctx, "/some/netns", "some-handle", attachment)
+				Expect(err).To(MatchError(ContainSubstring("ADD cancelled")))
+				Expect(childPidFile).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when a process outside the plugin's group holds its stdout", func() {
+			var escapedPidFile string
+
+			BeforeEach(func() {
+				escapedPidFile = filepath.Join(pluginDir, "escaped.pid")
+				script := fmt.Sprintf("#!/bin/sh\nsetsid sleep 1000 &\necho $! > %s\nwait\n", escapedPidFile)
+				Expect(ioutil.WriteFile(filepath.Join(pluginDir, "hanging-plugin"), []byte(script), 0700)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				pidBytes, err := ioutil.ReadFile(escapedPidFile)
+				Expect(err).NotTo(HaveOccurred())
+				pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(syscall.Kill(pid, syscall.SIGKILL)).To(Succeed())
+			})
+
+			It("still returns shortly after the timeout", func() {
+				started := time.Now()
+				_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+				Expect(err).To(MatchError(ContainSubstring("ADD timed out after 100ms")))
+				Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+			})
+		})
+
+		Context("when the attachment was recorded without a timeout", func() {
+			It("uses the controller's timeout", func() {
+				attachment.Timeout = 0
+				cniController.PluginTimeout = 200 * time.Millisecond
+
+				_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+				Expect(err).To(MatchError(ContainSubstring("timed out after 200ms")))
+			})
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/invoke"
+)
+
+// findPlugin resolves a plugin to the first binary of that name in the
+// plugin directories.  If PluginChecksums is set, the plugin must be listed
+// in it, and the binary must have the listed SHA-256 checksum.
+//
+// The binary is hashed, then run again by its path, so a checksum catches a
+// wrong or corrupt binary, but not one swapped in between the two.  That is
+// left to the plugin directories being writable only by root.
+func (c *CNIController) findPlugin(pluginType string) (string, error) {
+	pluginPath, err := invoke.FindInPath(pluginType, c.PluginDirs)
+	if err != nil {
+		return "", fmt.Errorf("plugin %s not found in %s", pluginType, strings.Join(c.PluginDirs, ":"))
+	}
+
+	if c.PluginChecksums == nil {
+		return pluginPath, nil
+	}
+
+	expected, ok := c.PluginChecksums[pluginType]
+	if !ok {
+		return "", &KindError{Kind: ErrorKindConfig, Err: fmt.Errorf("plugin %s is not in the plugin allowlist", pluginType)}
+	}
+
+	actual, err := fileChecksum(pluginPath)
+	if err != nil {
+		return "", fmt.Errorf("verifying plugin %s: %s", pluginType, err) // not tested
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		return "", &KindError{Kind: ErrorKindConfig, Err: fmt.Errorf("plugin %s at %s has checksum %s, expected %s", pluginType, pluginPath, actual, expected)}
+	}
+
+	return pluginPath, nil
+}
+
+// verifyPlugins finds a network's plugin.  With an allowlist, the IPAM plugin
+// it delegates to is verified too, as the plugin will exec it.
+func (c *CNIController) verifyPlugins(pluginType, ipamType string) (string, error) {
+	if ipamType != "" && c.PluginChecksums != nil {
+		_, err := c.findPlugin(ipamType)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return c.findPlugin(pluginType)
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err // not tested
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err // not tested
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,122 @@
+package controller_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plugin verification", func() {
+	var (
+		configDir     string
+		pluginDirs    []string
+		ranFile       string
+		pluginScript  []byte
+		cniController *controller.CNIController
+	)
+
+	checksum := func(contents []byte) string {
+		sum := sha256.Sum256(contents)
+		return hex.EncodeToString(sum[:])
+	}
+
+	BeforeEach(func() {
+		var err error
+		configDir, err = ioutil.TempDir("", "cni-config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		pluginDirs = nil
+		for i := 0; i < 2; i++ {
+			pluginDir, err := ioutil.TempDir("", "cni-plugin-")
+			Expect(err).NotTo(HaveOccurred())
+			pluginDirs = append(pluginDirs, pluginDir)
+		}
+
+		ranFile = filepath.Join(configDir, "ran")
+		pluginScript = []byte("#!/bin/sh\ntouch " + ranFile + "\necho '{}'\n")
+		Expect(ioutil.WriteFile(filepath.Join(pluginDirs[1], "some-plugin"), pluginScript, 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(configDir, "10-a.conf"), []byte(`{"name": "net-a", "type": "some-plugin"}`), 0600)).To(Succeed())
+
+		cniController = &controller.CNIController{ConfigDir: configDir, PluginDirs: pluginDirs}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(configDir)).To(Succeed())
+		for _, pluginDir := range pluginDirs {
+			Expect(os.RemoveAll(pluginDir)).To(Succeed())
+		}
+	})
+
+	It("searches the plugin directories in order", func() {
+		report, err := cniController.Validate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Invalid()).To(BeEmpty())
+
+		otherScript := []byte("#!/bin/sh\nexit 1\n")
+		Expect(ioutil.WriteFile(filepath.Join(pluginDirs[0], "some-plugin"), otherScript, 0700)).To(Succeed())
+		cniController.PluginChecksums = map[string]string{"some-plugin": checksum(pluginScript)}
+
+		report, err = cniController.Validate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Invalid()).To(HaveLen(1))
+		Expect(report.Invalid()[0].Problems).To(ConsistOf(ContainSubstring("plugin some-plugin at " + filepath.Join(pluginDirs[0], "some-plugin") + " has checksum " + checksum(otherScript))))
+	})
+
+	Context("when plugins must be on the allowlist", func() {
+		var attachment controller.NetworkAttachment
+
+		BeforeEach(func() {
+			attachment = controller.NetworkAttachment{
+				Network:   "net-a",
+				Interface: "eth0",
+				Configs:   []json.RawMessage{json.RawMessage(`{"name":"net-a","type":"some-plugin"}`)},
+			}
+		})
+
+		It("runs a plugin with the listed checksum", func() {
+			cniController.PluginChecksums = map[string]string{"some-plugin": checksum(pluginScript)}
+
+			report, err := cniController.Validate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Invalid()).To(BeEmpty())
+
+			_, _, err = cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ranFile).To(BeAnExistingFile())
+		})
+
+		It("refuses to run a plugin whose binary has changed", func() {
+			cniController.PluginChecksums = map[string]string{"some-plugin": checksum([]byte("the original"))}
+
+			_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+			Expect(err).To(MatchError(ContainSubstring("plugin some-plugin at " + filepath.Join(pluginDirs[1], "some-plugin") + " has checksum")))
+			Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindConfig))
+			Expect(ranFile).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses to run a plugin that is not listed", func() {
+			cniController.PluginChecksums = map[string]string{}
+
+			_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+			Expect(err).To(MatchError(ContainSubstring("plugin some-plugin is not in the plugin allowlist")))
+			Expect(ranFile).NotTo(BeAnExistingFile())
+		})
+
+		It("verifies the IPAM plugin that the plugin delegates to", func() {
+			cniController.PluginChecksums = map[string]string{"some-plugin": checksum(pluginScript)}
+			attachment.Configs = []json.RawMessage{json.RawMessage(`{"name":"net-a","type":"some-plugin","ipam":{"type":"some-ipam"}}`)}
+
+			_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+			Expect(err).To(MatchError(ContainSubstring("plugin some-ipam not found in " + pluginDirs[0] + ":" + pluginDirs[1])))
+			Expect(ranFile).NotTo(BeAnExistingFile())
+		})
+	})
+})
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+type IPResult struct {
+	Version string `json:"version"`
+	Address string `json:"address"`
+	Gateway string `json:"gateway,omitempty"`
+}
+
+type RouteResult struct {
+	Dst string `json:"dst"`
+	GW  string `json:"gw,omitempty"`
+}
+
+type NetworkResult struct {
+	Interface string        `json:"interface"`
+	MAC       string        `json:"mac,omitempty"`
+	IPs       []IPResult    `json:"ips,omitempty"`
+	Routes    []RouteResult `json:"routes,omitempty"`
+	DNS       types.DNS     `json:"dns"`
+}
+
+type CheckResult struct {
+	Interface string `json:"interface"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+const (
+	CheckStatusOK          = "ok"
+	CheckStatusFailed      = "failed"
+	CheckStatusUnsupported = "unsupported"
+)
+
+type UpResult struct {
+	Handle   string                   `json:"handle"`
+	Networks map[string]NetworkResult `json:"networks"`
+}
+
+func NewNetworkResult(ifName string, result *types.Result) NetworkResult {
+	networkResult := NetworkResult{
+		Interface: ifName,
+	}
+	if result == nil {
+		return networkResult
+	}
+
+	networkResult.DNS = result.DNS
+	networkResult.appendIPConfig("4", result.IP4)
+	networkResult.appendIPConfig("6", result.IP6)
+
+	return networkResult
+}
+
+func (r *NetworkResult) appendIPConfig(version string, ipConfig *types.IPConfig) {
+	if ipConfig == nil {
+		return
+	}
+
+	ipResult := IPResult{
+		Version: version,
+		Address: ipConfig.IP.String(),
+	}
+	if ipConfig.Gateway != nil {
+		ipResult.Gateway = ipConfig.Gateway.String()
+	}
+	r.IPs = append(r.IPs, ipResult)
+
+	for _, route := range ipConfig.Routes {
+		routeResult := RouteResult{
+			Dst: route.Dst.String(),
+		}
+		if route.GW != nil {
+			routeResult.GW = route.GW.String()
+		}
+		r.Routes = append(r.Routes, routeResult)
+	}
+}
+
+func (r NetworkResult) CNIResult() (*types.Result, error) {
+	result := &types.Result{
+		DNS: r.DNS,
+	}
+
+	for _, ipResult := range r.IPs {
+		ip, ipNet, err := net.ParseCIDR(ipResult.Address)
+		if err != nil {
+			return nil, fmt.Errorf("parsing address %q: %s", ipResult.Address, err)
+		}
+		ipNet.IP = ip
+
+		ipConfig := &types.IPConfig{
+			IP:      *ipNet,
+			Gateway: net.ParseIP(ipResult.Gateway),
+		}
+
+		switch ipResult.Version {
+		case "4":
+			result.IP4 = ipConfig
+		case "6":
+			result.IP6 = ipConfig
+		default:
+			return nil, fmt.Errorf("unknown ip version %q", ipResult.Version)
+		}
+	}
+
+	for _, routeResult := range r.Routes {
+		_, dst, err := net.ParseCIDR(routeResult.Dst)
+		if err != nil {
+			return nil, fmt.Errorf("parsing route destination %q: %s", routeResult.Dst, err)
+		}
+
+		route := types.Route{
+			Dst: *dst,
+			GW:  net.ParseIP(routeResult.GW),
+		}
+
+		ipConfig := result.IP4
+		if dst.IP.To4() == nil {
+			ipConfig = result.IP6
+		}
+		if ipConfig == nil {
+			return nil, fmt.Errorf("route %s has no matching address", routeResult.Dst)
+		}
+		ipConfig.Routes = append(ipConfig.Routes, route)
+	}
+
+	return result, nil
+}
+
+// currentResult is a plugin's result as of spec 0.3.0, which lists the
+// interfaces the plugin created and any number of addresses on them.  As of
+// 1.0.0, addresses no longer carry their version.
+type currentResult struct {
+	CNIVersion string             `json:"cniVersion,omitempty"`
+	Interfaces []currentInterface `json:"interfaces,omitempty"`
+	IPs        []currentIPConfig  `json:"ips,omitempty"`
+	Routes     []RouteResult      `json:"routes,omitempty"`
+	DNS        types.DNS          `json:"dns"`
+}
+
+type currentInterface struct {
+	Name    string `json:"name"`
+	MAC     string `json:"mac,omitempty"`
+	Sandbox string `json:"sandbox,omitempty"`
+}
+
+type currentIPConfig struct {
+	Version   string `json:"version,omitempty"`
+	Interface *int   `json:"interface,omitempty"`
+	Address   string `json:"address"`
+	Gateway   string `json:"gateway,omitempty"`
+}
+
+// usesCurrentResult reports whether plugins of the given spec version print
+// results with interfaces and a list of addresses, rather than ip4 and ip6.
+func usesCurrentResult(cniVersion string) bool {
+	return versionAtLeast(cniVersion, 0, 3)
+}
+
+// ParseResult converts a plugin's result, in the spec version of its
+// network, into the adapter's network result for the given interface.
+func ParseResult(cniVersion, ifName string, output []byte) (NetworkResult, error) {
+	if !usesCurrentResult(cniVersion) {
+		result := &types.Result{}
+		err := json.Unmarshal(output, result)
+		if err != nil {
+			return NetworkResult{}, fmt.Errorf("parsing result: %s", err)
+		}
+		return NewNetworkResult(ifName, result), nil
+	}
+
+	var result currentResult
+	err := json.Unmarshal(output, &result)
+	if err != nil {
+		return NetworkResult{}, fmt.Errorf("parsing result: %s", err)
+	}
+
+	networkResult := NetworkResult{
+		Interface: ifName,
+		Routes:    result.Routes,
+		DNS:       result.DNS,
+	}
+
+	// the container's side of the interface, as opposed to any host veth
+	for _, iface := range result.Interfaces {
+		if iface.Sandbox != "" && (iface.Name == ifName || networkResult.MAC == "") {
+			networkResult.MAC = iface.MAC
+		}
+	}
+
+	for _, ipConfig := range result.IPs {
+		ip, _, err := net.ParseCIDR(ipConfig.Address)
+		if err != nil {
+			return NetworkResult{}, fmt.Errorf("parsing result: address %q: %s", ipConfig.Address, err)
+		}
+
+		version := "6"
+		if ip.To4() != nil {
+			version = "4"
+		}
+		networkResult.IPs = append(networkResult.IPs, IPResult{
+			Version: version,
+			Address: ipConfig.Address,
+			Gateway: ipConfig.Gateway,
+		})
+	}
+
+	return networkResult, nil
+}
+
+// PrevResult converts the network result back into a plugin result in the
+// given spec version, to be passed to plugins as their prevResult.  The
+// sandbox is the container's network namespace.
+func (r NetworkResult) PrevResult(cniVersion, sandbox string) (json.RawMessage, error) {
+	if !usesCurrentResult(cniVersion) {
+		result, err := r.CNIResult()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(result)
+	}
+
+	result := currentResult{
+		CNIVersion: cniVersion,
+		Interfaces: []currentInterface{{Name: r.Interface, MAC: r.MAC, Sandbox: sandbox}},
+		Routes:     r.Routes,
+		DNS:        r.DNS,
+	}
+
+	for _, ipResult := range r.IPs {
+		if _, _, err := net.ParseCIDR(ipResult.Address); err != nil {
+			return nil, fmt.Errorf("parsing address %q: %s", ipResult.Address, err)
+		}
+
+		interfaceIndex := 0
+		ipConfig := currentIPConfig{
+			Interface: &interfaceIndex,
+			Address:   ipResult.Address,
+			Gateway:   ipResult.Gateway,
+		}
+		if !versionAtLeast(cniVersion, 1, 0) {
+			ipConfig.Version = ipResult.Version
+		}
+		result.IPs = append(result.IPs, ipConfig)
+	}
+
+	return json.Marshal(result)
+}
@@ -0,0 +1,217 @@
+package controller_test
+
+import (
+	"net"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewNetworkResult", func() {
+	It("converts a CNI result into the adapter's network result", func() {
+		result := &types.Result{
+			IP4: &types.IPConfig{
+				IP: net.IPNet{
+					IP:   net.ParseIP("10.255.0.5"),
+					Mask: net.IPv4Mask(255, 255, 255, 0),
+				},
+				Gateway: net.ParseIP("10.255.0.1"),
+				Routes: []types.Route{
+					{
+						Dst: net.IPNet{
+							IP:   net.ParseIP("0.0.0.0"),
+							Mask: net.IPv4Mask(0, 0, 0, 0),
+						},
+						GW: net.ParseIP("10.255.0.1"),
+					},
+				},
+			},
+			IP6: &types.IPConfig{
+				IP: net.IPNet{
+					IP:   net.ParseIP("fd00::5"),
+					Mask: net.CIDRMask(64, 128),
+				},
+			},
+			DNS: types.DNS{
+				Nameservers: []string{"8.8.8.8"},
+			},
+		}
+
+		Expect(controller.NewNetworkResult("eth0", result)).To(Equal(controller.NetworkResult{
+			Interface: "eth0",
+			IPs: []controller.IPResult{
+				{Version: "4", Address: "10.255.0.5/24", Gateway: "10.255.0.1"},
+				{Version: "6", Address: "fd00::5/64"},
+			},
+			Routes: []controller.RouteResult{
+				{Dst: "0.0.0.0/0", GW: "10.255.0.1"},
+			},
+			DNS: types.DNS{
+				Nameservers: []string{"8.8.8.8"},
+			},
+		}))
+	})
+
+	Context("when the result is nil", func() {
+		It("returns only the interface", func() {
+			Expect(controller.NewNetworkResult("eth1", nil)).To(Equal(controller.NetworkResult{
+				Interface: "eth1",
+			}))
+		})
+	})
+})
+
+var _ = Describe("NetworkResult", func() {
+	Describe("CNIResult", func() {
+		It("converts back into a CNI result", func() {
+			networkResult := controller.NetworkResult{
+				Interface: "eth0",
+				IPs: []controller.IPResult{
+					{Version: "4", Address: "10.255.0.5/24", Gateway: "10.255.0.1"},
+				},
+				Routes: []controller.RouteResult{
+					{Dst: "0.0.0.0/0", GW: "10.255.0.1"},
+				},
+			}
+
+			result, err := networkResult.CNIResult()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.IP6).To(BeNil())
+			Expect(result.IP4.IP.String()).To(Equal("10.255.0.5/24"))
+			Expect(result.IP4.Gateway.String()).To(Equal("10.255.0.1"))
+			Expect(result.IP4.Routes).To(HaveLen(1))
+			Expect(result.IP4.Routes[0].Dst.String()).To(Equal("0.0.0.0/0"))
+
+			Expect(controller.NewNetworkResult("eth0", result)).To(Equal(networkResult))
+		})
+
+		Context("when an address is malformed", func() {
+			It("returns an error", func() {
+				networkResult := controller.NetworkResult{
+					IPs: []controller.IPResult{{Version: "4", Address: "banana"}},
+				}
+				_, err := networkResult.CNIResult()
+				Expect(err).To(MatchError(HavePrefix(`parsing address "banana"`)))
+			})
+		})
+
+		Context("when a route has no address of the same family", func() {
+			It("returns an error", func() {
+				networkResult := controller.NetworkResult{
+					IPs:    []controller.IPResult{{Version: "4", Address: "10.255.0.5/24"}},
+					Routes: []controller.RouteResult{{Dst: "::/0"}},
+				}
+				_, err := networkResult.CNIResult()
+				Expect(err).To(MatchError("route ::/0 has no matching address"))
+			})
+		})
+	})
+})
+
+var _ = Describe("ParseResult", func() {
+	It("parses a result with ip4 and ip6 for versions before 0.3.0", func() {
+		result, err := controller.ParseResult("0.2.0", "eth0", []byte(`{"ip4": {"ip": "10.255.0.5/24", "gateway": "10.255.0.1"}, "dns": {}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(controller.NetworkResult{
+			Interface: "eth0",
+			IPs:       []controller.IPResult{{Version: "4", Address: "10.255.0.5/24", Gateway: "10.255.0.1"}},
+		}))
+	})
+
+	It("parses a result with interfaces and any number of addresses from 0.3.0", func() {
+		result, err := controller.ParseResult("0.4.0", "eth1", []byte(`{
+			"cniVersion": "0.4.0",
+			"interfaces": [
+				{"name": "veth1234", "mac": "ee:ee:00:00:00:01"},
+				{"name": "eth1", "mac": "ee:ee:00:00:00:02", "sandbox": "/some/netns"}
+			],
+			"ips": [
+				{"version": "4", "interface": 1, "address": "10.255.0.5/24", "gateway": "10.255.0.1"},
+				{"version": "4", "interface": 1, "address": "10.255.1.5/24"},
+				{"version": "6", "interface": 1, "address": "fd00::5/64"}
+			],
+			"routes": [{"dst": "0.0.0.0/0", "gw": "10.255.0.1"}],
+			"dns": {"nameservers": ["8.8.8.8"]}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(controller.NetworkResult{
+			Interface: "eth1",
+			MAC:       "ee:ee:00:00:00:02",
+			IPs: []controller.IPResult{
+				{Version: "4", Address: "10.255.0.5/24", Gateway: "10.255.0.1"},
+				{Version: "4", Address: "10.255.1.5/24"},
+				{Version: "6", Address: "fd00::5/64"},
+			},
+			Routes: []controller.RouteResult{{Dst: "0.0.0.0/0", GW: "10.255.0.1"}},
+			DNS:    types.DNS{Nameservers: []string{"8.8.8.8"}},
+		}))
+	})
+
+	It("derives the address versions that 1.0.0 results leave out", func() {
+		result, err := controller.ParseResult("1.0.0", "eth0", []byte(`{
+			"cniVersion": "1.0.0",
+			"ips": [{"address": "10.255.0.5/24"}, {"address": "fd00::5/64"}]
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.IPs).To(Equal([]controller.IPResult{
+			{Version: "4", Address: "10.255.0.5/24"},
+			{Version: "6", Address: "fd00::5/64"},
+		}))
+	})
+
+	Context("when the result is malformed", func() {
+		It("returns an error", func() {
+			_, err := controller.ParseResult("0.1.0", "eth0", []byte(`banana`))
+			Expect(err).To(MatchError(HavePrefix("parsing result")))
+
+			_, err = controller.ParseResult("1.0.0", "eth0", []byte(`{"ips": [{"address": "banana"}]}`))
+			Expect(err).To(MatchError(HavePrefix(`parsing result: address "banana"`)))
+		})
+	})
+})
+
+var _ = Describe("PrevResult", func() {
+	var networkResult controller.NetworkResult
+
+	BeforeEach(func() {
+		networkResult = controller.NetworkResult{
+			Interface: "eth0",
+			MAC:       "ee:ee:00:00:00:02",
+			IPs:       []controller.IPResult{{Version: "4", Address: "10.255.0.5/24", Gateway: "10.255.0.1"}},
+		}
+	})
+
+	It("converts into a result with ip4 and ip6 for versions before 0.3.0", func() {
+		prevResult, err := networkResult.PrevResult("0.1.0", "/some/netns")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prevResult).To(MatchJSON(`{"ip4": {"ip": "10.255.0.5/24", "gateway": "10.255.0.1"}, "dns": {}}`))
+	})
+
+	It("converts into a result in the given version from 0.3.0", func() {
+		prevResult, err := networkResult.PrevResult("0.3.1", "/some/netns")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prevResult).To(MatchJSON(`{
+			"cniVersion": "0.3.1",
+			"interfaces": [{"name": "eth0", "mac": "ee:ee:00:00:00:02", "sandbox": "/some/netns"}],
+			"ips": [{"version": "4", "interface": 0, "address": "10.255.0.5/24", "gateway": "10.255.0.1"}],
+			"dns": {}
+		}`))
+
+		parsed, err := controller.ParseResult("0.3.1", "eth0", prevResult)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(networkResult))
+	})
+
+	It("leaves out the address versions from 1.0.0", func() {
+		prevResult, err := networkResult.PrevResult("1.0.0", "/some/netns")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prevResult).To(MatchJSON(`{
+			"cniVersion": "1.0.0",
+			"interfaces": [{"name": "eth0", "mac": "ee:ee:00:00:00:02", "sandbox": "/some/netns"}],
+			"ips": [{"interface": 0, "address": "10.255.0.5/24", "gateway": "10.255.0.1"}],
+			"dns": {}
+		}`))
+	})
+})
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+)
+
+const (
+	// CodeTryAgainLater is the error code the CNI spec reserves for transient
+	// failures, and the only one retried unless a network lists its own.
+	CodeTryAgainLater = 11
+
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultRetryMaxBackoff = 5 * time.Second
+)
+
+// RetryPolicy is how often, and after how long, ADD and DEL are retried when
+// a network's plugins fail with one of the retryable codes.
+type RetryPolicy struct {
+	MaxAttempts    int           `json:"max_attempts"`
+	Backoff        time.Duration `json:"backoff"`
+	MaxBackoff     time.Duration `json:"max_backoff"`
+	Jitter         float64       `json:"jitter"`
+	RetryableCodes []uint        `json:"retryable_codes"`
+}
+
+// ParseRetryPolicy reads a network's "retry" field, such as
+//
+//	"retry": {"max_attempts": 3, "backoff": "200ms", "max_backoff": "2s", "jitter": 0.5, "retryable_codes": [11]}
+//
+// where the backoff doubles after each attempt up to max_backoff, and jitter
+// is the fraction of each backoff that is randomized.  Networks without the
+// field are not retried.
+func ParseRetryPolicy(networkConfigList *NetworkConfigList) (*RetryPolicy, error) {
+	var declared struct {
+		Retry *struct {
+			MaxAttempts    int     `json:"max_attempts"`
+			Backoff        string  `json:"backoff"`
+			MaxBackoff     string  `json:"max_backoff"`
+			Jitter         float64 `json:"jitter"`
+			RetryableCodes []uint  `json:"retryable_codes"`
+		} `json:"retry"`
+	}
+	err := json.Unmarshal(networkConfigList.Bytes, &declared)
+	if err != nil {
+		return nil, fmt.Errorf("parsing retry policy for %s: %s", networkConfigList.Name, err)
+	}
+
+	if declared.Retry == nil {
+		return nil, nil
+	}
+
+	invalid := func(reason string) error {
+		return fmt.Errorf("invalid retry policy for %s: %s", networkConfigList.Name, reason)
+	}
+
+	policy := &RetryPolicy{
+		MaxAttempts:    declared.Retry.MaxAttempts,
+		Backoff:        DefaultRetryBackoff,
+		MaxBackoff:     DefaultRetryMaxBackoff,
+		Jitter:         declared.Retry.Jitter,
+		RetryableCodes: declared.Retry.RetryableCodes,
+	}
+
+	if policy.MaxAttempts < 1 {
+		return nil, invalid("max_attempts must be at least 1")
+	}
+
+	if declared.Retry.Backoff != "" {
+		policy.Backoff, err = time.ParseDuration(declared.Retry.Backoff)
+		if err != nil || policy.Backoff < 0 {
+			return nil, invalid("backoff must be a duration such as \"200ms\"")
+		}
+	}
+
+	if declared.Retry.MaxBackoff != "" {
+		policy.MaxBackoff, err = time.ParseDuration(declared.Retry.MaxBackoff)
+		if err != nil || policy.MaxBackoff < 0 {
+			return nil, invalid("max_backoff must be a duration such as \"2s\"")
+		}
+	}
+
+	if policy.MaxBackoff < policy.Backoff {
+		return nil, invalid("max_backoff must not be less than backoff")
+	}
+
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return nil, invalid("jitter must be between 0 and 1")
+	}
+
+	if len(policy.RetryableCodes) == 0 {
+		policy.RetryableCodes = []uint{CodeTryAgainLater}
+	}
+
+	return policy, nil
+}
+
+// Retries reports whether a failed attempt should be followed by another.
+// Only plugin errors are retried; a plugin that timed out has already held
+// up the container for its whole timeout.
+func (p *RetryPolicy) Retries(err error, attempt int) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	pluginErr, ok := err.(*PluginError)
+	if !ok {
+		return false
+	}
+
+	for _, code := range p.RetryableCodes {
+		if pluginErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// BackoffAfter returns how long to wait after the given failed attempt.
+func (p *RetryPolicy) BackoffAfter(attempt int) time.Duration {
+	backoff := p.Backoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	return backoff - time.Duration(p.Jitter*rand.Float64()*float64(backoff))
+}
+
+// retry runs attempt until it succeeds or fails in a way the policy does not
+// retry, logging each failure and calling cleanup before backing off.  It
+// gives up with the last failure once the context is done.
+func (p *RetryPolicy) retry(ctx context.Context, description string, fields logging.Fields, attempt func() error, cleanup func()) error {
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		if !p.Retries(err, n) {
+			return err
+		}
+
+		backoff := p.BackoffAfter(n)
+		logging.FromContext(ctx).With(fields).Warn(fmt.Sprintf("%s failed on attempt %d of %d, retrying in %s", description, n, p.MaxAttempts, backoff), logging.Fields{
+			"attempt": n,
+			"backoff": backoff,
+			"error":   err,
+		})
+
+		if cleanup != nil {
+			cleanup()
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
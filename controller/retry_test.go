@@ -0,0 +1,246 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retries", func() {
+	Describe("ParseRetryPolicy", func() {
+		parse := func(config string) (*controller.RetryPolicy, error) {
+			return controller.ParseRetryPolicy(&controller.NetworkConfigList{Name: "some-net", Bytes: []byte(config)})
+		}
+
+		It("reads the network's retry policy", func() {
+			policy, err := parse(`{"retry": {"max_attempts": 3, "backoff": "200ms", "max_backoff": "1s", "jitter": 0.5, "retryable_codes": [11, 100]}}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(&controller.RetryPolicy{
+				MaxAttempts:    3,
+				Backoff:        200 * time.Millisecond,
+				MaxBackoff:     time.Second,
+				Jitter:         0.5,
+				RetryableCodes: []uint{11, 100},
+			}))
+		})
+
+		It("defaults the backoff and retries only the code for transient failures", func() {
+			policy, err := parse(`{"retry": {"max_attempts": 2}}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(&controller.RetryPolicy{
+				MaxAttempts:    2,
+				Backoff:        controller.DefaultRetryBackoff,
+				MaxBackoff:     controller.DefaultRetryMaxBackoff,
+				RetryableCodes: []uint{controller.CodeTryAgainLater},
+			}))
+		})
+
+		Context("when the network has no retry policy", func() {
+			It("returns nil", func() {
+				Expect(parse(`{}`)).To(BeNil())
+			})
+		})
+
+		Context("when the retry policy is invalid", func() {
+			It("returns an error", func() {
+				for config, reason := range map[string]string{
+					`{"retry": {}}`: "max_attempts must be at least 1",
+					`{"retry": {"max_attempts": 2, "backoff": "banana"}}`:                  `backoff must be a duration such as "200ms"`,
+					`{"retry": {"max_attempts": 2, "max_backoff": "-1s"}}`:                 `max_backoff must be a duration such as "2s"`,
+					`{"retry": {"max_attempts": 2, "backoff": "2s", "max_backoff": "1s"}}`: "max_backoff must not be less than backoff",
+					`{"retry": {"max_attempts": 2, "jitter": 1.5}}`:                        "jitter must be between 0 and 1",
+				} {
+					_, err := parse(config)
+					Expect(err).To(MatchError("invalid retry policy for some-net: "+reason), config)
+				}
+			})
+		})
+	})
+
+	Describe("RetryPolicy", func() {
+		var policy *controller.RetryPolicy
+
+		BeforeEach(func() {
+			policy = &controller.RetryPolicy{
+				MaxAttempts:    3,
+				Backoff:        100 * time.Millisecond,
+				MaxBackoff:     300 * time.Millisecond,
+				RetryableCodes: []uint{11},
+			}
+		})
+
+		It("retries plugin errors with a retryable code until the attempts run out", func() {
+			busy := &controller.PluginError{Code: 11, Msg: "busy"}
+			Expect(policy.Retries(busy, 1)).To(BeTrue())
+			Expect(policy.Retries(busy, 2)).To(BeTrue())
+			Expect(policy.Retries(busy, 3)).To(BeFalse())
+
+			Expect(policy.Retries(&controller.PluginError{Code: 100, Msg: "broken"}, 1)).To(BeFalse())
+			Expect(policy.Retries(&controller.TimeoutError{}, 1)).To(BeFalse())
+			Expect(policy.Retries(fmt.Errorf("some error"), 1)).To(BeFalse())
+		})
+
+		It("never retries without a policy", func() {
+			policy = nil
+			Expect(policy.Retries(&controller.PluginError{Code: 11}, 1)).To(BeFalse())
+		})
+
+		It("doubles the backoff after each attempt up to the maximum", func() {
+			Expect(policy.BackoffAfter(1)).To(Equal(100 * time.Millisecond))
+			Expect(policy.BackoffAfter(2)).To(Equal(200 * time.Millisecond))
+			Expect(policy.BackoffAfter(3)).To(Equal(300 * time.Millisecond))
+			Expect(policy.BackoffAfter(10)).To(Equal(300 * time.Millisecond))
+		})
+
+		It("randomizes the given fraction of the backoff", func() {
+			policy.Jitter = 0.5
+			for i := 0; i < 20; i++ {
+				backoff := policy.BackoffAfter(1)
+				Expect(backoff).To(BeNumerically(">=", 50*time.Millisecond))
+				Expect(backoff).To(BeNumerically("<=", 100*time.Millisecond))
+			}
+		})
+	})
+
+	Describe("running a plugin that fails transiently", func() {
+		var (
+			pluginDir     string
+			cniController *controller.CNIController
+			attachment    controller.NetworkAttachment
+		)
+
+		writePlugin := func(failures int) {
+			script := fmt.Sprintf(`#!/bin/sh
+cd %s
+echo "$CNI_COMMAND" >> calls
+if [ "$CNI_COMMAND" = ADD ] && [ $(grep -c ADD calls) -le %d ]; then
+  echo '{"code": 11, "msg": "ipam backend busy"}'
+  exit 1
+fi
+echo '{}'
+`, pluginDir, failures)
+			Expect(ioutil.WriteFile(filepath.Join(pluginDir, "flaky-plugin"), []byte(script), 0700)).To(Succeed())
+		}
+
+		calls := func() []string {
+			callBytes, err := ioutil.ReadFile(filepath.Join(pluginDir, "calls"))
+			Expect(err).NotTo(HaveOccurred())
+			return strings.Fields(string(callBytes))
+		}
+
+		BeforeEach(func() {
+			var err error
+			pluginDir, err = ioutil.TempDir("", "cni-plugin-")
+			Expect(err).NotTo(HaveOccurred())
+
+			cniController = &controller.CNIController{
+				PluginDirs: []string{pluginDir},
+				ConfigDir:  pluginDir,
+			}
+
+			attachment = controller.NetworkAttachment{
+				Network:   "some-net",
+				Interface: "eth0",
+				Configs:   []json.RawMessage{json.RawMessage(`{"name":"some-net","type":"flaky-plugin"}`)},
+				Retry: &controller.RetryPolicy{
+					MaxAttempts:    3,
+					Backoff:        time.Millisecond,
+					MaxBackoff:     time.Millisecond,
+					RetryableCodes: []uint{11},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(pluginDir)).To(Succeed())
+		})
+
+		It("deletes what the failed attempt added before trying again", func() {
+			writePlugin(2)
+
+			_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(calls()).To(Equal([]string{"ADD", "DEL", "ADD", "DEL", "ADD"}))
+		})
+
+		Context("when the network is a chain of spec 0.4.0 or later", func() {
+			BeforeEach(func() {
+				script := fmt.Sprintf(`#!/bin/sh
+cd %s
+cat > "first-$CNI_COMMAND.stdin"
+echo '{"cniVersion": "0.4.0", "interfaces": [{"name": "veth-host"}], "dns": {}}'
+`, pluginDir)
+				Expect(ioutil.WriteFile(filepath.Join(pluginDir, "first-plugin"), []byte(script), 0700)).To(Succeed())
+
+				attachment.CNIVersion = "0.4.0"
+				attachment.Configs = []json.RawMessage{
+					json.RawMessage(`{"cniVersion":"0.4.0","name":"some-net","type":"first-plugin"}`),
+					json.RawMessage(`{"cniVersion":"0.4.0","name":"some-net","type":"flaky-plugin"}`),
+				}
+			})
+
+			It("passes the failed attempt's last result to the cleanup DEL", func() {
+				writePlugin(1)
+
+				_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+				Expect(err).NotTo(HaveOccurred())
+
+				stdin, err := ioutil.ReadFile(filepath.Join(pluginDir, "first-DEL.stdin"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(stdin).To(MatchJSON(`{
+					"cniVersion": "0.4.0",
+					"name": "some-net",
+					"type": "first-plugin",
+					"prevResult": {"cniVersion": "0.4.0", "interfaces": [{"name": "veth-host"}], "dns": {}}
+				}`))
+			})
+		})
+
+		Context("when the context is done while backing off", func() {
+			It("gives up with the last failure", func() {
+				writePlugin(3)
+				attachment.Retry.Backoff = time.Hour
+				attachment.Retry.MaxBackoff = time.Hour
+
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(100*time.Millisecond, cancel)
+
+				started := time.Now()
+				_, _, err := cniController.Add(ctx, "/some/netns", "some-handle", attachment)
+				Expect(err).To(MatchError("add network failed: ipam backend busy"))
+				Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+				Expect(calls()).To(Equal([]string{"ADD", "DEL"}))
+			})
+		})
+
+		Context("when every attempt fails", func() {
+			It("returns the last failure", func() {
+				writePlugin(3)
+
+				_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+				Expect(err).To(MatchError("add network failed: ipam backend busy"))
+				Expect(calls()).To(Equal([]string{"ADD", "DEL", "ADD", "DEL", "ADD"}))
+			})
+		})
+
+		Context("when the network has no retry policy", func() {
+			It("fails on the first attempt", func() {
+				writePlugin(1)
+				attachment.Retry = nil
+
+				_, _, err := cniController.Add(context.Background(), "/some/netns", "some-handle", attachment)
+				Expect(err).To(MatchError("add network failed: ipam backend busy"))
+				Expect(calls()).To(Equal([]string{"ADD"}))
+			})
+		})
+	})
+})
@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+// Well-known Garden properties that are passed to plugins through CNI_ARGS
+// and the runtimeConfig capability mechanism.  Since Garden properties are
+// strings, each value may also be given JSON encoded within a string.
+const (
+	PortMappingsProperty = "port_mappings"
+	BandwidthProperty    = "bandwidth"
+	IPsProperty          = "ips"
+	MACProperty          = "mac"
+)
+
+type PortMapping struct {
+	HostPort      int    `json:"hostPort"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+type Bandwidth struct {
+	IngressRate  int `json:"ingressRate,omitempty"`
+	IngressBurst int `json:"ingressBurst,omitempty"`
+	EgressRate   int `json:"egressRate,omitempty"`
+	EgressBurst  int `json:"egressBurst,omitempty"`
+}
+
+// RuntimeArgs are the per-container arguments handed to plugins: CNIArgs as
+// CNI_ARGS, and Capabilities as runtimeConfig to the plugins that declare
+// them.
+type RuntimeArgs struct {
+	CNIArgs      [][2]string
+	Capabilities map[string]interface{}
+}
+
+// addressArgs are the capabilities, and the CNI_ARGS they are also passed
+// as, that are specific to one interface.
+var addressArgs = map[string]string{"ips": "IP", "mac": "MAC"}
+
+// WithoutAddresses returns the arguments that apply to any of the
+// container's networks, leaving out the IPs and the MAC, which would clash
+// if given to more than one interface.
+func (r *RuntimeArgs) WithoutAddresses() *RuntimeArgs {
+	withoutAddresses := &RuntimeArgs{
+		Capabilities: make(map[string]interface{}),
+	}
+
+	for capability, arg := range r.Capabilities {
+		if _, ok := addressArgs[capability]; !ok {
+			withoutAddresses.Capabilities[capability] = arg
+		}
+	}
+
+	addressCNIArgs := make(map[string]bool)
+	for _, cniArg := range addressArgs {
+		addressCNIArgs[cniArg] = true
+	}
+	for _, cniArg := range r.CNIArgs {
+		if !addressCNIArgs[cniArg[0]] && cniArg[0] != "IgnoreUnknown" {
+			withoutAddresses.CNIArgs = append(withoutAddresses.CNIArgs, cniArg)
+		}
+	}
+	if len(withoutAddresses.CNIArgs) > 0 {
+		withoutAddresses.CNIArgs = append([][2]string{{"IgnoreUnknown", "1"}}, withoutAddresses.CNIArgs...)
+	}
+
+	return withoutAddresses
+}
+
+func ParseRuntimeArgs(spec string, gardenNetwork *GardenNetwork) (*RuntimeArgs, error) {
+	properties := make(map[string]interface{})
+	if strings.TrimSpace(spec) != "" {
+		err := json.Unmarshal([]byte(spec), &properties)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal garden network spec: %s", err)
+		}
+	}
+
+	runtimeArgs := &RuntimeArgs{
+		Capabilities: make(map[string]interface{}),
+	}
+
+	var portMappings []struct {
+		HostPort      int    `json:"host_port"`
+		ContainerPort int    `json:"container_port"`
+		Protocol      string `json:"protocol"`
+	}
+	found, err := decodeProperty(properties, PortMappingsProperty, &portMappings)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		mappings := []PortMapping{}
+		for _, portMapping := range portMappings {
+			mapping := PortMapping{
+				HostPort:      portMapping.HostPort,
+				ContainerPort: portMapping.ContainerPort,
+				Protocol:      strings.ToLower(portMapping.Protocol),
+			}
+			if mapping.Protocol == "" {
+				mapping.Protocol = "tcp"
+			}
+			if !validPort(mapping.HostPort) || !validPort(mapping.ContainerPort) {
+				return nil, fmt.Errorf("invalid %q property: ports must be between 1 and 65535", PortMappingsProperty)
+			}
+			if mapping.Protocol != "tcp" && mapping.Protocol != "udp" && mapping.Protocol != "sctp" {
+				return nil, fmt.Errorf("invalid %q property: unknown protocol %q", PortMappingsProperty, portMapping.Protocol)
+			}
+			mappings = append(mappings, mapping)
+		}
+		runtimeArgs.Capabilities["portMappings"] = mappings
+	}
+
+	var bandwidth struct {
+		IngressRate  int `json:"ingress_rate"`
+		IngressBurst int `json:"ingress_burst"`
+		EgressRate   int `json:"egress_rate"`
+		EgressBurst  int `json:"egress_burst"`
+	}
+	found, err = decodeProperty(properties, BandwidthProperty, &bandwidth)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if bandwidth.IngressRate < 0 || bandwidth.IngressBurst < 0 || bandwidth.EgressRate < 0 || bandwidth.EgressBurst < 0 {
+			return nil, fmt.Errorf("invalid %q property: rates and bursts must not be negative", BandwidthProperty)
+		}
+		runtimeArgs.Capabilities["bandwidth"] = Bandwidth(bandwidth)
+	}
+
+	ips := []string{}
+	if gardenNetwork != nil && gardenNetwork.IP != "" {
+		ips = append(ips, gardenNetwork.IP)
+	}
+	ipsProperty, err := decodeListProperty(properties, IPsProperty)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ipsProperty {
+		if !isIP(ip) && !isCIDR(ip) {
+			return nil, fmt.Errorf("invalid %q property: %q is not an IP or CIDR", IPsProperty, ip)
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) > 0 {
+		runtimeArgs.Capabilities["ips"] = ips
+		runtimeArgs.CNIArgs = append(runtimeArgs.CNIArgs, [2]string{"IP", strings.Join(ips, ",")})
+	}
+
+	var mac string
+	found, err = decodeProperty(properties, MACProperty, &mac)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		hardwareAddr, err := net.ParseMAC(mac)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q property: %s", MACProperty, err)
+		}
+		runtimeArgs.Capabilities["mac"] = hardwareAddr.String()
+		runtimeArgs.CNIArgs = append(runtimeArgs.CNIArgs, [2]string{"MAC", hardwareAddr.String()})
+	}
+
+	if len(runtimeArgs.CNIArgs) > 0 {
+		// plugins reject CNI_ARGS they do not know about unless told otherwise
+		runtimeArgs.CNIArgs = append([][2]string{{"IgnoreUnknown", "1"}}, runtimeArgs.CNIArgs...)
+	}
+
+	return runtimeArgs, nil
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// decodeProperty decodes the named property into value, accepting either
+// the JSON value itself or a string holding it.  It reports whether the
+// property was present.
+func decodeProperty(properties map[string]interface{}, name string, value interface{}) (bool, error) {
+	rawValue, ok := properties[name]
+	if !ok {
+		return false, nil
+	}
+
+	valueBytes, err := json.Marshal(rawValue)
+	if err != nil {
+		return false, fmt.Errorf("invalid %q property: %s", name, err) // not tested
+	}
+
+	err = json.Unmarshal(valueBytes, value)
+	if err == nil {
+		return true, nil
+	}
+
+	encoded, ok := rawValue.(string)
+	if !ok {
+		return false, fmt.Errorf("invalid %q property: %s", name, err)
+	}
+
+	err = json.Unmarshal([]byte(encoded), value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %q property: %s", name, err)
+	}
+
+	return true, nil
+}
+
+// decodeListProperty accepts a list property as a JSON list, a JSON list
+// encoded in a string, or a comma-separated string.
+func decodeListProperty(properties map[string]interface{}, name string) ([]string, error) {
+	encoded, ok := properties[name].(string)
+	if ok && !strings.HasPrefix(strings.TrimSpace(encoded), "[") {
+		list := []string{}
+		for _, item := range strings.Split(encoded, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	}
+
+	var list []string
+	_, err := decodeProperty(properties, name, &list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// InjectRuntimeConfig sets runtimeConfig on the plugin config for every
+// capability the plugin declares in its "capabilities" map and for which
+// there is an argument.
+func InjectRuntimeConfig(existingNetConfig *libcni.NetworkConfig, capabilityArgs map[string]interface{}) (*libcni.NetworkConfig, error) {
+	config := make(map[string]interface{})
+	err := json.Unmarshal(existingNetConfig.Bytes, &config)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal existing network bytes: %s", err)
+	}
+
+	capabilities, _ := config["capabilities"].(map[string]interface{})
+
+	runtimeConfig := make(map[string]interface{})
+	for capability, enabled := range capabilities {
+		if enabled, ok := enabled.(bool); !ok || !enabled {
+			continue
+		}
+		if arg, ok := capabilityArgs[capability]; ok {
+			runtimeConfig[capability] = arg
+		}
+	}
+
+	if len(runtimeConfig) == 0 {
+		return existingNetConfig, nil
+	}
+	config["runtimeConfig"] = runtimeConfig
+
+	newBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err // not tested
+	}
+
+	return &libcni.NetworkConfig{
+		Network: existingNetConfig.Network,
+		Bytes:   newBytes,
+	}, nil
+}
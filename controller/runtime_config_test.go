@@ -0,0 +1,130 @@
+package controller_test
+
+import (
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/containernetworking/cni/libcni"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseRuntimeArgs", func() {
+	It("returns no arguments when no well-known properties are set", func() {
+		runtimeArgs, err := controller.ParseRuntimeArgs(`{"some-key": "some-value"}`, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtimeArgs.CNIArgs).To(BeEmpty())
+		Expect(runtimeArgs.Capabilities).To(BeEmpty())
+	})
+
+	It("maps port mappings given as JSON or as a JSON string", func() {
+		expected := []controller.PortMapping{
+			{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+			{HostPort: 5353, ContainerPort: 53, Protocol: "udp"},
+		}
+
+		runtimeArgs, err := controller.ParseRuntimeArgs(`{"port_mappings": [
+			{"host_port": 8080, "container_port": 80},
+			{"host_port": 5353, "container_port": 53, "protocol": "UDP"}
+		]}`, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtimeArgs.Capabilities).To(HaveKeyWithValue("portMappings", expected))
+
+		runtimeArgs, err = controller.ParseRuntimeArgs(`{"port_mappings": "[{\"host_port\": 8080, \"container_port\": 80}, {\"host_port\": 5353, \"container_port\": 53, \"protocol\": \"udp\"}]"}`, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtimeArgs.Capabilities).To(HaveKeyWithValue("portMappings", expected))
+	})
+
+	It("maps bandwidth limits", func() {
+		runtimeArgs, err := controller.ParseRuntimeArgs(`{"bandwidth": {"egress_rate": 100, "egress_burst": 200}}`, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtimeArgs.Capabilities).To(HaveKeyWithValue("bandwidth", controller.Bandwidth{EgressRate: 100, EgressBurst: 200}))
+	})
+
+	It("maps the garden network address and requested IPs to the ips capability and CNI_ARGS", func() {
+		runtimeArgs, err := controller.ParseRuntimeArgs(`{"ips": "10.0.0.7, fd00::7/64"}`, &controller.GardenNetwork{IP: "10.0.0.5"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtimeArgs.Capabilities).To(HaveKeyWithValue("ips", []string{"10.0.0.5", "10.0.0.7", "fd00::7/64"}))
+		Expect(runtimeArgs.CNIArgs).To(Equal([][2]string{
+			{"IgnoreUnknown", "1"},
+			{"IP", "10.0.0.5,10.0.0.7,fd00::7/64"},
+		}))
+	})
+
+	It("maps the MAC to the mac capability and CNI_ARGS", func() {
+		runtimeArgs, err := controller.ParseRuntimeArgs(`{"mac": "0A:00:00:00:00:01"}`, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtimeArgs.Capabilities).To(HaveKeyWithValue("mac", "0a:00:00:00:00:01"))
+		Expect(runtimeArgs.CNIArgs).To(Equal([][2]string{
+			{"IgnoreUnknown", "1"},
+			{"MAC", "0a:00:00:00:00:01"},
+		}))
+	})
+
+	Describe("WithoutAddresses", func() {
+		It("leaves out the IPs and the MAC", func() {
+			runtimeArgs, err := controller.ParseRuntimeArgs(`{"ips": "10.0.0.7", "mac": "0a:00:00:00:00:01", "bandwidth": {"egress_rate": 100}}`, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			withoutAddresses := runtimeArgs.WithoutAddresses()
+			Expect(withoutAddresses.Capabilities).To(Equal(map[string]interface{}{
+				"bandwidth": controller.Bandwidth{EgressRate: 100},
+			}))
+			Expect(withoutAddresses.CNIArgs).To(BeEmpty())
+			Expect(runtimeArgs.Capabilities).To(HaveKey("mac"))
+		})
+	})
+
+	DescribeTable("invalid properties",
+		func(spec, expectedError string) {
+			_, err := controller.ParseRuntimeArgs(spec, nil)
+			Expect(err).To(MatchError(HavePrefix(expectedError)))
+		},
+		Entry("malformed spec", `{`, "unmarshal garden network spec"),
+		Entry("port mappings not a list", `{"port_mappings": 80}`, `invalid "port_mappings" property`),
+		Entry("port out of range", `{"port_mappings": [{"host_port": 70000, "container_port": 80}]}`, `invalid "port_mappings" property: ports must be between 1 and 65535`),
+		Entry("unknown protocol", `{"port_mappings": [{"host_port": 8080, "container_port": 80, "protocol": "icmp"}]}`, `invalid "port_mappings" property: unknown protocol "icmp"`),
+		Entry("negative bandwidth", `{"bandwidth": {"ingress_rate": -1}}`, `invalid "bandwidth" property: rates and bursts must not be negative`),
+		Entry("bad ip", `{"ips": ["banana"]}`, `invalid "ips" property: "banana" is not an IP or CIDR`),
+		Entry("bad mac", `{"mac": "banana"}`, `invalid "mac" property`),
+	)
+})
+
+var _ = Describe("InjectRuntimeConfig", func() {
+	var capabilityArgs map[string]interface{}
+
+	BeforeEach(func() {
+		capabilityArgs = map[string]interface{}{
+			"portMappings": []controller.PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}},
+			"mac":          "0a:00:00:00:00:01",
+		}
+	})
+
+	It("sets runtimeConfig for the capabilities the plugin declares", func() {
+		netConfig := &libcni.NetworkConfig{
+			Bytes: []byte(`{"type": "portmap", "capabilities": {"portMappings": true, "mac": false, "bandwidth": true}}`),
+		}
+
+		injected, err := controller.InjectRuntimeConfig(netConfig, capabilityArgs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(injected.Bytes).To(MatchJSON(`{
+			"type": "portmap",
+			"capabilities": {"portMappings": true, "mac": false, "bandwidth": true},
+			"runtimeConfig": {"portMappings": [{"hostPort": 8080, "containerPort": 80, "protocol": "tcp"}]}
+		}`))
+	})
+
+	It("leaves plugins without matching capabilities untouched", func() {
+		netConfig := &libcni.NetworkConfig{Bytes: []byte(`{"type": "bridge"}`)}
+
+		injected, err := controller.InjectRuntimeConfig(netConfig, capabilityArgs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(injected).To(BeIdenticalTo(netConfig))
+	})
+
+	Context("when the config is malformed", func() {
+		It("returns an error", func() {
+			_, err := controller.InjectRuntimeConfig(&libcni.NetworkConfig{Bytes: []byte(`banana`)}, capabilityArgs)
+			Expect(err).To(MatchError(HavePrefix("unmarshal existing network bytes")))
+		})
+	})
+})
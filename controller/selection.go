@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// NetworksProperty is the reserved Garden property listing, by name, the
+// networks a container joins.  It may be a comma-separated string or a JSON
+// list.  When it is absent, every network whose match_properties rules are
+// satisfied is joined.
+const NetworksProperty = "networks"
+
+type NetworkSelector struct {
+	properties map[string]interface{}
+	requested  map[string]bool
+}
+
+func NewNetworkSelector(spec string) (*NetworkSelector, error) {
+	selector := &NetworkSelector{
+		properties: make(map[string]interface{}),
+	}
+
+	if strings.TrimSpace(spec) != "" {
+		err := json.Unmarshal([]byte(spec), &selector.properties)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal garden network spec: %s", err)
+		}
+	}
+
+	rawNetworks, ok := selector.properties[NetworksProperty]
+	if !ok {
+		return selector, nil
+	}
+
+	selector.requested = make(map[string]bool)
+	switch networks := rawNetworks.(type) {
+	case string:
+		for _, name := range strings.Split(networks, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				selector.requested[name] = true
+			}
+		}
+	case []interface{}:
+		for _, rawName := range networks {
+			name, ok := rawName.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %q property: network names must be strings", NetworksProperty)
+			}
+			selector.requested[name] = true
+		}
+	default:
+		return nil, fmt.Errorf("invalid %q property: must be a string or a list", NetworksProperty)
+	}
+
+	return selector, nil
+}
+
+// Selects reports whether the container should join the given network.
+// Networks requested by name are always joined; otherwise every rule in the
+// network's match_properties must equal the corresponding property.
+func (s *NetworkSelector) Selects(networkConfigList *NetworkConfigList) (bool, error) {
+	if s.requested != nil {
+		return s.requested[networkConfigList.Name], nil
+	}
+
+	var rules struct {
+		MatchProperties map[string]interface{} `json:"match_properties"`
+	}
+	err := json.Unmarshal(networkConfigList.Bytes, &rules)
+	if err != nil {
+		return false, fmt.Errorf("parsing match_properties for %s: %s", networkConfigList.Name, err)
+	}
+
+	for key, value := range rules.MatchProperties {
+		if !reflect.DeepEqual(s.properties[key], value) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// CheckAllFound returns an error if any network requested by name is not
+// configured.
+func (s *NetworkSelector) CheckAllFound(networkConfigLists []*NetworkConfigList) error {
+	configured := make(map[string]bool)
+	for _, networkConfigList := range networkConfigLists {
+		configured[networkConfigList.Name] = true
+	}
+
+	missing := []string{}
+	for name := range s.requested {
+		if !configured[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("requested networks are not configured: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package controller_test
+
+import (
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NetworkSelector", func() {
+	var (
+		plainNetwork, matchingNetwork *controller.NetworkConfigList
+		networkConfigLists            []*controller.NetworkConfigList
+	)
+
+	BeforeEach(func() {
+		plainNetwork = &controller.NetworkConfigList{
+			Name:  "some-net",
+			Bytes: []byte(`{"name": "some-net", "type": "bridge"}`),
+		}
+		matchingNetwork = &controller.NetworkConfigList{
+			Name:  "some-overlay",
+			Bytes: []byte(`{"name": "some-overlay", "type": "vxlan", "match_properties": {"app": "web", "space": "dev"}}`),
+		}
+		networkConfigLists = []*controller.NetworkConfigList{plainNetwork, matchingNetwork}
+	})
+
+	Context("when the networks property lists networks by name", func() {
+		It("selects only the named networks", func() {
+			selector, err := controller.NewNetworkSelector(`{"networks": "some-overlay"}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(selector.Selects(plainNetwork)).To(BeFalse())
+			Expect(selector.Selects(matchingNetwork)).To(BeTrue())
+		})
+
+		It("accepts a comma-separated list", func() {
+			selector, err := controller.NewNetworkSelector(`{"networks": "some-net, some-overlay"}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(selector.Selects(plainNetwork)).To(BeTrue())
+			Expect(selector.Selects(matchingNetwork)).To(BeTrue())
+		})
+
+		It("accepts a JSON list", func() {
+			selector, err := controller.NewNetworkSelector(`{"networks": ["some-net"]}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(selector.Selects(plainNetwork)).To(BeTrue())
+			Expect(selector.Selects(matchingNetwork)).To(BeFalse())
+		})
+
+		Context("when a named network is not configured", func() {
+			It("returns an error", func() {
+				selector, err := controller.NewNetworkSelector(`{"networks": "some-net,some-typo,another-typo"}`)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = selector.CheckAllFound(networkConfigLists)
+				Expect(err).To(MatchError("requested networks are not configured: another-typo, some-typo"))
+			})
+		})
+
+		Context("when the property is neither a string nor a list", func() {
+			It("returns an error", func() {
+				_, err := controller.NewNetworkSelector(`{"networks": 42}`)
+				Expect(err).To(MatchError(`invalid "networks" property: must be a string or a list`))
+			})
+		})
+
+		Context("when the list contains something other than a name", func() {
+			It("returns an error", func() {
+				_, err := controller.NewNetworkSelector(`{"networks": ["some-net", 42]}`)
+				Expect(err).To(MatchError(`invalid "networks" property: network names must be strings`))
+			})
+		})
+	})
+
+	Context("when no networks are listed", func() {
+		It("selects networks without rules", func() {
+			selector, err := controller.NewNetworkSelector(`{"app": "api"}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(selector.Selects(plainNetwork)).To(BeTrue())
+			Expect(selector.CheckAllFound(networkConfigLists)).To(Succeed())
+		})
+
+		It("selects networks whose rules all match the properties", func() {
+			selector, err := controller.NewNetworkSelector(`{"app": "web", "space": "dev", "other": "thing"}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(selector.Selects(matchingNetwork)).To(BeTrue())
+		})
+
+		It("does not select networks with any unmatched rule", func() {
+			selector, err := controller.NewNetworkSelector(`{"app": "web", "space": "prod"}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(selector.Selects(matchingNetwork)).To(BeFalse())
+		})
+
+		Context("when the spec is empty", func() {
+			It("treats every property as missing", func() {
+				selector, err := controller.NewNetworkSelector("")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(selector.Selects(plainNetwork)).To(BeTrue())
+				Expect(selector.Selects(matchingNetwork)).To(BeFalse())
+			})
+		})
+	})
+
+	Context("when the spec is malformed JSON", func() {
+		It("returns an error", func() {
+			_, err := controller.NewNetworkSelector("%%%")
+			Expect(err).To(MatchError(ContainSubstring("unmarshal garden network spec")))
+		})
+	})
+})
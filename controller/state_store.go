@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// StatusAttaching is recorded from before the container's netns is
+	// mounted until its last network is added.  A container left in this
+	// status was interrupted during up; its last attachment may or may not
+	// have been added.
+	StatusAttaching = "attaching"
+	StatusAttached  = "attached"
+)
+
+// ContainerState records everything needed to tear a container's networking
+// down again, even after a crash.
+type ContainerState struct {
+	Handle      string              `json:"handle"`
+	Pid         int                 `json:"pid"`
+	NetNSPath   string              `json:"netns_path"`
+	Status      string              `json:"status"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Attachments []NetworkAttachment `json:"attachments"`
+}
+
+func (s *ContainerState) UpResult() *UpResult {
+	result := &UpResult{
+		Handle:   s.Handle,
+		Networks: make(map[string]NetworkResult),
+	}
+	for _, attachment := range s.Attachments {
+		result.Networks[attachment.Network] = attachment.Result
+	}
+	return result
+}
+
+type StateStore struct {
+	Dir string
+}
+
+const (
+	stateFileSuffix   = ".json"
+	corruptFileSuffix = ".corrupt"
+)
+
+func (s *StateStore) path(handle string) string {
+	return filepath.Join(s.Dir, handle+stateFileSuffix)
+}
+
+// Save atomically replaces the state recorded for the container.
+func (s *StateStore) Save(state *ContainerState) error {
+	if state.Attachments == nil {
+		state.Attachments = []NetworkAttachment{}
+	}
+	state.UpdatedAt = time.Now().UTC()
+
+	return s.writeAtomically(s.path(state.Handle), state)
+}
+
+// Load returns nil state, and no error, if none is recorded for the handle.
+func (s *StateStore) Load(handle string) (*ContainerState, error) {
+	stateBytes, err := ioutil.ReadFile(s.path(handle))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state file failed: %s", err)
+	}
+
+	state := &ContainerState{}
+	err = json.Unmarshal(stateBytes, state)
+	if err != nil {
+		return nil, fmt.Errorf("parsing state file failed: %s", err)
+	}
+
+	return state, nil
+}
+
+// List returns the handles of every container with recorded state, sorted.
+func (s *StateStore) List() ([]string, error) {
+	files, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state dir failed: %s", err)
+	}
+
+	handles := []string{}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, stateFileSuffix) {
+			continue
+		}
+		handles = append(handles, strings.TrimSuffix(name, stateFileSuffix))
+	}
+	sort.Strings(handles)
+
+	return handles, nil
+}
+
+// Delete removes the container's state, along with any state file moved
+// aside.
+func (s *StateStore) Delete(handle string) error {
+	err := os.Remove(s.path(handle))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing state file failed: %s", err)
+	}
+
+	err = os.Remove(filepath.Join(s.Dir, handle+corruptFileSuffix))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing corrupt state file failed: %s", err) // not tested
+	}
+
+	return nil
+}
+
+// MoveAside renames a state file that cannot be loaded to <handle>.corrupt,
+// so that it no longer stands in the way of up, but can still be looked at
+// until the container is torn down.
+func (s *StateStore) MoveAside(handle string) error {
+	err := os.Rename(s.path(handle), filepath.Join(s.Dir, handle+corruptFileSuffix))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("moving state file aside failed: %s", err)
+	}
+
+	return nil
+}
+
+func (s *StateStore) writeAtomically(path string, value interface{}) error {
+	err := os.MkdirAll(s.Dir, 0700)
+	if err != nil {
+		return fmt.Errorf("os.MkdirAll failed: %s", err)
+	}
+
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %s", err) // not tested
+	}
+
+	tempFile, err := ioutil.TempFile(s.Dir, filepath.Base(path)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("creating temp file failed: %s", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(valueBytes)
+	if err != nil {
+		tempFile.Close()
+		return fmt.Errorf("writing temp file failed: %s", err) // not tested
+	}
+
+	err = tempFile.Sync()
+	if err != nil {
+		tempFile.Close()
+		return fmt.Errorf("syncing temp file failed: %s", err) // not tested
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		return fmt.Errorf("closing temp file failed: %s", err) // not tested
+	}
+
+	err = os.Rename(tempFile.Name(), path)
+	if err != nil {
+		return fmt.Errorf("rename failed: %s", err) // not tested
+	}
+
+	return nil
+}
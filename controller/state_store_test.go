@@ -0,0 +1,168 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StateStore", func() {
+	var (
+		stateStore *controller.StateStore
+		storeDir   string
+		state      *controller.ContainerState
+	)
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = ioutil.TempDir("", "state-store-")
+		Expect(err).NotTo(HaveOccurred())
+
+		stateStore = &controller.StateStore{
+			Dir: filepath.Join(storeDir, "state"),
+		}
+
+		state = &controller.ContainerState{
+			Handle:    "some-handle",
+			Pid:       42,
+			NetNSPath: "/some/bind/mount/some-handle",
+			Status:    controller.StatusAttached,
+			Attachments: []controller.NetworkAttachment{
+				{
+					Network:   "some-net",
+					Interface: "eth0",
+					Configs:   []json.RawMessage{json.RawMessage(`{"name":"some-net","type":"some-plugin"}`)},
+					Result: controller.NetworkResult{
+						Interface: "eth0",
+						IPs:       []controller.IPResult{{Version: "4", Address: "169.254.1.2/24"}},
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("saves and loads state by handle", func() {
+		Expect(stateStore.Save(state)).To(Succeed())
+		Expect(filepath.Join(storeDir, "state", "some-handle.json")).To(BeAnExistingFile())
+
+		loaded, err := stateStore.Load("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.UpdatedAt).NotTo(BeZero())
+		Expect(loaded.UpdatedAt.Equal(state.UpdatedAt)).To(BeTrue())
+
+		loaded.UpdatedAt = state.UpdatedAt
+		Expect(loaded).To(Equal(state))
+	})
+
+	It("does not leave temporary files behind", func() {
+		Expect(stateStore.Save(state)).To(Succeed())
+		Expect(stateStore.Save(state)).To(Succeed())
+
+		files, err := ioutil.ReadDir(stateStore.Dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+	})
+
+	Context("when no state was saved for the handle", func() {
+		It("returns nil state", func() {
+			loaded, err := stateStore.Load("some-missing-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(BeNil())
+		})
+	})
+
+	Context("when the container has not joined any network yet", func() {
+		It("records empty, non-nil attachments", func() {
+			state.Attachments = nil
+			Expect(stateStore.Save(state)).To(Succeed())
+
+			loaded, err := stateStore.Load("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.Attachments).NotTo(BeNil())
+			Expect(loaded.Attachments).To(BeEmpty())
+		})
+	})
+
+	It("lists the handles with recorded state", func() {
+		Expect(stateStore.List()).To(BeEmpty())
+
+		Expect(stateStore.Save(state)).To(Succeed())
+		state.Handle = "another-handle"
+		Expect(stateStore.Save(state)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(stateStore.Dir, "some-handle.json.tmp-123"), nil, 0600)).To(Succeed())
+
+		Expect(stateStore.List()).To(Equal([]string{"another-handle", "some-handle"}))
+	})
+
+	It("deletes state by handle", func() {
+		Expect(stateStore.Save(state)).To(Succeed())
+		Expect(stateStore.Delete("some-handle")).To(Succeed())
+
+		loaded, err := stateStore.Load("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(BeNil())
+	})
+
+	Context("when deleting a handle that has no state", func() {
+		It("succeeds", func() {
+			Expect(stateStore.Delete("some-missing-handle")).To(Succeed())
+		})
+	})
+
+	Context("when the stored state is corrupt", func() {
+		It("returns an error", func() {
+			Expect(os.MkdirAll(stateStore.Dir, 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(stateStore.Dir, "some-handle.json"), []byte("%%%"), 0600)).To(Succeed())
+
+			_, err := stateStore.Load("some-handle")
+			Expect(err).To(MatchError(HavePrefix("parsing state file failed")))
+		})
+
+		It("moves it aside until the handle's state is deleted", func() {
+			Expect(os.MkdirAll(stateStore.Dir, 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(stateStore.Dir, "some-handle.json"), []byte("%%%"), 0600)).To(Succeed())
+
+			Expect(stateStore.MoveAside("some-handle")).To(Succeed())
+			Expect(stateStore.Load("some-handle")).To(BeNil())
+			Expect(stateStore.List()).To(BeEmpty())
+			Expect(ioutil.ReadFile(filepath.Join(stateStore.Dir, "some-handle.corrupt"))).To(Equal([]byte("%%%")))
+
+			Expect(stateStore.Delete("some-handle")).To(Succeed())
+			Expect(filepath.Join(stateStore.Dir, "some-handle.corrupt")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("when moving aside a handle that has no state", func() {
+		It("succeeds", func() {
+			Expect(stateStore.MoveAside("some-missing-handle")).To(Succeed())
+		})
+	})
+
+	Context("when the directory cannot be created", func() {
+		It("returns an error", func() {
+			stateStore.Dir = "/proc/0/state"
+			err := stateStore.Save(state)
+			Expect(err).To(MatchError(HavePrefix("os.MkdirAll failed")))
+		})
+	})
+
+	Describe("UpResult", func() {
+		It("returns the result of each network keyed by name", func() {
+			Expect(state.UpResult()).To(Equal(&controller.UpResult{
+				Handle: "some-handle",
+				Networks: map[string]controller.NetworkResult{
+					"some-net": state.Attachments[0].Result,
+				},
+			}))
+		})
+	})
+})
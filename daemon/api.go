@@ -0,0 +1,52 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+)
+
+// Requests carry the hook's trace context, if any, as a W3C traceparent, so
+// that the daemon's spans join the hook's trace.
+type UpRequest struct {
+	Pid         int    `json:"pid"`
+	Handle      string `json:"handle"`
+	Network     string `json:"network"`
+	Properties  string `json:"properties"`
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+type DownRequest struct {
+	Handle      string `json:"handle"`
+	Network     string `json:"network"`
+	Properties  string `json:"properties"`
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+type CheckRequest struct {
+	Handle      string `json:"handle"`
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+type CheckResponse struct {
+	Report      map[string]controller.CheckResult `json:"report,omitempty"`
+	Error       string                            `json:"error,omitempty"`
+	ErrorReport *controller.ErrorReport           `json:"error_report,omitempty"`
+}
+
+// ErrorResponse carries the error's report, when the manager returned it, so
+// that the client can classify it as the manager would.
+type ErrorResponse struct {
+	Error  string                  `json:"error"`
+	Report *controller.ErrorReport `json:"report,omitempty"`
+}
+
+// Status describes a running daemon: the networks it has loaded and how many
+// requests of each action it has handled and failed.
+type Status struct {
+	Pid       int            `json:"pid"`
+	StartedAt time.Time      `json:"started_at"`
+	Networks  []string       `json:"networks"`
+	Requests  map[string]int `json:"requests"`
+	Failures  map[string]int `json:"failures"`
+}
@@ -0,0 +1,173 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
+)
+
+// Client forwards hook invocations to a daemon listening on SocketPath.  It
+// has the same methods as controller.Manager, and passes on the span carried
+// by their context.  If no daemon is listening, they are handled by Fallback
+// instead, if set.
+type Client struct {
+	SocketPath string
+	Fallback   manager
+}
+
+// notRunningError is returned when nothing accepts connections on the
+// socket, so that the request was never sent.
+type notRunningError struct {
+	socketPath string
+	err        error
+}
+
+func (e *notRunningError) Error() string {
+	return fmt.Sprintf("daemon is not running on %s: %s", e.socketPath, e.err)
+}
+
+func (c *Client) Up(ctx context.Context, pid int, containerHandle, gardenNetworkSpec, networkSpec string) (*controller.UpResult, error) {
+	result := &controller.UpResult{}
+	err := c.post("/up", UpRequest{
+		Pid:         pid,
+		Handle:      containerHandle,
+		Network:     gardenNetworkSpec,
+		Properties:  networkSpec,
+		Traceparent: tracing.SpanFromContext(ctx).SpanContext().Traceparent(),
+	}, result)
+	if err != nil {
+		if c.fallBack(ctx, err) {
+			return c.Fallback.Up(ctx, pid, containerHandle, gardenNetworkSpec, networkSpec)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *Client) Down(ctx context.Context, containerHandle, gardenNetworkSpec, networkSpec string) error {
+	err := c.post("/down", DownRequest{
+		Handle:      containerHandle,
+		Network:     gardenNetworkSpec,
+		Properties:  networkSpec,
+		Traceparent: tracing.SpanFromContext(ctx).SpanContext().Traceparent(),
+	}, &struct{}{})
+	if err != nil && c.fallBack(ctx, err) {
+		return c.Fallback.Down(ctx, containerHandle, gardenNetworkSpec, networkSpec)
+	}
+	return err
+}
+
+// Check returns the daemon's report even when it also returns an error, as
+// controller.Manager does.
+func (c *Client) Check(ctx context.Context, containerHandle string) (map[string]controller.CheckResult, error) {
+	response := CheckResponse{}
+	err := c.post("/check", CheckRequest{
+		Handle:      containerHandle,
+		Traceparent: tracing.SpanFromContext(ctx).SpanContext().Traceparent(),
+	}, &response)
+	if err != nil {
+		if c.fallBack(ctx, err) {
+			return c.Fallback.Check(ctx, containerHandle)
+		}
+		return nil, err
+	}
+	if response.ErrorReport != nil {
+		return response.Report, response.ErrorReport
+	}
+	if response.Error != "" {
+		return response.Report, errors.New(response.Error)
+	}
+	return response.Report, nil
+}
+
+func (c *Client) Status() (*Status, error) {
+	response, err := c.httpClient().Get("http://daemon/status")
+	if err != nil {
+		return nil, c.connectionError(err)
+	}
+	defer response.Body.Close()
+
+	status := &Status{}
+	err = decodeResponse(response, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *Client) post(path string, request, response interface{}) error {
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encoding request: %s", err) // not tested
+	}
+
+	httpResponse, err := c.httpClient().Post("http://daemon"+path, "application/json", bytes.NewReader(requestBytes))
+	if err != nil {
+		return c.connectionError(err)
+	}
+	defer httpResponse.Body.Close()
+
+	return decodeResponse(httpResponse, response)
+}
+
+// connectionError tells a daemon that is not running from one that failed
+// to answer, which may have handled the request.
+func (c *Client) connectionError(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return &notRunningError{socketPath: c.SocketPath, err: opErr.Err}
+	}
+	return fmt.Errorf("connecting to daemon at %s: %s", c.SocketPath, err)
+}
+
+// fallBack reports whether a request that failed with the given error is to
+// be handled by the Fallback, which is only safe if it was never sent.
+func (c *Client) fallBack(ctx context.Context, err error) bool {
+	var notRunning *notRunningError
+	if c.Fallback == nil || !errors.As(err, &notRunning) {
+		return false
+	}
+
+	logging.FromContext(ctx).Warn("handling the request in the hook", logging.Fields{"error": err})
+	return true
+}
+
+// decodeResponse decodes a successful response into value.  Check responses
+// carry their error in the body, so they are decoded whatever the status.
+func decodeResponse(httpResponse *http.Response, value interface{}) error {
+	if _, isCheck := value.(*CheckResponse); httpResponse.StatusCode != http.StatusOK && !isCheck {
+		errorResponse := ErrorResponse{}
+		err := json.NewDecoder(httpResponse.Body).Decode(&errorResponse)
+		if err != nil || errorResponse.Error == "" {
+			return fmt.Errorf("daemon responded with status %d", httpResponse.StatusCode)
+		}
+		if errorResponse.Report != nil {
+			return errorResponse.Report
+		}
+		return errors.New(errorResponse.Error)
+	}
+
+	err := json.NewDecoder(httpResponse.Body).Decode(value)
+	if err != nil {
+		return fmt.Errorf("decoding daemon response: %s", err)
+	}
+	return nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return net.Dial("unix", c.SocketPath)
+			},
+		},
+	}
+}
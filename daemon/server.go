@@ -0,0 +1,284 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
+)
+
+//go:generate counterfeiter -o ../fakes/manager.go --fake-name Manager . manager
+type manager interface {
+	Up(ctx context.Context, pid int, containerHandle, gardenNetworkSpec, networkSpec string) (*controller.UpResult, error)
+	Down(ctx context.Context, containerHandle, gardenNetworkSpec, networkSpec string) error
+	Check(ctx context.Context, containerHandle string) (map[string]controller.CheckResult, error)
+}
+
+//go:generate counterfeiter -o ../fakes/networkConfigs.go --fake-name NetworkConfigs . networkConfigs
+type networkConfigs interface {
+	Networks() ([]string, error)
+	Reload() error
+}
+
+// Server exposes a resident Manager over HTTP.  Requests for different
+// containers are handled concurrently, as separate hooks would be, while those
+// for the same container wait for each other on the Manager's lock.
+type Server struct {
+	Manager        manager
+	NetworkConfigs networkConfigs
+
+	// HandleLog opens a container's log, to which the lines of each request
+	// for the container are written as well as to the daemon's.  If nil,
+	// they are only written to the daemon's.
+	HandleLog func(handle string) (io.WriteCloser, error)
+
+	// mu guards the request counts
+	mu        sync.Mutex
+	startedAt time.Time
+	requests  map[string]int
+	failures  map[string]int
+}
+
+// Listen listens on a Unix socket at the given path, replacing any socket
+// left behind by a previous daemon.  It refuses to take over the socket of a
+// daemon that is still running.
+func Listen(socketPath string) (net.Listener, error) {
+	info, err := os.Lstat(socketPath)
+	if err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, dialErr := net.Dial("unix", socketPath)
+		if dialErr == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", socketPath)
+		}
+
+		err = os.Remove(socketPath)
+		if err != nil {
+			return nil, fmt.Errorf("removing stale socket %s: %s", socketPath, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %s", socketPath, err)
+	}
+
+	err = os.Chmod(socketPath, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("restricting permissions on %s: %s", socketPath, err) // not tested
+	}
+
+	return listener, nil
+}
+
+func (s *Server) Handler() http.Handler {
+	s.mu.Lock()
+	if s.requests == nil {
+		s.startedAt = time.Now()
+		s.requests = make(map[string]int)
+		s.failures = make(map[string]int)
+	}
+	s.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/up", s.handleUp)
+	mux.HandleFunc("/down", s.handleDown)
+	mux.HandleFunc("/check", s.handleCheck)
+	mux.HandleFunc("/status", s.handleStatus)
+	return mux
+}
+
+// Reload reads the network configs again, for the requests that follow.  If
+// they are no longer valid, the previous ones stay in use.
+func (s *Server) Reload() {
+	err := s.NetworkConfigs.Reload()
+	if err != nil {
+		logging.Error("reloading network configs failed, keeping the previous ones", logging.Fields{"error": err})
+		return
+	}
+	logging.Info("reloaded network configs")
+}
+
+func (s *Server) handleUp(w http.ResponseWriter, r *http.Request) {
+	var request UpRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	ctx, finish := s.startRequest(r, "up", request.Handle, request.Traceparent)
+	result, err := s.Manager.Up(ctx, request.Pid, request.Handle, request.Network, request.Properties)
+	finish(err)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, newErrorResponse(err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleDown(w http.ResponseWriter, r *http.Request) {
+	var request DownRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	ctx, finish := s.startRequest(r, "down", request.Handle, request.Traceparent)
+	err := s.Manager.Down(ctx, request.Handle, request.Network, request.Properties)
+	finish(err)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, newErrorResponse(err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	var request CheckRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	ctx, finish := s.startRequest(r, "check", request.Handle, request.Traceparent)
+	report, err := s.Manager.Check(ctx, request.Handle)
+	finish(err)
+
+	response := CheckResponse{Report: report}
+	if err != nil {
+		response.Error = err.Error()
+		response.ErrorReport = controller.NewErrorReport(err)
+		writeJSON(w, http.StatusInternalServerError, response)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+
+	networks, err := s.NetworkConfigs.Networks()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	status := Status{
+		Pid:       os.Getpid(),
+		StartedAt: s.startedAt,
+		Networks:  networks,
+		Requests:  make(map[string]int),
+		Failures:  make(map[string]int),
+	}
+
+	s.mu.Lock()
+	for action, count := range s.requests {
+		status.Requests[action] = count
+	}
+	for action, count := range s.failures {
+		status.Failures[action] = count
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// startRequest logs a request to the daemon's log and the container's, and
+// continues the hook's trace, or starts a new one if the hook sent none.  It
+// returns the context carrying the request's logger and span, and the
+// function that records the request's outcome.
+func (s *Server) startRequest(r *http.Request, action, handle, traceparent string) (context.Context, func(error)) {
+	logger := logging.Default().With(logging.Fields{"action": action, "handle": handle})
+
+	var handleLog io.WriteCloser
+	if s.HandleLog != nil {
+		var err error
+		handleLog, err = s.HandleLog(handle)
+		if err != nil {
+			logger.Warn("unable to open the container's log", logging.Fields{"error": err})
+		} else {
+			logger = logger.Tee(handleLog)
+		}
+	}
+
+	started := time.Now()
+	logger.Info(fmt.Sprintf("handling %s for %s", action, handle))
+
+	parent, err := tracing.ParseTraceparent(traceparent)
+	if err != nil && traceparent != "" {
+		logger.Warn("ignoring invalid traceparent", logging.Fields{"error": err})
+	}
+
+	ctx := logging.ContextWithLogger(r.Context(), logger)
+	ctx, span := tracing.StartRemote(ctx, parent, "daemon "+action, tracing.Attributes{"handle": handle, "action": action})
+
+	return ctx, func(err error) {
+		span.Finish(err)
+		s.record(logger, action, started, err)
+		if handleLog != nil {
+			handleLog.Close()
+		}
+	}
+}
+
+func (s *Server) record(logger *logging.Logger, action string, started time.Time, err error) {
+	fields := logging.Fields{"duration": time.Since(started)}
+
+	s.mu.Lock()
+	s.requests[action]++
+	if err != nil {
+		s.failures[action]++
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		fields["error"] = err
+		logger.Error(fmt.Sprintf("%s failed", action), fields)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("%s complete", action), fields)
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+		return false
+	}
+
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("decoding request: %s", err)})
+		return false
+	}
+
+	return true
+}
+
+func newErrorResponse(err error) ErrorResponse {
+	return ErrorResponse{
+		Error:  err.Error(),
+		Report: controller.NewErrorReport(err),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		logging.Error("writing response", logging.Fields{"error": err}) // not tested
+	}
+}
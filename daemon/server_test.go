@@ -0,0 +1,419 @@
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/daemon"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/fakes"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
+	"github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type spanRecorder struct {
+	spans []*tracing.Span
+}
+
+func (r *spanRecorder) Export(spans []*tracing.Span) error {
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+var _ = Describe("Server and Client", func() {
+	var (
+		socketDir      string
+		socketPath     string
+		manager        *fakes.Manager
+		networkConfigs *fakes.NetworkConfigs
+		server         *daemon.Server
+		httpServer     *http.Server
+		client         *daemon.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		socketDir, err = ioutil.TempDir("", "daemon-")
+		Expect(err).NotTo(HaveOccurred())
+		socketPath = filepath.Join(socketDir, "adapter.sock")
+
+		manager = &fakes.Manager{}
+		networkConfigs = &fakes.NetworkConfigs{}
+		server = &daemon.Server{
+			Manager:        manager,
+			NetworkConfigs: networkConfigs,
+		}
+
+		listener, err := daemon.Listen(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		httpServer = &http.Server{Handler: server.Handler()}
+		go httpServer.Serve(listener)
+
+		client = &daemon.Client{SocketPath: socketPath}
+	})
+
+	AfterEach(func() {
+		Expect(httpServer.Close()).To(Succeed())
+		Expect(os.RemoveAll(socketDir)).To(Succeed())
+	})
+
+	It("restricts the socket to its owner", func() {
+		info, err := os.Stat(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	Describe("Up", func() {
+		It("forwards to the manager and returns its result", func() {
+			manager.UpReturns(&controller.UpResult{
+				Handle:   "some-handle",
+				Networks: map[string]controller.NetworkResult{"some-net": {Interface: "eth0"}},
+			}, nil)
+
+			result, err := client.Up(context.Background(), 42, "some-handle", "10.255.0.5/24", `{"some": "properties"}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Networks).To(HaveKeyWithValue("some-net", controller.NetworkResult{Interface: "eth0"}))
+
+			Expect(manager.UpCallCount()).To(Equal(1))
+			_, pid, handle, gardenNetworkSpec, networkSpec := manager.UpArgsForCall(0)
+			Expect(pid).To(Equal(42))
+			Expect(handle).To(Equal("some-handle"))
+			Expect(gardenNetworkSpec).To(Equal("10.255.0.5/24"))
+			Expect(networkSpec).To(Equal(`{"some": "properties"}`))
+		})
+
+		Context("when the hook is traced", func() {
+			var exporter *spanRecorder
+
+			BeforeEach(func() {
+				exporter = &spanRecorder{}
+				tracing.SetDefault(tracing.NewTracer(exporter))
+			})
+
+			AfterEach(func() {
+				tracing.SetDefault(nil)
+			})
+
+			It("joins the daemon's span to the hook's trace", func() {
+				manager.UpReturns(&controller.UpResult{}, nil)
+
+				ctx, hookSpan := tracing.Start(context.Background(), "up", nil)
+				_, err := client.Up(ctx, 42, "some-handle", "", "")
+				Expect(err).NotTo(HaveOccurred())
+				hookSpan.Finish(nil)
+
+				Expect(exporter.spans).To(HaveLen(2))
+				daemonSpan := exporter.spans[0]
+				Expect(daemonSpan.Name).To(Equal("daemon up"))
+				Expect(daemonSpan.Attributes).To(HaveKeyWithValue("handle", "some-handle"))
+				Expect(daemonSpan.Context.TraceID).To(Equal(hookSpan.Context.TraceID))
+				Expect(daemonSpan.Parent).To(Equal(hookSpan.Context.SpanID))
+
+				managerCtx, _, _, _, _ := manager.UpArgsForCall(0)
+				Expect(tracing.SpanFromContext(managerCtx)).To(BeIdenticalTo(daemonSpan))
+			})
+		})
+
+		Context("when the manager fails", func() {
+			It("returns the manager's error", func() {
+				manager.UpReturns(nil, errors.New("potato"))
+
+				_, err := client.Up(context.Background(), 42, "some-handle", "", "")
+				Expect(err).To(MatchError("potato"))
+			})
+
+			It("preserves the error's kind and CNI error", func() {
+				pluginErr := &controller.PluginError{Code: 11, Msg: "busy", Network: "some-net", Plugin: "some-plugin"}
+				manager.UpReturns(nil, fmt.Errorf("cni up failed: %w", pluginErr))
+
+				_, err := client.Up(context.Background(), 42, "some-handle", "", "")
+				Expect(err).To(MatchError("cni up failed: busy"))
+				Expect(controller.KindOf(err)).To(Equal(controller.ErrorKindPlugin))
+				Expect(controller.NewErrorReport(err)).To(Equal(&controller.ErrorReport{
+					Kind:     controller.ErrorKindPlugin,
+					Msg:      "cni up failed: busy",
+					Network:  "some-net",
+					Plugin:   "some-plugin",
+					CNIError: &types.Error{Code: 11, Msg: "busy"},
+				}))
+			})
+		})
+	})
+
+	Describe("Down", func() {
+		It("forwards to the manager", func() {
+			Expect(client.Down(context.Background(), "some-handle", "10.255.0.5/24", "some-spec")).To(Succeed())
+
+			Expect(manager.DownCallCount()).To(Equal(1))
+			_, handle, gardenNetworkSpec, networkSpec := manager.DownArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(gardenNetworkSpec).To(Equal("10.255.0.5/24"))
+			Expect(networkSpec).To(Equal("some-spec"))
+		})
+
+		Context("when the manager fails", func() {
+			It("returns the manager's error", func() {
+				manager.DownReturns(errors.New("potato"))
+				Expect(client.Down(context.Background(), "some-handle", "", "")).To(MatchError("potato"))
+			})
+		})
+	})
+
+	Describe("Check", func() {
+		var report map[string]controller.CheckResult
+
+		BeforeEach(func() {
+			report = map[string]controller.CheckResult{
+				"some-net": {Interface: "eth0", Status: controller.CheckStatusFailed, Error: "drifted"},
+			}
+		})
+
+		It("forwards to the manager and returns its report", func() {
+			manager.CheckReturns(report, nil)
+
+			Expect(client.Check(context.Background(), "some-handle")).To(Equal(report))
+			_, handle := manager.CheckArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+		})
+
+		Context("when the manager fails", func() {
+			It("returns the report along with the error", func() {
+				manager.CheckReturns(report, errors.New("networks failed check: some-net"))
+
+				returnedReport, err := client.Check(context.Background(), "some-handle")
+				Expect(err).To(MatchError("networks failed check: some-net"))
+				Expect(returnedReport).To(Equal(report))
+			})
+		})
+	})
+
+	Context("when the container's log can be opened", func() {
+		var (
+			daemonLogs *bytes.Buffer
+			handleLogs *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			daemonLogs = &bytes.Buffer{}
+			handleLogs = &bytes.Buffer{}
+			logging.SetDefault(logging.New(daemonLogs, logging.LevelInfo))
+			server.HandleLog = func(handle string) (io.WriteCloser, error) {
+				Expect(handle).To(Equal("some-handle"))
+				return nopCloser{handleLogs}, nil
+			}
+		})
+
+		AfterEach(func() {
+			logging.SetDefault(logging.New(os.Stderr, logging.LevelInfo))
+		})
+
+		It("writes the request's lines to it as well as to the daemon's log", func() {
+			manager.DownStub = func(ctx context.Context, _, _, _ string) error {
+				logging.FromContext(ctx).Info("some line from the manager")
+				return nil
+			}
+
+			Expect(client.Down(context.Background(), "some-handle", "", "")).To(Succeed())
+
+			for _, logs := range []*bytes.Buffer{daemonLogs, handleLogs} {
+				Expect(logs.String()).To(ContainSubstring(`"message":"handling down for some-handle"`))
+				Expect(logs.String()).To(ContainSubstring(`"message":"some line from the manager"`))
+				Expect(logs.String()).To(ContainSubstring(`"message":"down complete"`))
+			}
+		})
+	})
+
+	Describe("Status", func() {
+		It("reports the loaded networks and the requests handled", func() {
+			networkConfigs.NetworksReturns([]string{"net-a", "net-b"}, nil)
+			manager.DownReturns(errors.New("potato"))
+
+			_, err := client.Up(context.Background(), 42, "some-handle", "", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Down(context.Background(), "some-handle", "", "")).NotTo(Succeed())
+
+			status, err := client.Status()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.Pid).To(Equal(os.Getpid()))
+			Expect(status.StartedAt).NotTo(BeZero())
+			Expect(status.Networks).To(Equal([]string{"net-a", "net-b"}))
+			Expect(status.Requests).To(Equal(map[string]int{"up": 1, "down": 1}))
+			Expect(status.Failures).To(Equal(map[string]int{"down": 1}))
+		})
+
+		Context("when the network configs cannot be loaded", func() {
+			It("returns an error", func() {
+				networkConfigs.NetworksReturns(nil, errors.New("potato"))
+
+				_, err := client.Status()
+				Expect(err).To(MatchError("potato"))
+			})
+		})
+	})
+
+	Describe("Reload", func() {
+		It("reloads the network configs", func() {
+			server.Reload()
+			Expect(networkConfigs.ReloadCallCount()).To(Equal(1))
+		})
+
+		Context("when the network configs are no longer valid", func() {
+			var logs *bytes.Buffer
+
+			BeforeEach(func() {
+				logs = &bytes.Buffer{}
+				logging.SetDefault(logging.New(logs, logging.LevelInfo))
+				networkConfigs.ReloadReturns(errors.New("potato"))
+			})
+
+			AfterEach(func() {
+				logging.SetDefault(logging.New(os.Stderr, logging.LevelInfo))
+			})
+
+			It("logs that the previous ones are kept", func() {
+				server.Reload()
+				Expect(logs.String()).To(ContainSubstring(`"message":"reloading network configs failed, keeping the previous ones"`))
+				Expect(logs.String()).To(ContainSubstring(`"error":"potato"`))
+			})
+		})
+	})
+
+	Context("when a request is still in flight", func() {
+		It("handles requests for other containers meanwhile", func() {
+			release := make(chan struct{})
+			manager.UpStub = func(context.Context, int, string, string, string) (*controller.UpResult, error) {
+				<-release
+				return &controller.UpResult{}, nil
+			}
+
+			upDone := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+				_, err := client.Up(context.Background(), 42, "some-handle", "", "")
+				upDone <- err
+			}()
+			Eventually(manager.UpCallCount).Should(Equal(1))
+
+			Expect(client.Down(context.Background(), "other-handle", "", "")).To(Succeed())
+
+			close(release)
+			Eventually(upDone).Should(Receive(BeNil()))
+		})
+	})
+
+	Context("when a request uses the wrong method", func() {
+		It("is rejected", func() {
+			httpClient := &http.Client{Transport: &http.Transport{
+				Dial: func(_, _ string) (net.Conn, error) {
+					return net.Dial("unix", socketPath)
+				},
+			}}
+
+			response, err := httpClient.Get("http://daemon/up")
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+			Expect(manager.UpCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the daemon is not running", func() {
+		It("returns an error naming the socket", func() {
+			client = &daemon.Client{SocketPath: filepath.Join(socketDir, "missing.sock")}
+
+			_, err := client.Up(context.Background(), 42, "some-handle", "", "")
+			Expect(err).To(MatchError(HavePrefix("daemon is not running on " + client.SocketPath + ": ")))
+			Expect(manager.UpCallCount()).To(Equal(0))
+		})
+
+		Context("when the client has a fallback", func() {
+			var fallback *fakes.Manager
+
+			BeforeEach(func() {
+				fallback = &fakes.Manager{}
+				client = &daemon.Client{SocketPath: filepath.Join(socketDir, "missing.sock"), Fallback: fallback}
+			})
+
+			It("handles each request with the fallback", func() {
+				fallback.UpReturns(&controller.UpResult{Handle: "some-handle"}, nil)
+				result, err := client.Up(context.Background(), 42, "some-handle", "some-network", "some-properties")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Handle).To(Equal("some-handle"))
+				_, pid, handle, network, properties := fallback.UpArgsForCall(0)
+				Expect([]interface{}{pid, handle, network, properties}).To(Equal([]interface{}{42, "some-handle", "some-network", "some-properties"}))
+
+				fallback.DownReturns(errors.New("boom"))
+				Expect(client.Down(context.Background(), "some-handle", "", "")).To(MatchError("boom"))
+
+				_, err = client.Check(context.Background(), "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fallback.CheckCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Context("when the daemon is running and fails", func() {
+		It("does not hand the request to the fallback", func() {
+			fallback := &fakes.Manager{}
+			client.Fallback = fallback
+			manager.DownReturns(errors.New("boom"))
+
+			Expect(client.Down(context.Background(), "some-handle", "", "")).To(MatchError("boom"))
+			Expect(fallback.DownCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when a stale socket is left behind", func() {
+		It("replaces it", func() {
+			staleSocketPath := filepath.Join(socketDir, "stale.sock")
+			staleListener, err := net.Listen("unix", staleSocketPath)
+			Expect(err).NotTo(HaveOccurred())
+			staleListener.(*net.UnixListener).SetUnlinkOnClose(false)
+			Expect(staleListener.Close()).To(Succeed())
+			Expect(staleSocketPath).To(BeAnExistingFile())
+
+			listener, err := daemon.Listen(staleSocketPath)
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+		})
+	})
+
+	Context("when another daemon is listening on the socket", func() {
+		It("returns an error and leaves the socket alone", func() {
+			_, err := daemon.Listen(socketPath)
+			Expect(err).To(MatchError("a daemon is already listening on " + socketPath))
+
+			_, err = client.Status()
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when something other than a socket is at the path", func() {
+		It("returns an error rather than removing it", func() {
+			filePath := filepath.Join(socketDir, "some-file")
+			Expect(ioutil.WriteFile(filePath, nil, 0600)).To(Succeed())
+
+			_, err := daemon.Listen(filePath)
+			Expect(err).To(MatchError(HavePrefix("listening on " + filePath)))
+			Expect(filePath).To(BeAnExistingFile())
+		})
+	})
+})
@@ -1,75 +1,151 @@
 // This file was generated by counterfeiter
 package fakes
 
-import "sync"
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+)
 
 type CNIController struct {
-	UpStub        func(namespacePath, handle, spec string) error
-	upMutex       sync.RWMutex
-	upArgsForCall []struct {
+	PlanStub        func(ctx context.Context, gardenNetworkSpec, spec string) ([]controller.NetworkAttachment, error)
+	planMutex       sync.RWMutex
+	planArgsForCall []struct {
+		ctx               context.Context
+		gardenNetworkSpec string
+		spec              string
+	}
+	planReturns struct {
+		result1 []controller.NetworkAttachment
+		result2 error
+	}
+	AddStub        func(ctx context.Context, namespacePath, handle string, attachment controller.NetworkAttachment) (controller.NetworkResult, json.RawMessage, error)
+	addMutex       sync.RWMutex
+	addArgsForCall []struct {
+		ctx           context.Context
 		namespacePath string
 		handle        string
-		spec          string
+		attachment    controller.NetworkAttachment
 	}
-	upReturns struct {
-		result1 error
+	addReturns struct {
+		result1 controller.NetworkResult
+		result2 json.RawMessage
+		result3 error
 	}
-	DownStub        func(namespacePath, handle, spec string) error
+	DownStub        func(ctx context.Context, namespacePath, handle, gardenNetworkSpec, spec string, attachments []controller.NetworkAttachment) error
 	downMutex       sync.RWMutex
 	downArgsForCall []struct {
-		namespacePath string
-		handle        string
-		spec          string
+		ctx               context.Context
+		namespacePath     string
+		handle            string
+		gardenNetworkSpec string
+		spec              string
+		attachments       []controller.NetworkAttachment
 	}
 	downReturns struct {
 		result1 error
 	}
+	CheckStub        func(ctx context.Context, namespacePath, handle string, attachments []controller.NetworkAttachment) (map[string]controller.CheckResult, error)
+	checkMutex       sync.RWMutex
+	checkArgsForCall []struct {
+		ctx           context.Context
+		namespacePath string
+		handle        string
+		attachments   []controller.NetworkAttachment
+	}
+	checkReturns struct {
+		result1 map[string]controller.CheckResult
+		result2 error
+	}
+}
+
+func (fake *CNIController) Plan(ctx context.Context, gardenNetworkSpec string, spec string) ([]controller.NetworkAttachment, error) {
+	fake.planMutex.Lock()
+	fake.planArgsForCall = append(fake.planArgsForCall, struct {
+		ctx               context.Context
+		gardenNetworkSpec string
+		spec              string
+	}{ctx, gardenNetworkSpec, spec})
+	fake.planMutex.Unlock()
+	if fake.PlanStub != nil {
+		return fake.PlanStub(ctx, gardenNetworkSpec, spec)
+	} else {
+		return fake.planReturns.result1, fake.planReturns.result2
+	}
+}
+
+func (fake *CNIController) PlanCallCount() int {
+	fake.planMutex.RLock()
+	defer fake.planMutex.RUnlock()
+	return len(fake.planArgsForCall)
+}
+
+func (fake *CNIController) PlanArgsForCall(i int) (context.Context, string, string) {
+	fake.planMutex.RLock()
+	defer fake.planMutex.RUnlock()
+	return fake.planArgsForCall[i].ctx, fake.planArgsForCall[i].gardenNetworkSpec, fake.planArgsForCall[i].spec
 }
 
-func (fake *CNIController) Up(namespacePath string, handle string, spec string) error {
-	fake.upMutex.Lock()
-	fake.upArgsForCall = append(fake.upArgsForCall, struct {
+func (fake *CNIController) PlanReturns(result1 []controller.NetworkAttachment, result2 error) {
+	fake.PlanStub = nil
+	fake.planReturns = struct {
+		result1 []controller.NetworkAttachment
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CNIController) Add(ctx context.Context, namespacePath string, handle string, attachment controller.NetworkAttachment) (controller.NetworkResult, json.RawMessage, error) {
+	fake.addMutex.Lock()
+	fake.addArgsForCall = append(fake.addArgsForCall, struct {
+		ctx           context.Context
 		namespacePath string
 		handle        string
-		spec          string
-	}{namespacePath, handle, spec})
-	fake.upMutex.Unlock()
-	if fake.UpStub != nil {
-		return fake.UpStub(namespacePath, handle, spec)
+		attachment    controller.NetworkAttachment
+	}{ctx, namespacePath, handle, attachment})
+	fake.addMutex.Unlock()
+	if fake.AddStub != nil {
+		return fake.AddStub(ctx, namespacePath, handle, attachment)
 	} else {
-		return fake.upReturns.result1
+		return fake.addReturns.result1, fake.addReturns.result2, fake.addReturns.result3
 	}
 }
 
-func (fake *CNIController) UpCallCount() int {
-	fake.upMutex.RLock()
-	defer fake.upMutex.RUnlock()
-	return len(fake.upArgsForCall)
+func (fake *CNIController) AddCallCount() int {
+	fake.addMutex.RLock()
+	defer fake.addMutex.RUnlock()
+	return len(fake.addArgsForCall)
 }
 
-func (fake *CNIController) UpArgsForCall(i int) (string, string, string) {
-	fake.upMutex.RLock()
-	defer fake.upMutex.RUnlock()
-	return fake.upArgsForCall[i].namespacePath, fake.upArgsForCall[i].handle, fake.upArgsForCall[i].spec
+func (fake *CNIController) AddArgsForCall(i int) (context.Context, string, string, controller.NetworkAttachment) {
+	fake.addMutex.RLock()
+	defer fake.addMutex.RUnlock()
+	return fake.addArgsForCall[i].ctx, fake.addArgsForCall[i].namespacePath, fake.addArgsForCall[i].handle, fake.addArgsForCall[i].attachment
 }
 
-func (fake *CNIController) UpReturns(result1 error) {
-	fake.UpStub = nil
-	fake.upReturns = struct {
-		result1 error
-	}{result1}
+func (fake *CNIController) AddReturns(result1 controller.NetworkResult, result2 json.RawMessage, result3 error) {
+	fake.AddStub = nil
+	fake.addReturns = struct {
+		result1 controller.NetworkResult
+		result2 json.RawMessage
+		result3 error
+	}{result1, result2, result3}
 }
 
-func (fake *CNIController) Down(namespacePath string, handle string, spec string) error {
+func (fake *CNIController) Down(ctx context.Context, namespacePath string, handle string, gardenNetworkSpec string, spec string, attachments []controller.NetworkAttachment) error {
 	fake.downMutex.Lock()
 	fake.downArgsForCall = append(fake.downArgsForCall, struct {
-		namespacePath string
-		handle        string
-		spec          string
-	}{namespacePath, handle, spec})
+		ctx               context.Context
+		namespacePath     string
+		handle            string
+		gardenNetworkSpec string
+		spec              string
+		attachments       []controller.NetworkAttachment
+	}{ctx, namespacePath, handle, gardenNetworkSpec, spec, attachments})
 	fake.downMutex.Unlock()
 	if fake.DownStub != nil {
-		return fake.DownStub(namespacePath, handle, spec)
+		return fake.DownStub(ctx, namespacePath, handle, gardenNetworkSpec, spec, attachments)
 	} else {
 		return fake.downReturns.result1
 	}
@@ -81,10 +157,10 @@ func (fake *CNIController) DownCallCount() int {
 	return len(fake.downArgsForCall)
 }
 
-func (fake *CNIController) DownArgsForCall(i int) (string, string, string) {
+func (fake *CNIController) DownArgsForCall(i int) (context.Context, string, string, string, string, []controller.NetworkAttachment) {
 	fake.downMutex.RLock()
 	defer fake.downMutex.RUnlock()
-	return fake.downArgsForCall[i].namespacePath, fake.downArgsForCall[i].handle, fake.downArgsForCall[i].spec
+	return fake.downArgsForCall[i].ctx, fake.downArgsForCall[i].namespacePath, fake.downArgsForCall[i].handle, fake.downArgsForCall[i].gardenNetworkSpec, fake.downArgsForCall[i].spec, fake.downArgsForCall[i].attachments
 }
 
 func (fake *CNIController) DownReturns(result1 error) {
@@ -93,3 +169,39 @@ func (fake *CNIController) DownReturns(result1 error) {
 		result1 error
 	}{result1}
 }
+
+func (fake *CNIController) Check(ctx context.Context, namespacePath string, handle string, attachments []controller.NetworkAttachment) (map[string]controller.CheckResult, error) {
+	fake.checkMutex.Lock()
+	fake.checkArgsForCall = append(fake.checkArgsForCall, struct {
+		ctx           context.Context
+		namespacePath string
+		handle        string
+		attachments   []controller.NetworkAttachment
+	}{ctx, namespacePath, handle, attachments})
+	fake.checkMutex.Unlock()
+	if fake.CheckStub != nil {
+		return fake.CheckStub(ctx, namespacePath, handle, attachments)
+	} else {
+		return fake.checkReturns.result1, fake.checkReturns.result2
+	}
+}
+
+func (fake *CNIController) CheckCallCount() int {
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	return len(fake.checkArgsForCall)
+}
+
+func (fake *CNIController) CheckArgsForCall(i int) (context.Context, string, string, []controller.NetworkAttachment) {
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	return fake.checkArgsForCall[i].ctx, fake.checkArgsForCall[i].namespacePath, fake.checkArgsForCall[i].handle, fake.checkArgsForCall[i].attachments
+}
+
+func (fake *CNIController) CheckReturns(result1 map[string]controller.CheckResult, result2 error) {
+	fake.CheckStub = nil
+	fake.checkReturns = struct {
+		result1 map[string]controller.CheckResult
+		result2 error
+	}{result1, result2}
+}
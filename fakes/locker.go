@@ -0,0 +1,87 @@
+// This file was generated by counterfeiter
+package fakes
+
+import "sync"
+
+type Locker struct {
+	LockStub        func(handle string) error
+	lockMutex       sync.RWMutex
+	lockArgsForCall []struct {
+		handle string
+	}
+	lockReturns struct {
+		result1 error
+	}
+	UnlockStub        func(handle string) error
+	unlockMutex       sync.RWMutex
+	unlockArgsForCall []struct {
+		handle string
+	}
+	unlockReturns struct {
+		result1 error
+	}
+}
+
+func (fake *Locker) Lock(handle string) error {
+	fake.lockMutex.Lock()
+	fake.lockArgsForCall = append(fake.lockArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.lockMutex.Unlock()
+	if fake.LockStub != nil {
+		return fake.LockStub(handle)
+	} else {
+		return fake.lockReturns.result1
+	}
+}
+
+func (fake *Locker) LockCallCount() int {
+	fake.lockMutex.RLock()
+	defer fake.lockMutex.RUnlock()
+	return len(fake.lockArgsForCall)
+}
+
+func (fake *Locker) LockArgsForCall(i int) string {
+	fake.lockMutex.RLock()
+	defer fake.lockMutex.RUnlock()
+	return fake.lockArgsForCall[i].handle
+}
+
+func (fake *Locker) LockReturns(result1 error) {
+	fake.LockStub = nil
+	fake.lockReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Locker) Unlock(handle string) error {
+	fake.unlockMutex.Lock()
+	fake.unlockArgsForCall = append(fake.unlockArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.unlockMutex.Unlock()
+	if fake.UnlockStub != nil {
+		return fake.UnlockStub(handle)
+	} else {
+		return fake.unlockReturns.result1
+	}
+}
+
+func (fake *Locker) UnlockCallCount() int {
+	fake.unlockMutex.RLock()
+	defer fake.unlockMutex.RUnlock()
+	return len(fake.unlockArgsForCall)
+}
+
+func (fake *Locker) UnlockArgsForCall(i int) string {
+	fake.unlockMutex.RLock()
+	defer fake.unlockMutex.RUnlock()
+	return fake.unlockArgsForCall[i].handle
+}
+
+func (fake *Locker) UnlockReturns(result1 error) {
+	fake.UnlockStub = nil
+	fake.unlockReturns = struct {
+		result1 error
+	}{result1}
+}
@@ -0,0 +1,152 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+)
+
+type Manager struct {
+	UpStub        func(ctx context.Context, pid int, containerHandle, gardenNetworkSpec, networkSpec string) (*controller.UpResult, error)
+	upMutex       sync.RWMutex
+	upArgsForCall []struct {
+		ctx               context.Context
+		pid               int
+		containerHandle   string
+		gardenNetworkSpec string
+		networkSpec       string
+	}
+	upReturns struct {
+		result1 *controller.UpResult
+		result2 error
+	}
+	DownStub        func(ctx context.Context, containerHandle, gardenNetworkSpec, networkSpec string) error
+	downMutex       sync.RWMutex
+	downArgsForCall []struct {
+		ctx               context.Context
+		containerHandle   string
+		gardenNetworkSpec string
+		networkSpec       string
+	}
+	downReturns struct {
+		result1 error
+	}
+	CheckStub        func(ctx context.Context, containerHandle string) (map[string]controller.CheckResult, error)
+	checkMutex       sync.RWMutex
+	checkArgsForCall []struct {
+		ctx             context.Context
+		containerHandle string
+	}
+	checkReturns struct {
+		result1 map[string]controller.CheckResult
+		result2 error
+	}
+}
+
+func (fake *Manager) Up(ctx context.Context, pid int, containerHandle string, gardenNetworkSpec string, networkSpec string) (*controller.UpResult, error) {
+	fake.upMutex.Lock()
+	fake.upArgsForCall = append(fake.upArgsForCall, struct {
+		ctx               context.Context
+		pid               int
+		containerHandle   string
+		gardenNetworkSpec string
+		networkSpec       string
+	}{ctx, pid, containerHandle, gardenNetworkSpec, networkSpec})
+	fake.upMutex.Unlock()
+	if fake.UpStub != nil {
+		return fake.UpStub(ctx, pid, containerHandle, gardenNetworkSpec, networkSpec)
+	} else {
+		return fake.upReturns.result1, fake.upReturns.result2
+	}
+}
+
+func (fake *Manager) UpCallCount() int {
+	fake.upMutex.RLock()
+	defer fake.upMutex.RUnlock()
+	return len(fake.upArgsForCall)
+}
+
+func (fake *Manager) UpArgsForCall(i int) (context.Context, int, string, string, string) {
+	fake.upMutex.RLock()
+	defer fake.upMutex.RUnlock()
+	return fake.upArgsForCall[i].ctx, fake.upArgsForCall[i].pid, fake.upArgsForCall[i].containerHandle, fake.upArgsForCall[i].gardenNetworkSpec, fake.upArgsForCall[i].networkSpec
+}
+
+func (fake *Manager) UpReturns(result1 *controller.UpResult, result2 error) {
+	fake.UpStub = nil
+	fake.upReturns = struct {
+		result1 *controller.UpResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Manager) Down(ctx context.Context, containerHandle string, gardenNetworkSpec string, networkSpec string) error {
+	fake.downMutex.Lock()
+	fake.downArgsForCall = append(fake.downArgsForCall, struct {
+		ctx               context.Context
+		containerHandle   string
+		gardenNetworkSpec string
+		networkSpec       string
+	}{ctx, containerHandle, gardenNetworkSpec, networkSpec})
+	fake.downMutex.Unlock()
+	if fake.DownStub != nil {
+		return fake.DownStub(ctx, containerHandle, gardenNetworkSpec, networkSpec)
+	} else {
+		return fake.downReturns.result1
+	}
+}
+
+func (fake *Manager) DownCallCount() int {
+	fake.downMutex.RLock()
+	defer fake.downMutex.RUnlock()
+	return len(fake.downArgsForCall)
+}
+
+func (fake *Manager) DownArgsForCall(i int) (context.Context, string, string, string) {
+	fake.downMutex.RLock()
+	defer fake.downMutex.RUnlock()
+	return fake.downArgsForCall[i].ctx, fake.downArgsForCall[i].containerHandle, fake.downArgsForCall[i].gardenNetworkSpec, fake.downArgsForCall[i].networkSpec
+}
+
+func (fake *Manager) DownReturns(result1 error) {
+	fake.DownStub = nil
+	fake.downReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Manager) Check(ctx context.Context, containerHandle string) (map[string]controller.CheckResult, error) {
+	fake.checkMutex.Lock()
+	fake.checkArgsForCall = append(fake.checkArgsForCall, struct {
+		ctx             context.Context
+		containerHandle string
+	}{ctx, containerHandle})
+	fake.checkMutex.Unlock()
+	if fake.CheckStub != nil {
+		return fake.CheckStub(ctx, containerHandle)
+	} else {
+		return fake.checkReturns.result1, fake.checkReturns.result2
+	}
+}
+
+func (fake *Manager) CheckCallCount() int {
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	return len(fake.checkArgsForCall)
+}
+
+func (fake *Manager) CheckArgsForCall(i int) (context.Context, string) {
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	return fake.checkArgsForCall[i].ctx, fake.checkArgsForCall[i].containerHandle
+}
+
+func (fake *Manager) CheckReturns(result1 map[string]controller.CheckResult, result2 error) {
+	fake.CheckStub = nil
+	fake.checkReturns = struct {
+		result1 map[string]controller.CheckResult
+		result2 error
+	}{result1, result2}
+}
@@ -21,6 +21,15 @@ type Mounter struct {
 	removeMountReturns struct {
 		result1 error
 	}
+	IsMountedStub        func(target string) (bool, error)
+	isMountedMutex       sync.RWMutex
+	isMountedArgsForCall []struct {
+		target string
+	}
+	isMountedReturns struct {
+		result1 bool
+		result2 error
+	}
 }
 
 func (fake *Mounter) IdempotentlyMount(source string, target string) error {
@@ -87,3 +96,36 @@ func (fake *Mounter) RemoveMountReturns(result1 error) {
 		result1 error
 	}{result1}
 }
+
+func (fake *Mounter) IsMounted(target string) (bool, error) {
+	fake.isMountedMutex.Lock()
+	fake.isMountedArgsForCall = append(fake.isMountedArgsForCall, struct {
+		target string
+	}{target})
+	fake.isMountedMutex.Unlock()
+	if fake.IsMountedStub != nil {
+		return fake.IsMountedStub(target)
+	} else {
+		return fake.isMountedReturns.result1, fake.isMountedReturns.result2
+	}
+}
+
+func (fake *Mounter) IsMountedCallCount() int {
+	fake.isMountedMutex.RLock()
+	defer fake.isMountedMutex.RUnlock()
+	return len(fake.isMountedArgsForCall)
+}
+
+func (fake *Mounter) IsMountedArgsForCall(i int) string {
+	fake.isMountedMutex.RLock()
+	defer fake.isMountedMutex.RUnlock()
+	return fake.isMountedArgsForCall[i].target
+}
+
+func (fake *Mounter) IsMountedReturns(result1 bool, result2 error) {
+	fake.IsMountedStub = nil
+	fake.isMountedReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
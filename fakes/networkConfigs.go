@@ -0,0 +1,69 @@
+// This file was generated by counterfeiter
+package fakes
+
+import "sync"
+
+type NetworkConfigs struct {
+	NetworksStub        func() ([]string, error)
+	networksMutex       sync.RWMutex
+	networksArgsForCall []struct{}
+	networksReturns     struct {
+		result1 []string
+		result2 error
+	}
+	ReloadStub        func() error
+	reloadMutex       sync.RWMutex
+	reloadArgsForCall []struct{}
+	reloadReturns     struct {
+		result1 error
+	}
+}
+
+func (fake *NetworkConfigs) Networks() ([]string, error) {
+	fake.networksMutex.Lock()
+	fake.networksArgsForCall = append(fake.networksArgsForCall, struct{}{})
+	fake.networksMutex.Unlock()
+	if fake.NetworksStub != nil {
+		return fake.NetworksStub()
+	} else {
+		return fake.networksReturns.result1, fake.networksReturns.result2
+	}
+}
+
+func (fake *NetworkConfigs) NetworksCallCount() int {
+	fake.networksMutex.RLock()
+	defer fake.networksMutex.RUnlock()
+	return len(fake.networksArgsForCall)
+}
+
+func (fake *NetworkConfigs) NetworksReturns(result1 []string, result2 error) {
+	fake.NetworksStub = nil
+	fake.networksReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *NetworkConfigs) Reload() error {
+	fake.reloadMutex.Lock()
+	fake.reloadArgsForCall = append(fake.reloadArgsForCall, struct{}{})
+	fake.reloadMutex.Unlock()
+	if fake.ReloadStub != nil {
+		return fake.ReloadStub()
+	} else {
+		return fake.reloadReturns.result1
+	}
+}
+
+func (fake *NetworkConfigs) ReloadCallCount() int {
+	fake.reloadMutex.RLock()
+	defer fake.reloadMutex.RUnlock()
+	return len(fake.reloadArgsForCall)
+}
+
+func (fake *NetworkConfigs) ReloadReturns(result1 error) {
+	fake.ReloadStub = nil
+	fake.reloadReturns = struct {
+		result1 error
+	}{result1}
+}
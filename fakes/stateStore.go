@@ -0,0 +1,205 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+)
+
+type StateStore struct {
+	SaveStub        func(state *controller.ContainerState) error
+	saveMutex       sync.RWMutex
+	saveArgsForCall []struct {
+		state *controller.ContainerState
+	}
+	saveReturns struct {
+		result1 error
+	}
+	LoadStub        func(handle string) (*controller.ContainerState, error)
+	loadMutex       sync.RWMutex
+	loadArgsForCall []struct {
+		handle string
+	}
+	loadReturns struct {
+		result1 *controller.ContainerState
+		result2 error
+	}
+	ListStub        func() ([]string, error)
+	listMutex       sync.RWMutex
+	listArgsForCall []struct{}
+	listReturns     struct {
+		result1 []string
+		result2 error
+	}
+	DeleteStub        func(handle string) error
+	deleteMutex       sync.RWMutex
+	deleteArgsForCall []struct {
+		handle string
+	}
+	deleteReturns struct {
+		result1 error
+	}
+	MoveAsideStub        func(handle string) error
+	moveAsideMutex       sync.RWMutex
+	moveAsideArgsForCall []struct {
+		handle string
+	}
+	moveAsideReturns struct {
+		result1 error
+	}
+}
+
+func (fake *StateStore) Save(state *controller.ContainerState) error {
+	fake.saveMutex.Lock()
+	fake.saveArgsForCall = append(fake.saveArgsForCall, struct {
+		state *controller.ContainerState
+	}{state})
+	fake.saveMutex.Unlock()
+	if fake.SaveStub != nil {
+		return fake.SaveStub(state)
+	} else {
+		return fake.saveReturns.result1
+	}
+}
+
+func (fake *StateStore) SaveCallCount() int {
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	return len(fake.saveArgsForCall)
+}
+
+func (fake *StateStore) SaveArgsForCall(i int) *controller.ContainerState {
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	return fake.saveArgsForCall[i].state
+}
+
+func (fake *StateStore) SaveReturns(result1 error) {
+	fake.SaveStub = nil
+	fake.saveReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *StateStore) Load(handle string) (*controller.ContainerState, error) {
+	fake.loadMutex.Lock()
+	fake.loadArgsForCall = append(fake.loadArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.loadMutex.Unlock()
+	if fake.LoadStub != nil {
+		return fake.LoadStub(handle)
+	} else {
+		return fake.loadReturns.result1, fake.loadReturns.result2
+	}
+}
+
+func (fake *StateStore) LoadCallCount() int {
+	fake.loadMutex.RLock()
+	defer fake.loadMutex.RUnlock()
+	return len(fake.loadArgsForCall)
+}
+
+func (fake *StateStore) LoadArgsForCall(i int) string {
+	fake.loadMutex.RLock()
+	defer fake.loadMutex.RUnlock()
+	return fake.loadArgsForCall[i].handle
+}
+
+func (fake *StateStore) LoadReturns(result1 *controller.ContainerState, result2 error) {
+	fake.LoadStub = nil
+	fake.loadReturns = struct {
+		result1 *controller.ContainerState
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *StateStore) List() ([]string, error) {
+	fake.listMutex.Lock()
+	fake.listArgsForCall = append(fake.listArgsForCall, struct{}{})
+	fake.listMutex.Unlock()
+	if fake.ListStub != nil {
+		return fake.ListStub()
+	} else {
+		return fake.listReturns.result1, fake.listReturns.result2
+	}
+}
+
+func (fake *StateStore) ListCallCount() int {
+	fake.listMutex.RLock()
+	defer fake.listMutex.RUnlock()
+	return len(fake.listArgsForCall)
+}
+
+func (fake *StateStore) ListReturns(result1 []string, result2 error) {
+	fake.ListStub = nil
+	fake.listReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *StateStore) Delete(handle string) error {
+	fake.deleteMutex.Lock()
+	fake.deleteArgsForCall = append(fake.deleteArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.deleteMutex.Unlock()
+	if fake.DeleteStub != nil {
+		return fake.DeleteStub(handle)
+	} else {
+		return fake.deleteReturns.result1
+	}
+}
+
+func (fake *StateStore) DeleteCallCount() int {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	return len(fake.deleteArgsForCall)
+}
+
+func (fake *StateStore) DeleteArgsForCall(i int) string {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	return fake.deleteArgsForCall[i].handle
+}
+
+func (fake *StateStore) DeleteReturns(result1 error) {
+	fake.DeleteStub = nil
+	fake.deleteReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *StateStore) MoveAside(handle string) error {
+	fake.moveAsideMutex.Lock()
+	fake.moveAsideArgsForCall = append(fake.moveAsideArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.moveAsideMutex.Unlock()
+	if fake.MoveAsideStub != nil {
+		return fake.MoveAsideStub(handle)
+	} else {
+		return fake.moveAsideReturns.result1
+	}
+}
+
+func (fake *StateStore) MoveAsideCallCount() int {
+	fake.moveAsideMutex.RLock()
+	defer fake.moveAsideMutex.RUnlock()
+	return len(fake.moveAsideArgsForCall)
+}
+
+func (fake *StateStore) MoveAsideArgsForCall(i int) string {
+	fake.moveAsideMutex.RLock()
+	defer fake.moveAsideMutex.RUnlock()
+	return fake.moveAsideArgsForCall[i].handle
+}
+
+func (fake *StateStore) MoveAsideReturns(result1 error) {
+	fake.MoveAsideStub = nil
+	fake.moveAsideReturns = struct {
+		result1 error
+	}{result1}
+}
@@ -0,0 +1,261 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// ArchiveDir is where the janitor compresses logs, under the log directory.
+const ArchiveDir = "archive"
+
+var logFileName = regexp.MustCompile(`^(.+)\.log(\.[0-9]+)?$`)
+
+// Janitor bounds a log directory: it removes, or archives, the logs of
+// containers that were torn down more than Retention ago, then deletes the
+// oldest archives, rotated backups and logs of torn down containers until
+// the directory fits MaxTotalSize.  Zero disables either.
+type Janitor struct {
+	Dir          string
+	Retention    time.Duration
+	Archive      bool
+	MaxTotalSize int64
+
+	// Finished reports whether the logs by the given name, such as a handle,
+	// belong to a container that has been torn down.
+	Finished func(name string) (bool, error)
+
+	// Active names the log being written, which is never removed.
+	Active string
+}
+
+type logFile struct {
+	path    string
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// Sweep applies the retention, then the size cap, as of now.
+func (j *Janitor) Sweep(now time.Time) error {
+	if j.Retention > 0 {
+		err := j.expire(now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if j.MaxTotalSize > 0 {
+		return j.enforceCap()
+	}
+
+	return nil
+}
+
+// expire goes by the last write to any of a name's logs, which for a torn
+// down container is its down.
+func (j *Janitor) expire(now time.Time) error {
+	files, err := j.logFiles()
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string][]logFile)
+	lastWritten := make(map[string]time.Time)
+	for _, file := range files {
+		byName[file.name] = append(byName[file.name], file)
+		if file.modTime.After(lastWritten[file.name]) {
+			lastWritten[file.name] = file.modTime
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == j.Active || now.Sub(lastWritten[name]) < j.Retention {
+			continue
+		}
+
+		finished, err := j.Finished(name)
+		if err != nil {
+			return fmt.Errorf("checking whether %s is finished: %s", name, err)
+		}
+		if !finished {
+			continue
+		}
+
+		for _, file := range byName[name] {
+			err = j.retire(file)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (j *Janitor) retire(file logFile) error {
+	if j.Archive {
+		err := j.archive(file)
+		if err != nil {
+			return fmt.Errorf("archiving %s: %s", file.path, err)
+		}
+	}
+
+	err := os.Remove(file.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %s", file.path, err) // not tested
+	}
+	return nil
+}
+
+// archive compresses the file as archive/<file>.<last written>.gz, so that
+// the logs of a reused handle do not collide.
+func (j *Janitor) archive(file logFile) error {
+	archiveDir := filepath.Join(j.Dir, ArchiveDir)
+	err := os.MkdirAll(archiveDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(file.path)
+	if err != nil {
+		return err // not tested
+	}
+	defer source.Close()
+
+	archiveName := fmt.Sprintf("%s.%s.gz", filepath.Base(file.path), file.modTime.UTC().Format("20060102T150405Z"))
+	archive, err := os.Create(filepath.Join(archiveDir, archiveName))
+	if err != nil {
+		return err // not tested
+	}
+	defer archive.Close()
+
+	writer := gzip.NewWriter(archive)
+	_, err = io.Copy(writer, source)
+	if err != nil {
+		return err // not tested
+	}
+	return writer.Close()
+}
+
+// enforceCap deletes the oldest files first, but only archives, rotated
+// backups and the logs of finished containers: never the log of a container
+// that may still be running, nor those of other actions, such as the daemon.
+func (j *Janitor) enforceCap() error {
+	var files []logFile
+	var total int64
+	err := filepath.Walk(j.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		files = append(files, logFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("measuring log dir: %s", err)
+	}
+
+	sort.SliceStable(files, func(a, b int) bool {
+		return files[a].modTime.Before(files[b].modTime)
+	})
+
+	finished := make(map[string]bool)
+	for _, file := range files {
+		if total <= j.MaxTotalSize {
+			break
+		}
+
+		removable, err := j.removable(file.path, finished)
+		if err != nil {
+			return err
+		}
+		if !removable {
+			continue
+		}
+
+		err = os.Remove(file.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %s", file.path, err) // not tested
+		}
+		total -= file.size
+	}
+
+	return nil
+}
+
+// removable remembers whether each name is finished, so that it is only
+// asked once per sweep.
+func (j *Janitor) removable(path string, finished map[string]bool) (bool, error) {
+	dir, base := filepath.Split(path)
+	if filepath.Clean(dir) == filepath.Join(j.Dir, ArchiveDir) {
+		return true, nil
+	}
+	if filepath.Clean(dir) != filepath.Clean(j.Dir) {
+		return false, nil
+	}
+
+	match := logFileName.FindStringSubmatch(base)
+	if match == nil {
+		return false, nil
+	}
+	if match[2] != "" {
+		return true, nil
+	}
+
+	name := match[1]
+	if name == j.Active {
+		return false, nil
+	}
+	if done, ok := finished[name]; ok {
+		return done, nil
+	}
+
+	done, err := j.Finished(name)
+	if err != nil {
+		return false, fmt.Errorf("checking whether %s is finished: %s", name, err)
+	}
+	finished[name] = done
+	return done, nil
+}
+
+// logFiles are the logs and their backups directly in the log directory.
+func (j *Janitor) logFiles() ([]logFile, error) {
+	infos, err := ioutil.ReadDir(j.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading log dir: %s", err)
+	}
+
+	var files []logFile
+	for _, info := range infos {
+		match := logFileName.FindStringSubmatch(info.Name())
+		if info.IsDir() || match == nil {
+			continue
+		}
+
+		files = append(files, logFile{
+			path:    filepath.Join(j.Dir, info.Name()),
+			name:    match[1],
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	return files, nil
+}
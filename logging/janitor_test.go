@@ -0,0 +1,196 @@
+package logging_test
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Janitor", func() {
+	var (
+		logDir   string
+		now      time.Time
+		finished map[string]bool
+		janitor  *logging.Janitor
+	)
+
+	writeLog := func(name, contents string, age time.Duration) {
+		path := filepath.Join(logDir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		Expect(os.Chtimes(path, now.Add(-age), now.Add(-age))).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		logDir, err = ioutil.TempDir("", "janitor-")
+		Expect(err).NotTo(HaveOccurred())
+
+		now = time.Now()
+		finished = map[string]bool{"some-finished-handle": true, "another-finished-handle": true}
+		janitor = &logging.Janitor{
+			Dir:       logDir,
+			Retention: time.Hour,
+			Finished: func(name string) (bool, error) {
+				return finished[name], nil
+			},
+			Active: "some-active-handle",
+		}
+
+		writeLog("some-finished-handle.log", "some logs", 2*time.Hour)
+		writeLog("some-finished-handle.log.1", "some older logs", 3*time.Hour)
+		writeLog("another-finished-handle.log", "some recent logs", time.Minute)
+		writeLog("some-live-handle.log", "some live logs", 2*time.Hour)
+		writeLog("some-active-handle.log", "some active logs", 2*time.Hour)
+		writeLog("some-other-file", "not a log", 2*time.Hour)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(logDir)).To(Succeed())
+	})
+
+	remaining := func() []string {
+		infos, err := ioutil.ReadDir(logDir)
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		return names
+	}
+
+	It("removes the logs of containers that finished more than the retention ago", func() {
+		Expect(janitor.Sweep(now)).To(Succeed())
+
+		Expect(remaining()).To(ConsistOf(
+			"another-finished-handle.log",
+			"some-live-handle.log",
+			"some-active-handle.log",
+			"some-other-file",
+		))
+	})
+
+	It("asks whether logs are finished only once they are past the retention", func() {
+		var asked []string
+		janitor.Finished = func(name string) (bool, error) {
+			asked = append(asked, name)
+			return finished[name], nil
+		}
+
+		Expect(janitor.Sweep(now)).To(Succeed())
+		Expect(asked).To(Equal([]string{"some-finished-handle", "some-live-handle"}))
+	})
+
+	Context("when archiving", func() {
+		BeforeEach(func() {
+			janitor.Archive = true
+		})
+
+		It("compresses the logs into the archive directory instead", func() {
+			Expect(janitor.Sweep(now)).To(Succeed())
+			Expect(remaining()).NotTo(ContainElement(HavePrefix("some-finished-handle")))
+
+			archived, err := filepath.Glob(filepath.Join(logDir, logging.ArchiveDir, "some-finished-handle.log.1.*.gz"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(archived).To(HaveLen(1))
+
+			archive, err := os.Open(archived[0])
+			Expect(err).NotTo(HaveOccurred())
+			defer archive.Close()
+			reader, err := gzip.NewReader(archive)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(reader)).To(Equal([]byte("some older logs")))
+		})
+	})
+
+	Context("when checking whether a container finished fails", func() {
+		It("returns an error", func() {
+			janitor.Finished = func(name string) (bool, error) {
+				return false, errors.New("potato")
+			}
+
+			Expect(janitor.Sweep(now)).To(MatchError("checking whether some-finished-handle is finished: potato"))
+		})
+	})
+
+	Context("when the log directory exceeds its cap", func() {
+		var archivePath string
+
+		BeforeEach(func() {
+			janitor.Retention = 0
+
+			writeLog("daemon.log", "daemon logs", 4*time.Hour)
+			writeLog("some-live-handle.log.1", "some older live logs", 5*time.Hour)
+
+			Expect(os.Mkdir(filepath.Join(logDir, logging.ArchiveDir), 0755)).To(Succeed())
+			archivePath = filepath.Join(logging.ArchiveDir, "some-handle.log.20200101T000000Z.gz")
+			writeLog(archivePath, "archived logs", 6*time.Hour)
+		})
+
+		It("removes the oldest archives first", func() {
+			janitor.MaxTotalSize = 115
+
+			Expect(janitor.Sweep(now)).To(Succeed())
+			Expect(filepath.Join(logDir, archivePath)).NotTo(BeAnExistingFile())
+			Expect(remaining()).To(ContainElement("some-live-handle.log.1"))
+		})
+
+		It("removes rotated backups, even of a live container's log", func() {
+			janitor.MaxTotalSize = 100
+
+			Expect(janitor.Sweep(now)).To(Succeed())
+			Expect(remaining()).NotTo(ContainElement("some-live-handle.log.1"))
+			Expect(remaining()).To(ContainElement("some-finished-handle.log.1"))
+		})
+
+		It("removes the logs of finished containers", func() {
+			janitor.MaxTotalSize = 70
+
+			Expect(janitor.Sweep(now)).To(Succeed())
+			Expect(remaining()).NotTo(ContainElement("some-finished-handle.log"))
+			Expect(remaining()).To(ContainElement("another-finished-handle.log"))
+		})
+
+		It("never removes the log of a live container, of the daemon or the active log", func() {
+			janitor.MaxTotalSize = 1
+
+			Expect(janitor.Sweep(now)).To(Succeed())
+			Expect(remaining()).To(ConsistOf(
+				logging.ArchiveDir,
+				"daemon.log",
+				"some-live-handle.log",
+				"some-active-handle.log",
+				"some-other-file",
+			))
+		})
+
+		It("asks whether logs are finished only for the logs it would remove", func() {
+			janitor.MaxTotalSize = 70
+			var asked []string
+			janitor.Finished = func(name string) (bool, error) {
+				asked = append(asked, name)
+				return finished[name], nil
+			}
+
+			Expect(janitor.Sweep(now)).To(Succeed())
+			Expect(asked).To(Equal([]string{"daemon", "some-finished-handle"}))
+		})
+
+		Context("when checking whether a container finished fails", func() {
+			It("returns an error", func() {
+				janitor.MaxTotalSize = 1
+				janitor.Finished = func(name string) (bool, error) {
+					return false, errors.New("potato")
+				}
+
+				Expect(janitor.Sweep(now)).To(MatchError("checking whether daemon is finished: potato"))
+			})
+		})
+	})
+})
@@ -0,0 +1,205 @@
+// Package logging writes logs as JSON lines, one object per event, carrying
+// the fields that correlate events, such as the container handle, the
+// network and the plugin.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel parses a level by name, such as "debug".
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if name == levelName {
+			return Level(level), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q: must be %s", name, strings.Join(levelNames, ", "))
+}
+
+// Fields are added to a log line alongside its timestamp, level and message.
+// Durations are written in seconds.
+type Fields map[string]interface{}
+
+// Logger writes lines at or above its level.  Loggers derived from one
+// another share its writer and its lock.
+type Logger struct {
+	out    io.Writer
+	level  Level
+	fields Fields
+	mu     *sync.Mutex
+}
+
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{
+		out:    out,
+		level:  level,
+		fields: Fields{},
+		mu:     &sync.Mutex{},
+	}
+}
+
+// With returns a logger that adds the given fields to every line.
+func (l *Logger) With(fields Fields) *Logger {
+	merged := Fields{}
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+
+	return &Logger{
+		out:    l.out,
+		level:  l.level,
+		fields: merged,
+		mu:     l.mu,
+	}
+}
+
+// Tee returns a logger that also writes every line to out.
+func (l *Logger) Tee(out io.Writer) *Logger {
+	return &Logger{
+		out:    io.MultiWriter(l.out, out),
+		level:  l.level,
+		fields: l.fields,
+		mu:     l.mu,
+	}
+}
+
+func (l *Logger) Debug(message string, fields ...Fields) {
+	l.log(LevelDebug, message, fields)
+}
+
+func (l *Logger) Info(message string, fields ...Fields) {
+	l.log(LevelInfo, message, fields)
+}
+
+func (l *Logger) Warn(message string, fields ...Fields) {
+	l.log(LevelWarn, message, fields)
+}
+
+func (l *Logger) Error(message string, fields ...Fields) {
+	l.log(LevelError, message, fields)
+}
+
+func (l *Logger) log(level Level, message string, fields []Fields) {
+	if level < l.level {
+		return
+	}
+
+	line := Fields{}
+	for key, value := range l.fields {
+		line[key] = value
+	}
+	for _, extra := range fields {
+		for key, value := range extra {
+			line[key] = value
+		}
+	}
+	for key, value := range line {
+		switch value := value.(type) {
+		case time.Duration:
+			line[key] = value.Seconds()
+		case error:
+			line[key] = value.Error()
+		}
+	}
+	line["timestamp"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = level.String()
+	line["message"] = message
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		encoded, _ = json.Marshal(Fields{
+			"timestamp": line["timestamp"],
+			"level":     LevelError.String(),
+			"message":   fmt.Sprintf("unable to encode log line %q: %s", message, err),
+		}) // not tested
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(encoded, '\n'))
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = New(os.Stderr, LevelInfo)
+)
+
+// SetDefault replaces the logger used by the package-level functions, which
+// until then write to stderr at info level.
+func SetDefault(logger *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = logger
+}
+
+// Default returns the logger used by the package-level functions.
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a context carrying the logger, for a request whose
+// lines go somewhere besides the default logger's.
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or else the default
+// logger.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return logger
+	}
+	return Default()
+}
+
+func Debug(message string, fields ...Fields) {
+	Default().log(LevelDebug, message, fields)
+}
+
+func Info(message string, fields ...Fields) {
+	Default().log(LevelInfo, message, fields)
+}
+
+func Warn(message string, fields ...Fields) {
+	Default().log(LevelWarn, message, fields)
+}
+
+func Error(message string, fields ...Fields) {
+	Default().log(LevelError, message, fields)
+}
+
+// Fatal logs at error level and exits with status 1.
+func Fatal(message string, fields ...Fields) {
+	Default().log(LevelError, message, fields)
+	os.Exit(1)
+}
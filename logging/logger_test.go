@@ -0,0 +1,136 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logger", func() {
+	var (
+		out    *bytes.Buffer
+		logger *logging.Logger
+	)
+
+	lines := func() []map[string]interface{} {
+		var parsed []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var fields map[string]interface{}
+			Expect(json.Unmarshal([]byte(line), &fields)).To(Succeed())
+			parsed = append(parsed, fields)
+		}
+		return parsed
+	}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		logger = logging.New(out, logging.LevelInfo)
+	})
+
+	It("writes one JSON object per line, with a timestamp, level and message", func() {
+		logger.Info("some message")
+		logger.Error("another message")
+
+		Expect(lines()).To(HaveLen(2))
+		Expect(lines()[0]).To(HaveKeyWithValue("level", "info"))
+		Expect(lines()[0]).To(HaveKeyWithValue("message", "some message"))
+		Expect(lines()[1]).To(HaveKeyWithValue("level", "error"))
+
+		timestamp, err := time.Parse(time.RFC3339Nano, lines()[0]["timestamp"].(string))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(timestamp).To(BeTemporally("~", time.Now(), time.Minute))
+	})
+
+	It("skips lines below its level", func() {
+		logger.Debug("some debug message")
+		logger.Warn("some warning")
+
+		Expect(lines()).To(HaveLen(1))
+		Expect(lines()[0]).To(HaveKeyWithValue("message", "some warning"))
+	})
+
+	It("adds the fields of derived loggers and of each line", func() {
+		handleLogger := logger.With(logging.Fields{"handle": "some-handle", "action": "up"})
+		handleLogger.With(logging.Fields{"network": "some-net"}).Info("some message", logging.Fields{
+			"plugin":   "some-plugin",
+			"duration": 1500 * time.Millisecond,
+			"error":    errors.New("some error"),
+		})
+		logger.Info("unrelated message")
+
+		Expect(lines()[0]).To(Equal(map[string]interface{}{
+			"timestamp": lines()[0]["timestamp"],
+			"level":     "info",
+			"message":   "some message",
+			"handle":    "some-handle",
+			"action":    "up",
+			"network":   "some-net",
+			"plugin":    "some-plugin",
+			"duration":  1.5,
+			"error":     "some error",
+		}))
+		Expect(lines()[1]).NotTo(HaveKey("handle"))
+	})
+
+	It("writes the lines of a teed logger to both writers", func() {
+		teeOut := &bytes.Buffer{}
+		logger.With(logging.Fields{"handle": "some-handle"}).Tee(teeOut).Info("some message")
+
+		Expect(lines()).To(HaveLen(1))
+		Expect(lines()[0]).To(HaveKeyWithValue("handle", "some-handle"))
+		Expect(teeOut.String()).To(Equal(out.String()))
+	})
+
+	Describe("ParseLevel", func() {
+		It("parses levels by name", func() {
+			Expect(logging.ParseLevel("debug")).To(Equal(logging.LevelDebug))
+			Expect(logging.ParseLevel("warn")).To(Equal(logging.LevelWarn))
+		})
+
+		Context("when the level is unknown", func() {
+			It("returns an error", func() {
+				_, err := logging.ParseLevel("banana")
+				Expect(err).To(MatchError(`unknown log level "banana": must be debug, info, warn, error`))
+			})
+		})
+	})
+
+	Describe("the default logger", func() {
+		var previous *logging.Logger
+
+		BeforeEach(func() {
+			previous = logging.Default()
+		})
+
+		AfterEach(func() {
+			logging.SetDefault(previous)
+		})
+
+		It("is replaced by SetDefault", func() {
+			logging.SetDefault(logger.With(logging.Fields{"handle": "some-handle"}))
+			logging.Warn("some warning")
+
+			Expect(lines()).To(HaveLen(1))
+			Expect(lines()[0]).To(HaveKeyWithValue("handle", "some-handle"))
+		})
+
+		It("is used for contexts that carry no logger", func() {
+			logging.SetDefault(logger)
+			Expect(logging.FromContext(context.Background())).To(BeIdenticalTo(logger))
+
+			requestLogger := logger.With(logging.Fields{"handle": "some-handle"})
+			ctx := logging.ContextWithLogger(context.Background(), requestLogger)
+			Expect(logging.FromContext(ctx)).To(BeIdenticalTo(requestLogger))
+		})
+	})
+})
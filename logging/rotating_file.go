@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxBackups is how many rotated files are kept of each log, unless
+// configured otherwise.
+const DefaultMaxBackups = 3
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "10MB", counting in powers of 1024.
+func ParseSize(size string) (int64, error) {
+	for _, unit := range sizeUnits {
+		if !strings.HasSuffix(size, unit.suffix) {
+			continue
+		}
+
+		count, err := strconv.ParseInt(strings.TrimSuffix(size, unit.suffix), 10, 64)
+		if err != nil || count <= 0 {
+			break
+		}
+		return count * unit.bytes, nil
+	}
+
+	return 0, fmt.Errorf("invalid size %q: must be a positive number of B, KB, MB or GB", size)
+}
+
+// RotatingFile appends to a log file until a write would take it past
+// MaxSize, then renames it to <path>.1, shifting older backups up to
+// <path>.<MaxBackups> and deleting any beyond.  A MaxSize of zero never
+// rotates.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err // not tested
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize {
+		err := f.rotate()
+		if err != nil {
+			return 0, fmt.Errorf("rotating %s: %s", f.Path, err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate must be called with the lock held.
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		return err // not tested
+	}
+
+	err = os.Remove(backupPath(f.Path, f.MaxBackups))
+	if err != nil && !os.IsNotExist(err) {
+		return err // not tested
+	}
+
+	for n := f.MaxBackups - 1; n >= 0; n-- {
+		err = os.Rename(backupPath(f.Path, n), backupPath(f.Path, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err // not tested
+		}
+	}
+
+	return f.open()
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// backupPath is the path of the nth backup, where the 0th is the log itself.
+func backupPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.%d", path, n)
+}
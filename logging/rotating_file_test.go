@@ -0,0 +1,92 @@
+package logging_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RotatingFile", func() {
+	var (
+		logDir  string
+		logPath string
+	)
+
+	BeforeEach(func() {
+		var err error
+		logDir, err = ioutil.TempDir("", "rotating-file-")
+		Expect(err).NotTo(HaveOccurred())
+		logPath = filepath.Join(logDir, "some-handle.log")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(logDir)).To(Succeed())
+	})
+
+	It("appends to an existing log", func() {
+		Expect(ioutil.WriteFile(logPath, []byte("some existing logs\n"), 0644)).To(Succeed())
+
+		file, err := logging.OpenRotatingFile(logPath, 0, 1)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.Write([]byte("some new logs\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		Expect(ioutil.ReadFile(logPath)).To(Equal([]byte("some existing logs\nsome new logs\n")))
+	})
+
+	It("rotates before a write would exceed the max size, keeping the max backups", func() {
+		Expect(ioutil.WriteFile(logPath, []byte("0123456789"), 0644)).To(Succeed())
+
+		file, err := logging.OpenRotatingFile(logPath, 15, 2)
+		Expect(err).NotTo(HaveOccurred())
+		for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+			_, err = file.Write([]byte(line))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(file.Close()).To(Succeed())
+
+		Expect(ioutil.ReadFile(logPath)).To(Equal([]byte("third\nfourth\n")))
+		Expect(ioutil.ReadFile(logPath + ".1")).To(Equal([]byte("first\nsecond\n")))
+		Expect(ioutil.ReadFile(logPath + ".2")).To(Equal([]byte("0123456789")))
+		Expect(logPath + ".3").NotTo(BeAnExistingFile())
+	})
+
+	It("writes lines larger than the max size to a file of their own", func() {
+		file, err := logging.OpenRotatingFile(logPath, 5, 1)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.Write([]byte("some long line\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		Expect(ioutil.ReadFile(logPath)).To(Equal([]byte("some long line\n")))
+		Expect(logPath + ".1").NotTo(BeAnExistingFile())
+	})
+
+	Context("when the log cannot be created", func() {
+		It("returns an error", func() {
+			_, err := logging.OpenRotatingFile(filepath.Join(logDir, "missing", "some-handle.log"), 0, 1)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("ParseSize", func() {
+		It("parses sizes in powers of 1024", func() {
+			Expect(logging.ParseSize("512B")).To(Equal(int64(512)))
+			Expect(logging.ParseSize("2KB")).To(Equal(int64(2048)))
+			Expect(logging.ParseSize("10MB")).To(Equal(int64(10 << 20)))
+			Expect(logging.ParseSize("1GB")).To(Equal(int64(1 << 30)))
+		})
+
+		It("rejects sizes without a unit, or that are not positive", func() {
+			for _, size := range []string{"10", "0MB", "-1KB", "MB", "1.5MB"} {
+				_, err := logging.ParseSize(size)
+				Expect(err).To(MatchError(`invalid size "` + size + `": must be a positive number of B, KB, MB or GB`))
+			}
+		})
+	})
+})
@@ -1,33 +1,136 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/daemon"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
 )
 
 type Config struct {
-	CniPluginDir string `json:"cni_plugin_dir"`
-	CniConfigDir string `json:"cni_config_dir"`
-	BindMountDir string `json:"bind_mount_dir"`
-	LogDir       string `json:"log_dir"`
+	// CniPluginDir is a directory, or a list of directories searched in
+	// order, for plugins.
+	CniPluginDir PathList `json:"cni_plugin_dir"`
+
+	CniConfigDir  string `json:"cni_config_dir"`
+	BindMountDir  string `json:"bind_mount_dir"`
+	LogDir        string `json:"log_dir"`
+	SocketPath    string `json:"socket_path"`
+	LockTimeout   string `json:"lock_timeout"`
+	PluginTimeout string `json:"plugin_timeout"`
+	LogLevel      string `json:"log_level"`
+
+	// StateDir records each container's networks, by default in the hidden
+	// .state directory of bind_mount_dir.
+	StateDir string `json:"state_dir"`
+
+	// SkipInvalidConfigs leaves out, and logs, any network config that fails
+	// to load, rather than failing every container.
+	SkipInvalidConfigs bool `json:"skip_invalid_configs"`
+
+	// PluginChecksums, such as {"bridge": "<sha256>"}, allows only the
+	// listed plugins to run, and verifies their binaries before each exec.
+	PluginChecksums map[string]string `json:"plugin_checksums"`
+
+	// CniConfigRecursive loads network configs from the subdirectories of
+	// cni_config_dir too.  Otherwise they are ignored.
+	CniConfigRecursive bool `json:"cni_config_recursive"`
+
+	// LogMaxSize, such as "10MB", rotates each log file once reached,
+	// keeping LogMaxBackups rotated files, 3 by default.
+	LogMaxSize    string `json:"log_max_size"`
+	LogMaxBackups int    `json:"log_max_backups"`
+
+	// LogRetention is how long a container's logs are kept after its down,
+	// before they are deleted, or compressed into the archive directory if
+	// LogArchive is set.  LogMaxTotalSize caps the log directory as a whole, by
+	// deleting archives, backups and the logs of torn down containers.
+	LogRetention    string `json:"log_retention"`
+	LogArchive      bool   `json:"log_archive"`
+	LogMaxTotalSize string `json:"log_max_total_size"`
+
+	// TraceEndpoint, an OTLP/HTTP collector such as "http://127.0.0.1:4318",
+	// or TraceFile, which OTLP/JSON is appended to, enables tracing.
+	TraceEndpoint string `json:"trace_endpoint"`
+	TraceFile     string `json:"trace_file"`
+}
+
+// PathList is a single path, or a list of paths searched in order.
+type PathList []string
+
+func (l *PathList) UnmarshalJSON(data []byte) error {
+	var path string
+	if json.Unmarshal(data, &path) == nil {
+		*l = nil
+		if path != "" {
+			*l = PathList{path}
+		}
+		return nil
+	}
+
+	var paths []string
+	err := json.Unmarshal(data, &paths)
+	if err != nil {
+		return fmt.Errorf("must be a path or a list of paths")
+	}
+	*l = paths
+	return nil
+}
+
+// TraceparentProperty lets Garden pass its trace context as a network
+// property, as an alternative to the traceparent flag.
+const TraceparentProperty = "traceparent"
+
+type hookManager interface {
+	Up(ctx context.Context, pid int, containerHandle, gardenNetworkSpec, networkSpec string) (*controller.UpResult, error)
+	Down(ctx context.Context, containerHandle, gardenNetworkSpec, networkSpec string) error
+	Check(ctx context.Context, containerHandle string) (map[string]controller.CheckResult, error)
 }
 
 var (
 	action            string
 	handle            string
 	config            Config
+	lockTimeout       time.Duration
+	pluginTimeout     time.Duration
+	logLevel          logging.Level
+	logMaxSize        int64
+	logRetention      time.Duration
+	logMaxTotalSize   int64
+	logName           string
+	traceparent       string
+	actionSpan        *tracing.Span
+	gardenNetworkSpec string
 	encodedProperties string
+	liveHandles       string
+	dryRun            bool
+	minAge            time.Duration
+	format            string
 )
 
-func setupLogging(logDir, handle string) error {
+// setupLogging logs at the configured level to stderr and to a file per
+// handle, or per action for actions that serve every container.  Every line
+// carries the given fields.
+func setupLogging(logDir, logName string, fields logging.Fields) error {
 	if logDir == "" {
 		return nil
 	}
@@ -36,13 +139,13 @@ func setupLogging(logDir, handle string) error {
 		return fmt.Errorf("unable to create log dir %q: %s", logDir, err)
 	}
 
-	logFilePath := filepath.Join(logDir, handle+".log")
-	logFile, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
+	logFilePath := filepath.Join(logDir, logName+".log")
+	logFile, err := logging.OpenRotatingFile(logFilePath, logMaxSize, config.LogMaxBackups)
 	if err != nil {
 		return fmt.Errorf("unable to create log file %q: %s", logFilePath, err)
 	}
-	log.SetOutput(io.MultiWriter(os.Stderr, logFile))
-	log.Printf("started logging to %s\n", logFilePath)
+	logging.SetDefault(logging.New(io.MultiWriter(os.Stderr, logFile), logLevel).With(fields))
+	logging.Info(fmt.Sprintf("started logging to %s", logFilePath))
 	return nil
 }
 
@@ -61,10 +164,16 @@ func parseConfig(configFilePath string) error {
 		return fmt.Errorf("missing required config 'log_dir'")
 	}
 
-	if config.CniPluginDir == "" {
+	if len(config.CniPluginDir) == 0 {
 		return fmt.Errorf("missing required config 'cni_plugin_dir'")
 	}
 
+	for _, pluginDir := range config.CniPluginDir {
+		if pluginDir == "" {
+			return fmt.Errorf("invalid config 'cni_plugin_dir': paths must not be empty")
+		}
+	}
+
 	if config.CniConfigDir == "" {
 		return fmt.Errorf("missing required config 'cni_config_dir'")
 	}
@@ -73,11 +182,122 @@ func parseConfig(configFilePath string) error {
 		return fmt.Errorf("missing required config 'bind_mount_dir'")
 	}
 
+	if config.StateDir == "" {
+		config.StateDir = filepath.Join(config.BindMountDir, ".state")
+	}
+
+	lockTimeout = controller.DefaultLockTimeout
+	if config.LockTimeout != "" {
+		lockTimeout, err = time.ParseDuration(config.LockTimeout)
+		if err != nil || lockTimeout <= 0 {
+			return fmt.Errorf("invalid config 'lock_timeout': must be a positive duration such as \"30s\"")
+		}
+	}
+
+	if config.PluginTimeout != "" {
+		pluginTimeout, err = time.ParseDuration(config.PluginTimeout)
+		if err != nil || pluginTimeout <= 0 {
+			return fmt.Errorf("invalid config 'plugin_timeout': must be a positive duration such as \"30s\"")
+		}
+	}
+
+	logLevel = logging.LevelInfo
+	if config.LogLevel != "" {
+		logLevel, err = logging.ParseLevel(config.LogLevel)
+		if err != nil {
+			return fmt.Errorf("invalid config 'log_level': %s", err)
+		}
+	}
+
+	if config.LogMaxSize != "" {
+		logMaxSize, err = logging.ParseSize(config.LogMaxSize)
+		if err != nil {
+			return fmt.Errorf("invalid config 'log_max_size': %s", err)
+		}
+	}
+
+	if config.LogMaxBackups < 0 {
+		return fmt.Errorf("invalid config 'log_max_backups': must not be negative")
+	}
+	if config.LogMaxBackups == 0 {
+		config.LogMaxBackups = logging.DefaultMaxBackups
+	}
+
+	if config.LogRetention != "" {
+		logRetention, err = time.ParseDuration(config.LogRetention)
+		if err != nil || logRetention <= 0 {
+			return fmt.Errorf("invalid config 'log_retention': must be a positive duration such as \"24h\"")
+		}
+	}
+
+	if config.LogMaxTotalSize != "" {
+		logMaxTotalSize, err = logging.ParseSize(config.LogMaxTotalSize)
+		if err != nil {
+			return fmt.Errorf("invalid config 'log_max_total_size': %s", err)
+		}
+	}
+
+	for plugin, checksum := range config.PluginChecksums {
+		decoded, err := hex.DecodeString(checksum)
+		if err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("invalid config 'plugin_checksums': checksum of %s must be a hex SHA-256", plugin)
+		}
+	}
+
+	if config.TraceEndpoint != "" && config.TraceFile != "" {
+		return fmt.Errorf("invalid config: set only one of 'trace_endpoint' and 'trace_file'")
+	}
+
+	if config.TraceEndpoint != "" {
+		endpoint, err := url.Parse(config.TraceEndpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("invalid config 'trace_endpoint': must be an http or https URL such as \"http://127.0.0.1:4318\"")
+		}
+	}
+
 	return nil
 }
 
+func setupTracing() {
+	switch {
+	case config.TraceEndpoint != "":
+		tracing.SetDefault(tracing.NewTracer(&tracing.HTTPExporter{Endpoint: config.TraceEndpoint}))
+	case config.TraceFile != "":
+		tracing.SetDefault(tracing.NewTracer(&tracing.FileExporter{Path: config.TraceFile}))
+	}
+}
+
+// startActionSpan starts the span of the action, under Garden's span if it
+// passed one through the traceparent flag or property.  An invalid trace
+// context only starts a new trace, rather than failing the action.
+func startActionSpan() context.Context {
+	if traceparent == "" && strings.TrimSpace(encodedProperties) != "" {
+		var properties map[string]interface{}
+		if json.Unmarshal([]byte(encodedProperties), &properties) == nil {
+			traceparent, _ = properties[TraceparentProperty].(string)
+		}
+	}
+
+	var parent tracing.SpanContext
+	if traceparent != "" {
+		var err error
+		parent, err = tracing.ParseTraceparent(traceparent)
+		if err != nil {
+			logging.Warn("ignoring invalid traceparent", logging.Fields{"error": err})
+		}
+	}
+
+	attributes := tracing.Attributes{"action": action}
+	if handle != "" {
+		attributes["handle"] = handle
+	}
+	var ctx context.Context
+	ctx, actionSpan = tracing.StartRemote(context.Background(), parent, action, attributes)
+	return ctx
+}
+
 func parseArgs(allArgs []string) error {
-	var gardenNetworkSpec, configFilePath string
+	var configFilePath string
 
 	flagSet := flag.NewFlagSet("", flag.ContinueOnError)
 
@@ -86,86 +306,427 @@ func parseArgs(allArgs []string) error {
 	flagSet.StringVar(&gardenNetworkSpec, "network", "", "")
 	flagSet.StringVar(&encodedProperties, "properties", "", "")
 	flagSet.StringVar(&configFilePath, "configFile", "", "")
+	flagSet.StringVar(&liveHandles, "liveHandles", "", "")
+	flagSet.BoolVar(&dryRun, "dryRun", false, "")
+	flagSet.DurationVar(&minAge, "minAge", 5*time.Minute, "")
+	flagSet.StringVar(&format, "format", "table", "")
+	flagSet.StringVar(&traceparent, "traceparent", "", "")
 
 	err := flagSet.Parse(allArgs[1:])
 	if err != nil {
-		return err
+		return &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
 	}
 	if len(flagSet.Args()) > 0 {
-		return fmt.Errorf("unexpected extra args: %+v", flagSet.Args())
+		err = fmt.Errorf("unexpected extra args: %+v", flagSet.Args())
+		return &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
 	}
 
-	if handle == "" {
-		return fmt.Errorf("missing required flag 'handle'")
+	// the daemon and its status serve every container, so have no handle,
+	// nor do list, validate-config, or gc, which looks for containers that
+	// are not live
+	daemonAction := action == "daemon" || action == "status"
+	globalAction := daemonAction || action == "gc" || action == "list" || action == "validate-config"
+
+	if handle == "" && !globalAction {
+		err = fmt.Errorf("missing required flag 'handle'")
+		return &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
 	}
 
 	if configFilePath == "" {
-		return fmt.Errorf("missing required flag 'configFile'")
+		err = fmt.Errorf("missing required flag 'configFile'")
+		return &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
 	}
 
 	if err = parseConfig(configFilePath); err != nil {
-		return err
+		return &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
+	}
+
+	logName = handle
+	if daemonAction {
+		logName = "daemon"
+	} else if globalAction {
+		logName = action
+	}
+
+	fields := logging.Fields{"action": action}
+	if handle != "" {
+		fields["handle"] = handle
 	}
 
-	if err = setupLogging(config.LogDir, handle); err != nil {
+	if err = setupLogging(config.LogDir, logName, fields); err != nil {
 		return err
 	}
 
 	if action == "" {
-		return fmt.Errorf("missing required flag 'action'")
+		err = fmt.Errorf("missing required flag 'action'")
+		return &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
+	}
+
+	if daemonAction && config.SocketPath == "" {
+		err = fmt.Errorf("missing required config 'socket_path'")
+		return &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
+	}
+
+	if format != "table" && format != "json" {
+		err = fmt.Errorf("invalid flag 'format': must be table or json")
+		return &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
 	}
 
 	return nil
 }
 
-func main() {
-	if len(os.Args) == 1 || os.Args[1] == "-h" || os.Args[1] == "--help" {
-		log.Fatalf("this is a OCI prestart/poststop hook.  see https://github.com/opencontainers/specs/blob/master/runtime-config.md")
+// readLiveHandles takes the live handles from the liveHandles flag, or else
+// from a JSON list on stdin.
+func readLiveHandles(stdin io.Reader) ([]string, error) {
+	if liveHandles != "" {
+		handles := controller.ParseHandleList(liveHandles)
+		if len(handles) == 0 {
+			err := fmt.Errorf("--liveHandles lists no handles: pass an empty JSON list on stdin if no container is live")
+			return nil, &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
+		}
+		return handles, nil
 	}
 
-	inputBytes, err := ioutil.ReadAll(os.Stdin)
+	inputBytes, err := ioutil.ReadAll(stdin)
 	if err != nil {
-		log.Fatalf("unable to read stdin: %s", err)
+		return nil, fmt.Errorf("unable to read stdin: %s", err) // not tested
 	}
 
-	var containerState struct {
-		Pid int
+	if len(strings.TrimSpace(string(inputBytes))) == 0 {
+		err = fmt.Errorf("gc requires the live handles, via --liveHandles or a JSON list on stdin")
+		return nil, &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
 	}
-	err = json.Unmarshal(inputBytes, &containerState)
+
+	var handles []string
+	err = json.Unmarshal(inputBytes, &handles)
 	if err != nil {
-		log.Fatalf("input is not valid json: %s: %q", err, string(inputBytes))
+		err = fmt.Errorf("live handles are not a JSON list: %s: %q", err, string(inputBytes))
+		return nil, &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}
 	}
 
-	err = parseArgs(os.Args)
+	return handles, nil
+}
+
+// exitCodes tell Garden what kind of failure it is reacting to.
+var exitCodes = map[controller.ErrorKind]int{
+	controller.ErrorKindInternal: 1,
+	controller.ErrorKindConfig:   2,
+	controller.ErrorKindMount:    3,
+	controller.ErrorKindPlugin:   4,
+	controller.ErrorKindTimeout:  5,
+}
+
+// fail logs the error, writes its report as JSON on the last line of stderr,
+// and exits with the code for its kind.
+func fail(prefix string, err error) {
+	err = fmt.Errorf("%s: %w", prefix, err)
+	logging.Error(err.Error(), logging.Fields{"kind": controller.KindOf(err)})
+	actionSpan.Finish(err)
+
+	encodeErr := json.NewEncoder(os.Stderr).Encode(controller.NewErrorReport(err))
+	if encodeErr != nil {
+		logging.Error("writing error report to stderr", logging.Fields{"error": encodeErr}) // not tested
+	}
+
+	os.Exit(exitCodes[controller.KindOf(err)])
+}
+
+// globalLogNames are the logs of actions that serve every container, which
+// are never finished.
+var globalLogNames = map[string]bool{"daemon": true, "gc": true, "list": true, "validate-config": true}
+
+// sweepLogs applies the log retention and the log directory's size cap.  A
+// failure is only logged, as it is no reason to fail the action.
+func sweepLogs(stateStore *controller.StateStore) {
+	if logRetention == 0 && logMaxTotalSize == 0 {
+		return
+	}
+
+	janitor := &logging.Janitor{
+		Dir:          config.LogDir,
+		Retention:    logRetention,
+		Archive:      config.LogArchive,
+		MaxTotalSize: logMaxTotalSize,
+		Active:       logName,
+		Finished: func(name string) (bool, error) {
+			if globalLogNames[name] {
+				return false, nil
+			}
+
+			state, err := stateStore.Load(name)
+			if err != nil || state != nil {
+				return false, err
+			}
+
+			_, err = os.Stat(filepath.Join(config.BindMountDir, name))
+			if os.IsNotExist(err) {
+				return true, nil
+			}
+			return false, err
+		},
+	}
+
+	err := janitor.Sweep(time.Now())
+	if err != nil {
+		logging.Warn("sweeping logs failed", logging.Fields{"error": err})
+	}
+}
+
+// openHandleLog opens the log a hook for the container writes to, so that the
+// daemon's lines for the container can be found alongside the hook's.
+func openHandleLog(handle string) (io.WriteCloser, error) {
+	return logging.OpenRotatingFile(filepath.Join(config.LogDir, handle+".log"), logMaxSize, config.LogMaxBackups)
+}
+
+// daemonDrainTimeout bounds how long a stopping daemon waits for the requests
+// it is handling, so that a plugin that succeeded has its state recorded.
+const daemonDrainTimeout = time.Minute
+
+func runDaemon(server *daemon.Server) error {
+	httpServer := &http.Server{Handler: server.Handler()}
+
+	// handle signals before the socket appears, so that a client who sees it
+	// can rely on SIGTERM shutting down cleanly
+	drained := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer close(drained)
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				server.Reload()
+				continue
+			}
+
+			logging.Info(fmt.Sprintf("received %s, shutting down", sig))
+			ctx, cancel := context.WithTimeout(context.Background(), daemonDrainTimeout)
+			err := httpServer.Shutdown(ctx)
+			cancel()
+			if err != nil {
+				logging.Warn("requests still running after the drain timeout, abandoning them", logging.Fields{"error": err})
+				httpServer.Close()
+			}
+			return
+		}
+	}()
+
+	listener, err := daemon.Listen(config.SocketPath)
+	if err != nil {
+		return err
+	}
+
+	// the socket is removed once the running requests are done, rather than
+	// as soon as new ones are refused
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	logging.Info(fmt.Sprintf("daemon listening on %s", config.SocketPath))
+	err = httpServer.Serve(listener)
+	if err != http.ErrServerClosed {
+		return err
+	}
+
+	<-drained
+	err = os.Remove(config.SocketPath)
+	if err != nil {
+		return fmt.Errorf("removing socket %s: %s", config.SocketPath, err) // not tested
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) == 1 || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		logging.Fatal("this is a OCI prestart/poststop hook.  see https://github.com/opencontainers/specs/blob/master/runtime-config.md")
+	}
+
+	err := parseArgs(os.Args)
 	if err != nil {
-		log.Fatalf("arg parsing error: %s", err)
+		fail("arg parsing error", err)
 	}
 
+	// spread out the retries of adapters started at the same moment
+	rand.Seed(time.Now().UnixNano())
+
+	setupTracing()
+
 	cniController := &controller.CNIController{
-		PluginDir: config.CniPluginDir,
-		ConfigDir: config.CniConfigDir,
+		PluginDirs:         config.CniPluginDir,
+		ConfigDir:          config.CniConfigDir,
+		PluginChecksums:    config.PluginChecksums,
+		PluginTimeout:      pluginTimeout,
+		SkipInvalidConfigs: config.SkipInvalidConfigs,
+		RecurseConfigDir:   config.CniConfigRecursive,
 	}
 
 	mounter := &controller.Mounter{}
 
+	stateStore := &controller.StateStore{
+		Dir: config.StateDir,
+	}
+
+	locker := &controller.Locker{
+		Dir:     filepath.Join(config.BindMountDir, ".locks"),
+		Timeout: lockTimeout,
+	}
+
 	manager := &controller.Manager{
 		CNIController: cniController,
 		Mounter:       mounter,
+		StateStore:    stateStore,
+		Locker:        locker,
 		BindMountRoot: config.BindMountDir,
 	}
 
+	switch action {
+	case "daemon":
+		server := &daemon.Server{
+			Manager:        manager,
+			NetworkConfigs: cniController,
+		}
+		if config.LogDir != "" {
+			server.HandleLog = openHandleLog
+		}
+		err = runDaemon(server)
+		if err != nil {
+			fail("daemon failed", err)
+		}
+		return
+	case "status":
+		status, err := (&daemon.Client{SocketPath: config.SocketPath}).Status()
+		if err != nil {
+			fail("status failed", err)
+		}
+
+		err = json.NewEncoder(os.Stdout).Encode(status)
+		if err != nil {
+			logging.Fatal("writing status to stdout", logging.Fields{"error": err})
+		}
+		return
+	case "gc":
+		ctx := startActionSpan()
+		handles, err := readLiveHandles(os.Stdin)
+		if err != nil {
+			fail("gc failed", err)
+		}
+
+		report, err := manager.GC(ctx, handles, minAge, dryRun)
+		if report != nil {
+			encodeErr := json.NewEncoder(os.Stdout).Encode(report)
+			if encodeErr != nil {
+				logging.Fatal("writing report to stdout", logging.Fields{"error": encodeErr})
+			}
+		}
+		if err != nil {
+			fail("gc failed", err)
+		}
+
+		actionSpan.Finish(nil)
+		sweepLogs(stateStore)
+		return
+	case "list":
+		infos, err := manager.List()
+		if err != nil {
+			fail("list failed", err)
+		}
+
+		err = writeOutput(os.Stdout, format, infos, func(w io.Writer) error {
+			return writeContainers(w, infos)
+		})
+		if err != nil {
+			logging.Fatal("writing list to stdout", logging.Fields{"error": err})
+		}
+		return
+	case "inspect":
+		info, err := manager.Inspect(handle)
+		if err != nil {
+			fail("inspect failed", err)
+		}
+
+		err = writeOutput(os.Stdout, format, info, func(w io.Writer) error {
+			return writeContainers(w, []controller.ContainerInfo{*info})
+		})
+		if err != nil {
+			logging.Fatal("writing info to stdout", logging.Fields{"error": err})
+		}
+		return
+	case "validate-config":
+		report, err := cniController.Validate()
+		if err != nil {
+			fail("validate-config failed", &controller.KindError{Kind: controller.ErrorKindConfig, Err: err})
+		}
+
+		err = writeOutput(os.Stdout, format, report, func(w io.Writer) error {
+			return writeConfigReport(w, report)
+		})
+		if err != nil {
+			logging.Fatal("writing report to stdout", logging.Fields{"error": err})
+		}
+
+		if invalid := report.Invalid(); len(invalid) > 0 {
+			err = fmt.Errorf("%d invalid configs in %s", len(invalid), config.CniConfigDir)
+			fail("validate-config failed", &controller.KindError{Kind: controller.ErrorKindConfig, Err: err})
+		}
+		return
+	}
+
+	inputBytes, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fail("unable to read stdin", &controller.KindError{Kind: controller.ErrorKindConfig, Err: err}) // not tested
+	}
+
+	var containerState struct {
+		Pid int
+	}
+	err = json.Unmarshal(inputBytes, &containerState)
+	if err != nil {
+		err = fmt.Errorf("%s: %q", err, string(inputBytes))
+		fail("input is not valid json", &controller.KindError{Kind: controller.ErrorKindConfig, Err: err})
+	}
+
+	var hooks hookManager = manager
+	if config.SocketPath != "" {
+		hooks = &daemon.Client{SocketPath: config.SocketPath, Fallback: manager}
+	}
+
+	// a hook that is told to stop kills the plugins it is running, rather
+	// than leaving them behind
+	started := time.Now()
+	ctx, stop := signal.NotifyContext(startActionSpan(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 	switch action {
 	case "up":
-		err = manager.Up(containerState.Pid, handle, encodedProperties)
+		result, err := hooks.Up(ctx, containerState.Pid, handle, gardenNetworkSpec, encodedProperties)
+		if err != nil {
+			fail("up failed", err)
+		}
+
+		err = json.NewEncoder(os.Stdout).Encode(result)
 		if err != nil {
-			log.Fatalf("up failed: %s", err)
+			logging.Fatal("writing result to stdout", logging.Fields{"error": err})
 		}
 	case "down":
-		err = manager.Down(handle, encodedProperties)
+		err = hooks.Down(ctx, handle, gardenNetworkSpec, encodedProperties)
 		if err != nil {
-			log.Fatalf("down failed: %s", err)
+			fail("down failed", err)
+		}
+
+		sweepLogs(stateStore)
+	case "check":
+		report, err := hooks.Check(ctx, handle)
+		if report != nil {
+			encodeErr := json.NewEncoder(os.Stdout).Encode(report)
+			if encodeErr != nil {
+				logging.Fatal("writing report to stdout", logging.Fields{"error": encodeErr})
+			}
+		}
+		if err != nil {
+			fail("check failed", err)
 		}
 	default:
-		log.Fatalf("action: %s is unrecognized", action)
+		err = fmt.Errorf("action: %s is unrecognized", action)
+		fail("invalid action", &controller.KindError{Kind: controller.ErrorKindConfig, Err: err})
 	}
+
+	actionSpan.Finish(nil)
+
+	logging.Info(fmt.Sprintf("%s complete", action), logging.Fields{"duration": time.Since(started)})
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/controller"
+)
+
+// writeContainers writes one table row per network of each container, with
+// the container's columns repeated so that rows can be grepped on their own.
+// A container that could not be inspected has its error as its status.
+func writeContainers(w io.Writer, infos []controller.ContainerInfo) error {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "HANDLE\tSTATUS\tMOUNTED\tNETNS\tNETWORK\tINTERFACE\tIPS")
+
+	for _, info := range infos {
+		status := info.Status
+		if info.Error != "" {
+			status = "error: " + info.Error
+		} else if status == "" {
+			status = "-"
+		}
+
+		mounted := "no"
+		if info.Mounted {
+			mounted = "yes"
+		}
+
+		networks := info.Networks
+		if len(networks) == 0 {
+			networks = []controller.NetworkInfo{{Network: "-", Interface: "-"}}
+		}
+
+		for _, network := range networks {
+			addresses := []string{}
+			for _, ip := range network.IPs {
+				addresses = append(addresses, ip.Address)
+			}
+			ips := strings.Join(addresses, ",")
+			if ips == "" {
+				ips = "-"
+			}
+
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				info.Handle, status, mounted, info.NetNSPath, network.Network, network.Interface, ips)
+		}
+	}
+
+	return table.Flush()
+}
+
+// writeConfigReport writes one table row per problem of each config, or a
+// single row for a config without problems.
+func writeConfigReport(w io.Writer, report *controller.ConfigReport) error {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "PATH\tNETWORK\tPRIORITY\tINTERFACE\tSTATUS")
+
+	for _, status := range report.Configs {
+		network := status.Network
+		if network == "" {
+			network = "-"
+		}
+
+		ifName := status.Interface
+		if ifName == "" {
+			ifName = "-"
+		}
+
+		if len(status.Problems) == 0 {
+			fmt.Fprintf(table, "%s\t%s\t%d\t%s\tok\n", status.Path, network, status.Priority, ifName)
+		}
+		for _, problem := range status.Problems {
+			fmt.Fprintf(table, "%s\t%s\t%d\t%s\t%s\n", status.Path, network, status.Priority, ifName, problem)
+		}
+	}
+
+	return table.Flush()
+}
+
+func writeOutput(w io.Writer, format string, value interface{}, writeTable func(io.Writer) error) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(value)
+	}
+	return writeTable(w)
+}
@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceName identifies the adapter's spans to the collector.
+const ServiceName = "guardian-cni-adapter"
+
+// The subset of OTLP/JSON, as exported to /v1/traces, that spans need.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+func encodeOTLP(spans []*Span) ([]byte, error) {
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: ServiceName}}
+	for _, span := range spans {
+		encoded := otlpSpan{
+			TraceID:           span.Context.TraceID.String(),
+			SpanID:            span.Context.SpanID.String(),
+			Name:              span.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: unixNano(span.Start),
+			EndTimeUnixNano:   unixNano(span.End),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: otlpStatusOK},
+		}
+		if span.Parent.IsValid() {
+			encoded.ParentSpanID = span.Parent.String()
+		}
+		if span.Error != "" {
+			encoded.Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, encoded)
+	}
+
+	return json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: otlpAttributes(Attributes{"service.name": ServiceName}),
+			},
+			ScopeSpans: []otlpScopeSpans{scopeSpans},
+		}},
+	})
+}
+
+func otlpAttributes(attributes Attributes) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var encoded []otlpAttribute
+	for _, key := range keys {
+		encoded = append(encoded, otlpAttribute{Key: key, Value: otlpValue{StringValue: attributes[key]}})
+	}
+	return encoded
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// FileExporter appends each export to Path as a line of OTLP/JSON, which a
+// collector's file receiver, or a person, can read.
+type FileExporter struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (e *FileExporter) Export(spans []*Span) error {
+	encoded, err := encodeOTLP(spans)
+	if err != nil {
+		return fmt.Errorf("encoding spans: %s", err) // not tested
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	file, err := os.OpenFile(e.Path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("opening trace file: %s", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(encoded, '\n'))
+	if err != nil {
+		return fmt.Errorf("writing trace file: %s", err) // not tested
+	}
+	return nil
+}
+
+// HTTPExporter posts each export to an OTLP/HTTP collector, such as
+// "http://127.0.0.1:4318", as JSON.
+type HTTPExporter struct {
+	Endpoint string
+	Timeout  time.Duration
+}
+
+// DefaultExportTimeout bounds each export, so that a missing collector does
+// not hold up a container create for long.
+const DefaultExportTimeout = 2 * time.Second
+
+func (e *HTTPExporter) Export(spans []*Span) error {
+	encoded, err := encodeOTLP(spans)
+	if err != nil {
+		return fmt.Errorf("encoding spans: %s", err) // not tested
+	}
+
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = DefaultExportTimeout
+	}
+
+	client := &http.Client{Timeout: timeout}
+	response, err := client.Post(strings.TrimSuffix(e.Endpoint, "/")+"/v1/traces", "application/json", bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("posting spans: %s", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("posting spans: collector returned %d: %s", response.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OTLP exporters", func() {
+	var spans []*tracing.Span
+
+	BeforeEach(func() {
+		recorder := &fakeExporter{}
+		tracer := tracing.NewTracer(recorder)
+		ctx, root := tracer.Start(context.Background(), "up", tracing.Attributes{"handle": "some-handle"})
+		_, add := tracer.Start(ctx, "AddNetwork", tracing.Attributes{"network": "some-net"})
+		add.Finish(errors.New("some error"))
+		root.Finish(nil)
+		spans = recorder.exports[0]
+	})
+
+	expectOTLP := func(encoded []byte) {
+		var request struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []map[string]interface{}
+				}
+				ScopeSpans []struct {
+					Spans []map[string]interface{}
+				}
+			}
+		}
+		Expect(json.Unmarshal(encoded, &request)).To(Succeed())
+		Expect(request.ResourceSpans).To(HaveLen(1))
+		Expect(request.ResourceSpans[0].Resource.Attributes).To(ConsistOf(map[string]interface{}{
+			"key": "service.name", "value": map[string]interface{}{"stringValue": "guardian-cni-adapter"},
+		}))
+
+		exported := request.ResourceSpans[0].ScopeSpans[0].Spans
+		Expect(exported).To(HaveLen(2))
+
+		add, root := exported[0], exported[1]
+		Expect(root).To(HaveKeyWithValue("name", "up"))
+		Expect(root).To(HaveKeyWithValue("traceId", spans[1].Context.TraceID.String()))
+		Expect(root).To(HaveKeyWithValue("spanId", spans[1].Context.SpanID.String()))
+		Expect(root).NotTo(HaveKey("parentSpanId"))
+		Expect(root).To(HaveKeyWithValue("status", map[string]interface{}{"code": 1.0}))
+		Expect(root).To(HaveKeyWithValue("attributes", ConsistOf(map[string]interface{}{
+			"key": "handle", "value": map[string]interface{}{"stringValue": "some-handle"},
+		})))
+
+		Expect(add).To(HaveKeyWithValue("name", "AddNetwork"))
+		Expect(add).To(HaveKeyWithValue("parentSpanId", spans[1].Context.SpanID.String()))
+		Expect(add).To(HaveKeyWithValue("status", map[string]interface{}{"code": 2.0, "message": "some error"}))
+		Expect(add).To(HaveKeyWithValue("startTimeUnixNano", MatchRegexp(`^[0-9]+$`)))
+	}
+
+	Describe("FileExporter", func() {
+		var traceDir string
+
+		BeforeEach(func() {
+			var err error
+			traceDir, err = ioutil.TempDir("", "traces-")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(traceDir)).To(Succeed())
+		})
+
+		It("appends each export as a line of OTLP/JSON", func() {
+			exporter := &tracing.FileExporter{Path: filepath.Join(traceDir, "traces.json")}
+			Expect(exporter.Export(spans)).To(Succeed())
+			Expect(exporter.Export(spans)).To(Succeed())
+
+			contents, err := ioutil.ReadFile(exporter.Path)
+			Expect(err).NotTo(HaveOccurred())
+			lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+			Expect(lines).To(HaveLen(2))
+			expectOTLP([]byte(lines[1]))
+		})
+
+		Context("when the file cannot be opened", func() {
+			It("returns an error", func() {
+				exporter := &tracing.FileExporter{Path: filepath.Join(traceDir, "missing", "traces.json")}
+				Expect(exporter.Export(spans)).To(MatchError(HavePrefix("opening trace file")))
+			})
+		})
+	})
+
+	Describe("HTTPExporter", func() {
+		var (
+			collector  *httptest.Server
+			statusCode int
+			received   []byte
+			path       string
+		)
+
+		BeforeEach(func() {
+			statusCode = http.StatusOK
+			collector = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+				received, _ = ioutil.ReadAll(r.Body)
+				w.WriteHeader(statusCode)
+				w.Write([]byte("some response"))
+			}))
+		})
+
+		AfterEach(func() {
+			collector.Close()
+		})
+
+		It("posts OTLP/JSON to the collector's traces path", func() {
+			exporter := &tracing.HTTPExporter{Endpoint: collector.URL + "/"}
+			Expect(exporter.Export(spans)).To(Succeed())
+
+			Expect(path).To(Equal("/v1/traces"))
+			expectOTLP(received)
+		})
+
+		Context("when the collector rejects the spans", func() {
+			It("returns an error", func() {
+				statusCode = http.StatusBadRequest
+				exporter := &tracing.HTTPExporter{Endpoint: collector.URL}
+				Expect(exporter.Export(spans)).To(MatchError("posting spans: collector returned 400: some response"))
+			})
+		})
+
+		Context("when the collector is unreachable", func() {
+			It("returns an error", func() {
+				exporter := &tracing.HTTPExporter{Endpoint: "http://127.0.0.1:1"}
+				Expect(exporter.Export(spans)).To(MatchError(HavePrefix("posting spans")))
+			})
+		})
+	})
+})
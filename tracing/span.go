@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+func (id SpanID) IsValid() bool  { return id != SpanID{} }
+
+// SpanContext identifies a span across processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (c SpanContext) IsValid() bool {
+	return c.TraceID.IsValid() && c.SpanID.IsValid()
+}
+
+var traceparentFormat = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// ParseTraceparent parses a W3C traceparent, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	match := traceparentFormat.FindStringSubmatch(traceparent)
+	if match == nil || match[1] == "ff" {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+
+	var spanContext SpanContext
+	hex.Decode(spanContext.TraceID[:], []byte(match[2]))
+	hex.Decode(spanContext.SpanID[:], []byte(match[3]))
+	flags, _ := hex.DecodeString(match[4])
+	spanContext.Sampled = flags[0]&1 == 1
+
+	if !spanContext.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q: ids must not be zero", traceparent)
+	}
+	return spanContext, nil
+}
+
+// Traceparent formats the context to be passed on as a W3C traceparent, or
+// is empty if the context is invalid.
+func (c SpanContext) Traceparent() string {
+	if !c.IsValid() {
+		return ""
+	}
+
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", c.TraceID, c.SpanID, flags)
+}
+
+type Attributes map[string]string
+
+// Span times an operation.  Spans are safe to use when tracing is disabled,
+// as nil.
+type Span struct {
+	Name       string
+	Context    SpanContext
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes Attributes
+	Error      string
+
+	tracer *Tracer
+	mu     sync.Mutex
+
+	// root is the outermost span of the trace in this process, which holds
+	// the spans finished under it until it is exported, under the tracer's
+	// lock.
+	root     *Span
+	pending  []*Span
+	exported bool
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// Finish ends the span, failed if err is not nil.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.End = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.mu.Unlock()
+
+	s.tracer.finish(s)
+}
+
+// SpanContext returns the context to pass on to another process.  A nil span
+// has an invalid one.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.Context
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
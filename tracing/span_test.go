@@ -0,0 +1,54 @@
+package tracing_test
+
+import (
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SpanContext", func() {
+	Describe("ParseTraceparent", func() {
+		It("parses the trace and span ids and whether the trace is sampled", func() {
+			spanContext, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spanContext.TraceID.String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(spanContext.SpanID.String()).To(Equal("00f067aa0ba902b7"))
+			Expect(spanContext.Sampled).To(BeTrue())
+
+			spanContext, err = tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spanContext.Sampled).To(BeFalse())
+		})
+
+		It("formats it back", func() {
+			spanContext, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spanContext.Traceparent()).To(Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+		})
+
+		Context("when the traceparent is malformed", func() {
+			It("returns an error", func() {
+				for _, traceparent := range []string{
+					"banana",
+					"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+					"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+					"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				} {
+					_, err := tracing.ParseTraceparent(traceparent)
+					Expect(err).To(MatchError(`invalid traceparent "` + traceparent + `"`))
+				}
+			})
+		})
+
+		Context("when an id is zero", func() {
+			It("returns an error", func() {
+				_, err := tracing.ParseTraceparent("00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+				Expect(err).To(MatchError(ContainSubstring("ids must not be zero")))
+			})
+		})
+	})
+
+	It("formats an invalid context as an empty traceparent", func() {
+		Expect(tracing.SpanContext{}.Traceparent()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,157 @@
+// Package tracing records spans for the hook actions and the plugin
+// invocations within them, and exports them as OTLP, so that a slow container
+// create can be attributed to the mount, the config loading or a plugin.
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/logging"
+)
+
+type Exporter interface {
+	Export(spans []*Span) error
+}
+
+// Tracer nests each span under the span carried by the context it is started
+// with, and exports the spans of a trace once the outermost span this process
+// started for it finishes.  Since the parent is carried per request, the
+// daemon can trace requests for different containers at once.
+type Tracer struct {
+	Exporter Exporter
+
+	mu sync.Mutex
+}
+
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{Exporter: exporter}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a context carrying the span, under which spans
+// started with the context are nested.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by the context, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts a span under the span carried by the context, or else a new
+// trace, and returns a context carrying the new span.
+func (t *Tracer) Start(ctx context.Context, name string, attributes Attributes) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent := SpanFromContext(ctx)
+	if parent == nil || parent.tracer != t {
+		return t.StartRemote(ctx, SpanContext{}, name, attributes)
+	}
+
+	span := t.start(parent.Context, name, attributes)
+	span.root = parent.root
+	return ContextWithSpan(ctx, span), span
+}
+
+// StartRemote starts a span under a span of another process, such as
+// Garden's, or a new trace if the parent is invalid, and returns a context
+// carrying the new span.
+func (t *Tracer) StartRemote(ctx context.Context, parent SpanContext, name string, attributes Attributes) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := t.start(parent, name, attributes)
+	span.root = span
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) start(parent SpanContext, name string, attributes Attributes) *Span {
+	span := &Span{
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: Attributes{},
+		tracer:     t,
+	}
+	for key, value := range attributes {
+		span.Attributes[key] = value
+	}
+
+	if parent.IsValid() {
+		span.Context = SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+		span.Parent = parent.SpanID
+	} else {
+		span.Context = SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	}
+
+	return span
+}
+
+// finish exports the span along with the spans finished under it, if it is
+// the outermost, or else holds it until the outermost finishes.  A span that
+// outlives the outermost is exported on its own.
+func (t *Tracer) finish(span *Span) {
+	if !span.Context.Sampled {
+		return
+	}
+
+	t.mu.Lock()
+	var spans []*Span
+	switch {
+	case span.root == span:
+		spans = append(span.pending, span)
+		span.pending = nil
+		span.exported = true
+	case span.root.exported:
+		spans = []*Span{span}
+	default:
+		span.root.pending = append(span.root.pending, span)
+	}
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return
+	}
+
+	err := t.Exporter.Export(spans)
+	if err != nil {
+		logging.Warn("exporting spans failed", logging.Fields{"error": err, "spans": len(spans)})
+	}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultTracer *Tracer
+)
+
+// SetDefault sets the tracer used by the package-level functions, which
+// until then record nothing.
+func SetDefault(tracer *Tracer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultTracer = tracer
+}
+
+func Default() *Tracer {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultTracer
+}
+
+func Start(ctx context.Context, name string, attributes Attributes) (context.Context, *Span) {
+	return Default().Start(ctx, name, attributes)
+}
+
+func StartRemote(ctx context.Context, parent SpanContext, name string, attributes Attributes) (context.Context, *Span) {
+	return Default().StartRemote(ctx, parent, name, attributes)
+}
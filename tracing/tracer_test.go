@@ -0,0 +1,136 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cloudfoundry-incubator/guardian-cni-adapter/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeExporter struct {
+	exports [][]*tracing.Span
+	err     error
+}
+
+func (e *fakeExporter) Export(spans []*tracing.Span) error {
+	e.exports = append(e.exports, spans)
+	return e.err
+}
+
+var _ = Describe("Tracer", func() {
+	var (
+		exporter *fakeExporter
+		tracer   *tracing.Tracer
+	)
+
+	BeforeEach(func() {
+		exporter = &fakeExporter{}
+		tracer = tracing.NewTracer(exporter)
+	})
+
+	It("nests spans under the span in the context, and exports them once the outermost finishes", func() {
+		ctx, root := tracer.Start(context.Background(), "up", tracing.Attributes{"handle": "some-handle"})
+		Expect(tracing.SpanFromContext(ctx)).To(BeIdenticalTo(root))
+		_, mount := tracer.Start(ctx, "IdempotentlyMount", nil)
+		mount.Finish(nil)
+		_, add := tracer.Start(ctx, "AddNetwork", nil)
+		add.SetAttribute("network", "some-net")
+		add.Finish(errors.New("some error"))
+		Expect(exporter.exports).To(BeEmpty())
+
+		root.Finish(nil)
+		Expect(exporter.exports).To(HaveLen(1))
+		Expect(exporter.exports[0]).To(Equal([]*tracing.Span{mount, add, root}))
+
+		Expect(root.Parent.IsValid()).To(BeFalse())
+		Expect(root.Attributes).To(Equal(tracing.Attributes{"handle": "some-handle"}))
+		Expect(mount.Context.TraceID).To(Equal(root.Context.TraceID))
+		Expect(mount.Parent).To(Equal(root.Context.SpanID))
+		Expect(add.Parent).To(Equal(root.Context.SpanID))
+		Expect(add.Attributes).To(HaveKeyWithValue("network", "some-net"))
+		Expect(add.Error).To(Equal("some error"))
+		Expect(add.End).NotTo(BeTemporally("<", add.Start))
+	})
+
+	It("keeps the traces of concurrent requests apart", func() {
+		ctxA, rootA := tracer.Start(context.Background(), "daemon up", nil)
+		ctxB, rootB := tracer.Start(context.Background(), "daemon up", nil)
+
+		_, addA := tracer.Start(ctxA, "AddNetwork", nil)
+		_, addB := tracer.Start(ctxB, "AddNetwork", nil)
+		Expect(addA.Parent).To(Equal(rootA.Context.SpanID))
+		Expect(addB.Parent).To(Equal(rootB.Context.SpanID))
+
+		addA.Finish(nil)
+		addB.Finish(nil)
+		rootB.Finish(nil)
+		Expect(exporter.exports).To(Equal([][]*tracing.Span{{addB, rootB}}))
+
+		rootA.Finish(nil)
+		Expect(exporter.exports[1]).To(Equal([]*tracing.Span{addA, rootA}))
+	})
+
+	It("exports a span that outlives the outermost on its own", func() {
+		ctx, root := tracer.Start(context.Background(), "up", nil)
+		_, add := tracer.Start(ctx, "AddNetwork", nil)
+		root.Finish(nil)
+		add.Finish(nil)
+		Expect(exporter.exports).To(Equal([][]*tracing.Span{{root}, {add}}))
+	})
+
+	It("continues the trace of a remote parent", func() {
+		parent, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, span := tracer.StartRemote(context.Background(), parent, "up", nil)
+		Expect(span.Context.TraceID).To(Equal(parent.TraceID))
+		Expect(span.Context.SpanID).NotTo(Equal(parent.SpanID))
+		Expect(span.Parent).To(Equal(parent.SpanID))
+		span.Finish(nil)
+
+		Expect(exporter.exports).To(HaveLen(1))
+	})
+
+	It("starts a new, sampled trace when the remote parent is invalid", func() {
+		_, span := tracer.StartRemote(context.Background(), tracing.SpanContext{}, "up", nil)
+		Expect(span.Context.IsValid()).To(BeTrue())
+		Expect(span.Context.Sampled).To(BeTrue())
+		Expect(span.Parent.IsValid()).To(BeFalse())
+	})
+
+	It("does not export traces that the remote parent did not sample", func() {
+		parent, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, span := tracer.StartRemote(context.Background(), parent, "up", nil)
+		_, add := tracer.Start(ctx, "AddNetwork", nil)
+		add.Finish(nil)
+		span.Finish(nil)
+
+		Expect(exporter.exports).To(BeEmpty())
+	})
+
+	Context("when exporting fails", func() {
+		It("only logs", func() {
+			exporter.err = errors.New("potato")
+			_, span := tracer.Start(context.Background(), "up", nil)
+			span.Finish(nil)
+			Expect(exporter.exports).To(HaveLen(1))
+		})
+	})
+
+	Context("when tracing is disabled", func() {
+		It("records nothing", func() {
+			var tracer *tracing.Tracer
+			ctx, span := tracer.Start(context.Background(), "up", nil)
+			Expect(span).To(BeNil())
+
+			span.SetAttribute("handle", "some-handle")
+			span.Finish(nil)
+			Expect(span.SpanContext().IsValid()).To(BeFalse())
+			Expect(tracing.SpanFromContext(ctx)).To(BeNil())
+		})
+	})
+})